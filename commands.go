@@ -0,0 +1,720 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/crawler"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/releases"
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+// command is a peerlogger subcommand, invoked as `peerlogger <name> [flags]`.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"graph":      {"Export the discovery topology graph of a crawl", runGraph},
+	"enr":        {"Decode a node record (enr:, enode://, hex, base64) or a stored node ID", runENR},
+	"history":    {"Show the stored record versions and change events of a node", runHistory},
+	"migrate":    {"Convert legacy X/Y pubkeys to hex before the crawler clears the nodes table", runMigrate},
+	"adoption":   {"Show the adoption curves of client releases over the stored crawls", runAdoption},
+	"advisories": {"Show how many nodes are exposed to each advisory per crawl", runAdvisories},
+	"releases":   {"Summarise how far behind the latest client releases the nodes of a crawl are", runReleases},
+	"hosting":    {"Show how many nodes run on each cloud, hosting or residential provider per crawl", runHosting},
+	"anonymize":  {"Anonymise the stored IPs of rows older than a number of days", runAnonymize},
+	"blacklist":  {"Add, remove or list stored blacklist entries and show their audit log", runBlacklist},
+	"sybil":      {"Show the IPs, prefixes, ASNs and keyspace clusters flagged by the sybil detector", runSybil},
+}
+
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(args)
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nWithout a command the crawler is started.\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// createOutput opens the output file of a command, "-" writes to stdout.
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(path)
+}
+
+func runGraph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.String("format", "graphml", "output format (graphml, dot, json)")
+	crawl := fs.String("crawl", "", "crawl timestamp in RFC3339 format (default: latest crawl)")
+	out := fs.String("out", "-", "output file (- for stdout)")
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var crawlTime time.Time
+	if *crawl == "" {
+		if crawlTime, err = db.LatestCrawl(database); err != nil {
+			return fmt.Errorf("no crawl with neighbor data found: %w", err)
+		}
+	} else if crawlTime, err = time.Parse(time.RFC3339Nano, *crawl); err != nil {
+		return fmt.Errorf("invalid crawl timestamp: %w", err)
+	}
+
+	graph, err := db.ReadTopology(database, crawlTime)
+	if err != nil {
+		return fmt.Errorf("reading topology failed: %w", err)
+	}
+
+	w, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return graph.Write(w, *format)
+}
+
+// nodeIDPattern matches a hex node ID, which is looked up in the database.
+var nodeIDPattern = regexp.MustCompile(`^(0x)?[0-9a-fA-F]{64}$`)
+
+func runENR(args []string) error {
+	fs := flag.NewFlagSet("enr", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the record as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s enr [flags] <record | node ID | ->\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("need a record or node ID as argument")
+	}
+
+	source := fs.Arg(0)
+	if source == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		source = string(b)
+	}
+	source = strings.TrimSpace(source)
+
+	var dump *crawler.RecordDump
+	if nodeIDPattern.MatchString(source) {
+		database, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		n, err := db.LookupNode(database, strings.ToLower(strings.TrimPrefix(source, "0x")))
+		if err != nil {
+			return fmt.Errorf("node lookup failed: %w", err)
+		}
+		dump = crawler.DumpNode(n)
+	} else {
+		var err error
+		if dump, err = crawler.DecodeRecord(source); err != nil {
+			return fmt.Errorf("invalid record: %w", err)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dump)
+	}
+	dump.WriteText(os.Stdout)
+	return nil
+}
+
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the history as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [flags] <node ID>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || !nodeIDPattern.MatchString(fs.Arg(0)) {
+		fs.Usage()
+		return errors.New("need a node ID as argument")
+	}
+	id := strings.ToLower(strings.TrimPrefix(fs.Arg(0), "0x"))
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	records, err := db.ReadENRHistory(database, id)
+	if err != nil {
+		return fmt.Errorf("reading record history failed: %w", err)
+	}
+	changes, err := db.ReadENRChanges(database, id)
+	if err != nil {
+		return fmt.Errorf("reading change events failed: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Records []db.ENRRecord `json:"records"`
+			Changes []db.ENRChange `json:"changes"`
+		}{records, changes})
+	}
+
+	fmt.Printf("Node %s has %d stored records and %d change events.\n\n", id, len(records), len(changes))
+	for _, r := range records {
+		fmt.Printf("seq %-6d first seen %s\n", r.Seq, r.FirstSeen.Format(time.RFC3339))
+		if r.ENR != "" {
+			fmt.Printf("  %s\n", r.ENR)
+			continue
+		}
+		// stored without the raw record in an anonymising privacy mode
+		fields := make([]string, 0, len(r.Fields))
+		for field, v := range r.Fields {
+			fields = append(fields, field+"="+v)
+		}
+		sort.Strings(fields)
+		fmt.Printf("  %s\n", strings.Join(fields, " "))
+	}
+	if len(changes) > 0 {
+		fmt.Println()
+	}
+	for _, c := range changes {
+		fmt.Printf("seq %d -> %d  %s  %-20s %s: %q -> %q\n",
+			c.PrevSeq, c.Seq, c.Detected.Format(time.RFC3339), c.Kind, c.Field, c.Old, c.New)
+	}
+	return nil
+}
+
+// runMigrate converts the legacy pubkeys in place. The crawler clears the
+// nodes table when it starts, so the migration has to run before the first
+// start of this version.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s migrate\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Run it before starting the crawler, which clears the nodes table at start.")
+	}
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	updated, err := db.MigratePubkeys(database)
+	if err != nil {
+		return fmt.Errorf("pubkey migration failed: %w", err)
+	}
+	fmt.Printf("Migrated pubkeys of %d rows.\n", updated)
+	return nil
+}
+
+func runAnonymize(args []string) error {
+	fs := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	days := fs.Int("days", loadConfig().IPRetentionDays, "anonymise rows older than this many days")
+	mode := fs.String("mode", loadConfig().IPRetentionMode, "privacy mode to apply (truncated, hmac, none)")
+	fs.Parse(args)
+
+	if *days <= 0 {
+		return errors.New("-days must be positive")
+	}
+	privacy, err := initPrivacy(*mode)
+	if err != nil {
+		return err
+	}
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	result, err := db.AnonymizeIPs(database, privacy, time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return fmt.Errorf("anonymising IPs failed: %w", err)
+	}
+	fmt.Printf("Anonymised %d node IPs, %d change events and %d sybil findings, dropped %d signed records.\n",
+		result.Nodes, result.Changes, result.Findings, result.Records)
+	return nil
+}
+
+// crawlTimestamp parses the -crawl flag of the node reports, defaulting to the
+// latest node write.
+func crawlTimestamp(database *sql.DB, crawl string) (time.Time, error) {
+	if crawl != "" {
+		t, err := time.Parse(time.RFC3339Nano, crawl)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid crawl timestamp: %w", err)
+		}
+		return t, nil
+	}
+	t, err := db.LatestNodeCrawl(database)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("no crawl found: %w", err)
+	}
+	return t, nil
+}
+
+func runReleases(args []string) error {
+	fs := flag.NewFlagSet("releases", flag.ExitOnError)
+	manifestPath := fs.String("manifest", loadConfig().ReleaseManifestPath, "release manifest (JSON)")
+	crawl := fs.String("crawl", "", "crawl timestamp in RFC3339 format (default: latest crawl)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	listNodes := fs.Bool("nodes", false, "list the release status of every node")
+	fs.Parse(args)
+	if *manifestPath == "" {
+		fs.Usage()
+		return errors.New("no release manifest given (-manifest or RELEASE_MANIFEST_PATH)")
+	}
+
+	manifest, err := releases.LoadManifest(*manifestPath)
+	if err != nil {
+		return fmt.Errorf("loading release manifest failed: %w", err)
+	}
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	crawlTime, err := crawlTimestamp(database, *crawl)
+	if err != nil {
+		return err
+	}
+	clients, err := db.ReadClientVersions(database, crawlTime)
+	if err != nil {
+		return fmt.Errorf("reading client versions failed: %w", err)
+	}
+
+	type nodeStatus struct {
+		ID      string `json:"id"`
+		Client  string `json:"client"`
+		Version string `json:"version,omitempty"`
+		releases.Status
+	}
+	report := manifest.NewReport()
+	var nodes []nodeStatus
+	for _, c := range clients {
+		status := report.Add(c.Client, c.Version)
+		if *listNodes {
+			var version string
+			if c.Version != (vparser.Version{}) {
+				version = c.Version.Semver()
+			}
+			nodes = append(nodes, nodeStatus{c.ID, c.Client, version, status})
+		}
+	}
+	report.Sort()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Crawl time.Time `json:"crawl"`
+			*releases.Report
+			Nodes []nodeStatus `json:"nodes,omitempty"`
+		}{crawlTime, report, nodes})
+	}
+
+	fmt.Printf("Release status of %d nodes crawled at %s.\n\n", len(clients), crawlTime.Format(time.RFC3339))
+	if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		fmt.Println()
+	}
+	for _, n := range nodes {
+		fmt.Printf("%s  %-12s %-10s %s", n.ID, n.Client, dashIfEmpty(n.Version), n.State)
+		if n.Behind > 0 {
+			fmt.Printf(" (%d releases)", n.Behind)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func runAdvisories(args []string) error {
+	fs := flag.NewFlagSet("advisories", flag.ExitOnError)
+	crawl := fs.String("crawl", "", "crawl timestamp in RFC3339 format (default: latest crawl)")
+	all := fs.Bool("all", false, "show the exposure of all stored crawls")
+	asJSON := fs.Bool("json", false, "print the exposure as JSON")
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var crawlTime time.Time
+	if !*all {
+		if crawlTime, err = crawlTimestamp(database, *crawl); err != nil {
+			return err
+		}
+	}
+	exposure, err := db.ReadExposure(database, crawlTime)
+	if err != nil {
+		return fmt.Errorf("reading advisory exposure failed: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(exposure)
+	}
+	if len(exposure) == 0 {
+		fmt.Println("No nodes matched any advisory.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CRAWL\tADVISORY\tCLIENT\tNODES\tSHARE OF CLIENT")
+	for _, e := range exposure {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.1f%%\n", e.Crawl.Format(time.RFC3339), e.Advisory, e.Client,
+			e.Nodes, 100*float64(e.Nodes)/float64(max(e.Total, 1)))
+	}
+	return w.Flush()
+}
+
+func runAdoption(args []string) error {
+	fs := flag.NewFlagSet("adoption", flag.ExitOnError)
+	client := fs.String("client", "", "client name as parsed from the Hello name, e.g. geth (default: all)")
+	since := fs.Duration("since", 30*24*time.Hour, "only include crawls within this duration")
+	asJSON := fs.Bool("json", false, "print the time series as JSON")
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	series, err := db.ReadAdoption(database, strings.ToLower(*client), time.Now().Add(-*since))
+	if err != nil {
+		return fmt.Errorf("reading release adoption failed: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(series)
+	}
+	if len(series) == 0 {
+		fmt.Println("No release adoption data found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT\tVERSION\tFIRST SEEN\tCRAWLS\tNODES\tSHARE OF CLIENT\tPEAK SHARE")
+	for _, s := range series {
+		last, peak := s.Points[len(s.Points)-1], 0.0
+		for _, p := range s.Points {
+			peak = max(peak, p.ClientShare)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%.1f%%\t%.1f%%\n", s.Client, s.Version, s.FirstSeen.Format(time.RFC3339),
+			len(s.Points), last.Nodes, 100*last.ClientShare, 100*peak)
+	}
+	return w.Flush()
+}
+
+func runHosting(args []string) error {
+	fs := flag.NewFlagSet("hosting", flag.ExitOnError)
+	crawl := fs.String("crawl", "", "crawl timestamp in RFC3339 format (default: latest crawl)")
+	all := fs.Bool("all", false, "show the providers of all stored crawls")
+	category := fs.String("category", "", "only show providers of a category (cloud, hosting, residential, unknown)")
+	asJSON := fs.Bool("json", false, "print the provider counts as JSON")
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var crawlTime time.Time
+	if !*all {
+		if crawlTime, err = crawlTimestamp(database, *crawl); err != nil {
+			return err
+		}
+	}
+	shares, err := db.ReadHosting(database, crawlTime)
+	if err != nil {
+		return fmt.Errorf("reading hosting providers failed: %w", err)
+	}
+	if *category != "" {
+		filtered := shares[:0]
+		for _, s := range shares {
+			if string(s.Category) == *category {
+				filtered = append(filtered, s)
+			}
+		}
+		shares = filtered
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(shares)
+	}
+	if len(shares) == 0 {
+		fmt.Println("No classified nodes.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CRAWL\tCATEGORY\tPROVIDER\tNODES\tSHARE")
+	for _, s := range shares {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.1f%%\n", s.Crawl.Format(time.RFC3339), s.Category, dashIfEmpty(s.Provider),
+			s.Nodes, 100*float64(s.Nodes)/float64(max(s.Total, 1)))
+	}
+	return w.Flush()
+}
+
+// blacklistActor is the default actor of blacklist changes made on the CLI.
+func blacklistActor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "cli"
+}
+
+// runBlacklist manages the stored blacklist entries. A running crawler picks
+// up the changes within a minute.
+func runBlacklist(args []string) error {
+	usage := errors.New("usage: blacklist add|remove|list|audit [flags] [entry]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("blacklist "+args[0], flag.ExitOnError)
+	var (
+		by, reason, expires *string
+		ttl                 *time.Duration
+		all, asJSON         *bool
+		limit               *int
+	)
+	switch args[0] {
+	case "add":
+		by = fs.String("by", blacklistActor(), "actor recorded in the audit log")
+		reason = fs.String("reason", "", "reason of the entry")
+		expires = fs.String("expires", "", "expiry in RFC3339 format (default: never)")
+		ttl = fs.Duration("ttl", 0, "expiry relative to now, e.g. 72h (default: never)")
+	case "remove":
+		by = fs.String("by", blacklistActor(), "actor recorded in the audit log")
+	case "list":
+		all = fs.Bool("all", false, "include expired entries")
+		asJSON = fs.Bool("json", false, "print the entries as JSON")
+	case "audit":
+		limit = fs.Int("limit", 50, "number of changes to show")
+		asJSON = fs.Bool("json", false, "print the changes as JSON")
+	default:
+		return usage
+	}
+	fs.Parse(args[1:])
+	if (args[0] == "add" || args[0] == "remove") && fs.NArg() != 1 {
+		return fmt.Errorf("usage: blacklist %s [flags] <ip|cidr|pubkey>", args[0])
+	}
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "add":
+		e := db.BlacklistEntry{Entry: fs.Arg(0), Reason: *reason, AddedBy: *by}
+		switch {
+		case *expires != "" && *ttl != 0:
+			return errors.New("-expires and -ttl are exclusive")
+		case *expires != "":
+			t, err := time.Parse(time.RFC3339, *expires)
+			if err != nil {
+				return fmt.Errorf("invalid expiry: %w", err)
+			}
+			e.Expires = &t
+		case *ttl < 0:
+			return errors.New("-ttl must be positive")
+		case *ttl > 0:
+			t := time.Now().Add(*ttl)
+			e.Expires = &t
+		}
+		if e, err = db.AddBlacklistEntry(database, e); err != nil {
+			return fmt.Errorf("adding blacklist entry failed: %w", err)
+		}
+		fmt.Printf("Blacklisted %s %s.\n", e.Kind, e.Entry)
+		return nil
+
+	case "remove":
+		removed, err := db.RemoveBlacklistEntry(database, fs.Arg(0), *by)
+		if err != nil {
+			return fmt.Errorf("removing blacklist entry failed: %w", err)
+		}
+		if !removed {
+			return fmt.Errorf("%s is not a stored blacklist entry", fs.Arg(0))
+		}
+		fmt.Printf("Removed %s from the blacklist.\n", fs.Arg(0))
+		return nil
+
+	case "list":
+		var at time.Time
+		if !*all {
+			at = time.Now()
+		}
+		entries, err := db.ReadBlacklistEntries(database, at)
+		if err != nil {
+			return fmt.Errorf("reading blacklist entries failed: %w", err)
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No stored blacklist entries.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tENTRY\tEXPIRES\tADDED BY\tADDED\tREASON")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Kind, e.Entry, formatExpiry(e.Expires),
+				e.AddedBy, e.Added.Format(time.RFC3339), dashIfEmpty(e.Reason))
+		}
+		return w.Flush()
+
+	default:
+		changes, err := db.ReadBlacklistAudit(database, *limit)
+		if err != nil {
+			return fmt.Errorf("reading blacklist audit log failed: %w", err)
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(changes)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No blacklist changes.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "AT\tACTOR\tACTION\tKIND\tENTRY\tEXPIRES\tREASON")
+		for _, c := range changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.At.Format(time.RFC3339), c.Actor, c.Action,
+				c.Kind, c.Entry, formatExpiry(c.Expires), dashIfEmpty(c.Reason))
+		}
+		return w.Flush()
+	}
+}
+
+func formatExpiry(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func runSybil(args []string) error {
+	fs := flag.NewFlagSet("sybil", flag.ExitOnError)
+	run := fs.String("run", "", "detector run timestamp in RFC3339 format (default: latest run)")
+	all := fs.Bool("all", false, "show the findings of all runs")
+	kind := fs.String("kind", "", "only show findings of a kind (ip, prefix, asn, keyspace)")
+	asJSON := fs.Bool("json", false, "print the findings, including node IDs, as JSON")
+	fs.Parse(args)
+
+	database, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var detected time.Time
+	switch {
+	case *all:
+	case *run != "":
+		if detected, err = time.Parse(time.RFC3339Nano, *run); err != nil {
+			return fmt.Errorf("invalid run timestamp: %w", err)
+		}
+	default:
+		detected, err = db.LatestSybilRun(database)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("No sybil findings.")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading sybil findings failed: %w", err)
+		}
+	}
+	findings, err := db.ReadSybilFindings(database, detected)
+	if err != nil {
+		return fmt.Errorf("reading sybil findings failed: %w", err)
+	}
+	if *kind != "" {
+		filtered := findings[:0]
+		for _, f := range findings {
+			if string(f.Kind) == *kind {
+				filtered = append(filtered, f)
+			}
+		}
+		findings = filtered
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	}
+	if len(findings) == 0 {
+		fmt.Println("No sybil findings.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DETECTED\tKIND\tKEY\tNODES\tIPS\tTHRESHOLD\tBLACKLISTED")
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%t\n", f.Detected.Format(time.RFC3339), f.Kind, dashIfEmpty(f.Key),
+			f.Nodes, f.IPs, f.Threshold, f.Blacklisted)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// blacklistRequest is the body of POST /api/admin/blacklist. Expires is an
+// RFC3339 timestamp, TTL a duration from now; without either the entry
+// doesn't expire.
+type blacklistRequest struct {
+	Entry   string `json:"entry"`
+	Reason  string `json:"reason"`
+	Expires string `json:"expires"`
+	TTL     string `json:"ttl"`
+}
+
+// EnableAdmin serves the admin endpoints managing the blacklist. Requests
+// need one of the bearer tokens, keyed by the admin name recorded in the
+// audit log. Without tokens the endpoints stay disabled.
+func (s *Server) EnableAdmin(blacklist *db.ManagedBlacklist, tokens map[string]string) {
+	if len(tokens) == 0 {
+		return
+	}
+	s.blacklist = blacklist
+	s.tokens = tokens
+	s.mux.HandleFunc("GET /api/admin/blacklist", s.admin(s.handleBlacklist))
+	s.mux.HandleFunc("POST /api/admin/blacklist", s.admin(s.handleBlacklistAdd))
+	s.mux.HandleFunc("DELETE /api/admin/blacklist", s.admin(s.handleBlacklistRemove))
+	s.mux.HandleFunc("GET /api/admin/blacklist/audit", s.admin(s.handleBlacklistAudit))
+}
+
+// admin authenticates the request and passes the admin name to the handler.
+func (s *Server) admin(h func(w http.ResponseWriter, r *http.Request, actor string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok {
+			for name, t := range s.tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+					h(w, r, name)
+					return
+				}
+			}
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+	}
+}
+
+// handleBlacklist lists the stored entries, including expired ones.
+//
+//	GET /api/admin/blacklist
+func (s *Server) handleBlacklist(w http.ResponseWriter, r *http.Request, actor string) {
+	entries, err := db.ReadBlacklistEntries(s.db, time.Time{})
+	if err != nil {
+		log.Error().Err(err).Msg("Reading blacklist entries failed")
+		writeError(w, http.StatusInternalServerError, errors.New("reading blacklist entries failed"))
+		return
+	}
+	if entries == nil {
+		entries = []db.BlacklistEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleBlacklistAdd adds or updates an entry and applies it immediately.
+//
+//	POST /api/admin/blacklist {"entry": "203.0.113.0/24", "reason": "scanner", "ttl": "72h"}
+func (s *Server) handleBlacklistAdd(w http.ResponseWriter, r *http.Request, actor string) {
+	var req blacklistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+	if _, _, err := util.ParseBlacklistEntry(req.Entry); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	e := db.BlacklistEntry{Entry: req.Entry, Reason: req.Reason, AddedBy: actor}
+	switch {
+	case req.Expires != "" && req.TTL != "":
+		writeError(w, http.StatusBadRequest, errors.New("expires and ttl are exclusive"))
+		return
+	case req.Expires != "":
+		t, err := time.Parse(time.RFC3339, req.Expires)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("expires must be an RFC3339 timestamp"))
+			return
+		}
+		e.Expires = &t
+	case req.TTL != "":
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("ttl must be a positive duration"))
+			return
+		}
+		t := time.Now().Add(d)
+		e.Expires = &t
+	}
+	e, err := s.blacklist.Add(e)
+	if err != nil {
+		log.Error().Err(err).Str("entry", e.Entry).Msg("Adding blacklist entry failed")
+		writeError(w, http.StatusInternalServerError, errors.New("adding blacklist entry failed"))
+		return
+	}
+	log.Info().Str("actor", actor).Str("kind", e.Kind).Str("entry", e.Entry).Msg("Blacklist entry added")
+	writeJSON(w, http.StatusCreated, e)
+}
+
+// handleBlacklistRemove removes an entry and applies the change immediately.
+//
+//	DELETE /api/admin/blacklist?entry=203.0.113.0/24
+func (s *Server) handleBlacklistRemove(w http.ResponseWriter, r *http.Request, actor string) {
+	entry := r.URL.Query().Get("entry")
+	if _, _, err := util.ParseBlacklistEntry(entry); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	removed, err := s.blacklist.Remove(entry, actor)
+	if err != nil {
+		log.Error().Err(err).Str("entry", entry).Msg("Removing blacklist entry failed")
+		writeError(w, http.StatusInternalServerError, errors.New("removing blacklist entry failed"))
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, errors.New("no such blacklist entry"))
+		return
+	}
+	log.Info().Str("actor", actor).Str("entry", entry).Msg("Blacklist entry removed")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBlacklistAudit returns the latest blacklist changes.
+//
+//	GET /api/admin/blacklist/audit?limit=100
+func (s *Server) handleBlacklistAudit(w http.ResponseWriter, r *http.Request, actor string) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+	changes, err := db.ReadBlacklistAudit(s.db, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Reading blacklist audit log failed")
+		writeError(w, http.StatusInternalServerError, errors.New("reading blacklist audit log failed"))
+		return
+	}
+	if changes == nil {
+		changes = []db.BlacklistChange{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/api"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func TestAdminBlacklist(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	blacklist := db.NewManagedBlacklist(mockDB, util.NewBlacklist(nil, nil), nil, nil)
+	srv := api.NewServer(mockDB)
+	srv.EnableAdmin(blacklist, map[string]string{"alice": "secret"})
+
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/admin/blacklist", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/admin/blacklist", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong token, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/admin/blacklist", "secret", `{"entry": "nonsense"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid entry, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/admin/blacklist", "secret", `{"entry": "192.0.2.1", "ttl": "-1h"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative ttl, got %d", rec.Code)
+	}
+
+	columns := []string{"kind", "entry", "reason", "expires", "added_by", "added"}
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blacklist_entries").
+		WithArgs(util.EntryIP, "203.0.113.0/24", "scanner", sqlmock.AnyArg(), "alice", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO blacklist_audit").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM blacklist_entries").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(util.EntryIP, "203.0.113.0/24", "scanner", nil, "alice", time.Now()))
+
+	rec := do(http.MethodPost, "/api/admin/blacklist", "secret", `{"entry": "203.0.113.0/24", "reason": "scanner", "ttl": "72h"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if !blacklist.IsIPBlacklisted("203.0.113.9") {
+		t.Error("Expected the added entry to apply immediately")
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blacklist_entries").WithArgs(util.EntryIP, "198.51.100.1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	if rec := do(http.MethodDelete, "/api/admin/blacklist?entry=198.51.100.1", "secret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown entry, got %d", rec.Code)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	srv := api.NewServer(mockDB)
+	srv.EnableAdmin(db.NewManagedBlacklist(mockDB, util.NewBlacklist(nil, nil), nil, nil), nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/blacklist", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without admin tokens, got %d", rec.Code)
+	}
+}
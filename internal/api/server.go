@@ -0,0 +1,107 @@
+// Package api serves the crawl reports stored in the database over HTTP.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/prometheus"
+	"github.com/rs/zerolog/log"
+
+	"github.com/200ug/peerlogger/internal/db"
+)
+
+// Server is the HTTP API. All endpoints return JSON, except /metrics which
+// serves the process metrics in the Prometheus text format.
+type Server struct {
+	db  *sql.DB
+	mux *http.ServeMux
+
+	// admin endpoints, see EnableAdmin
+	blacklist *db.ManagedBlacklist
+	tokens    map[string]string
+}
+
+// NewServer creates the API handler for the database.
+func NewServer(database *sql.DB) *Server {
+	s := &Server{db: database, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /api/adoption", s.handleAdoption)
+	s.mux.Handle("GET /metrics", prometheus.Handler(metrics.DefaultRegistry))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on addr until the context is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleAdoption returns the adoption curves of client releases.
+//
+//	GET /api/adoption?client=geth&since=2025-08-01T00:00:00Z
+//
+// since also accepts a duration relative to now (e.g. 720h), the default is
+// the last 30 days.
+func (s *Server) handleAdoption(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query().Get("since"), 30*24*time.Hour)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	series, err := db.ReadAdoption(s.db, strings.ToLower(r.URL.Query().Get("client")), since)
+	if err != nil {
+		log.Error().Err(err).Msg("Reading release adoption failed")
+		writeError(w, http.StatusInternalServerError, errors.New("reading release adoption failed"))
+		return
+	}
+	if series == nil {
+		series = []db.AdoptionSeries{}
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+// parseSince parses an RFC3339 timestamp or a duration before now.
+func parseSince(v string, def time.Duration) (time.Time, error) {
+	if v == "" {
+		return time.Now().Add(-def), nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, errors.New("since must be an RFC3339 timestamp or a duration")
+	}
+	return t, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Writing API response failed")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
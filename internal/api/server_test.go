@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/api"
+	"github.com/200ug/peerlogger/internal/db"
+	_ "github.com/200ug/peerlogger/internal/util" // registers the GeoIP cache counters
+)
+
+func TestAdoption(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	crawl := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM release_adoption").WithArgs("geth", crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"client", "version", "first_seen", "crawl", "nodes", "client_nodes", "crawl_nodes"}).
+			AddRow("geth", "1.16.2", crawl, crawl, 10, 40, 100))
+
+	srv := api.NewServer(mockDB)
+	rec := httptest.NewRecorder()
+	// client names are stored lowercase
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/adoption?client=Geth&since=2025-08-01T12:00:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var series []db.AdoptionSeries
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(series) != 1 || series[0].Points[0].ClientShare != 0.25 {
+		t.Errorf("Unexpected series %+v", series)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/adoption?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid since, got %d", rec.Code)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	rec := httptest.NewRecorder()
+	api.NewServer(mockDB).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "geoip_cache_hits") {
+		t.Errorf("Expected the GeoIP cache counters, got:\n%s", rec.Body)
+	}
+}
@@ -0,0 +1,28 @@
+package common
+
+import "time"
+
+// BeaconInfo holds what a consensus-layer node reported over libp2p.
+type BeaconInfo struct {
+	AgentVersion string   `json:"agentVersion"`
+	Protocols    []string `json:"protocols,omitempty"`
+	// transport the connection was established over (tcp or quic)
+	Transport string `json:"transport"`
+	// Status req/resp
+	ForkDigest     string `json:"forkDigest"`
+	FinalizedRoot  string `json:"finalizedRoot"`
+	FinalizedEpoch uint64 `json:"finalizedEpoch"`
+	HeadRoot       string `json:"headRoot"`
+	HeadSlot       uint64 `json:"headSlot"`
+	// MetaData req/resp
+	MetadataSeq uint64    `json:"metadataSeq"`
+	ProbedAt    time.Time `json:"probedAt"`
+}
+
+// FinalizedSlot returns the first slot of the finalized epoch.
+func (info *BeaconInfo) FinalizedSlot() uint64 {
+	return info.FinalizedEpoch * slotsPerEpoch
+}
+
+// slotsPerEpoch is the same on all supported networks.
+const slotsPerEpoch = 32
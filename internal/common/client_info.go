@@ -2,6 +2,7 @@ package common
 
 import (
 	"math/big"
+	"net/netip"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/forkid"
@@ -17,4 +18,17 @@ type ClientInfo struct {
 	Blockheight     string
 	TotalDifficulty *big.Int
 	HeadHash        common.Hash
+	DialAddr        netip.AddrPort
+}
+
+// DialFamily returns the address family (ip4 or ip6) the handshake succeeded over.
+func (info *ClientInfo) DialFamily() string {
+	switch {
+	case !info.DialAddr.IsValid():
+		return ""
+	case info.DialAddr.Addr().Unmap().Is4():
+		return "ip4"
+	default:
+		return "ip6"
+	}
 }
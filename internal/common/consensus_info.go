@@ -0,0 +1,21 @@
+package common
+
+// ConsensusInfo holds the consensus-layer fields advertised in the ENR of a
+// beacon node.
+type ConsensusInfo struct {
+	ForkDigest      string `json:"forkDigest"`
+	ForkName        string `json:"forkName,omitempty"`
+	NextForkVersion string `json:"nextForkVersion"`
+	NextForkEpoch   uint64 `json:"nextForkEpoch"`
+	// next fork digest (nfd), advertised from Fulu on
+	NextForkDigest string `json:"nextForkDigest,omitempty"`
+	// attestation and sync committee subnet bitvectors, hex encoded
+	Attnets       string `json:"attnets,omitempty"`
+	AttnetsCount  int    `json:"attnetsCount"`
+	Syncnets      string `json:"syncnets,omitempty"`
+	SyncnetsCount int    `json:"syncnetsCount"`
+	// PeerDAS custody group count (cgc), nil if not advertised
+	CustodyGroupCount *uint64 `json:"custodyGroupCount,omitempty"`
+	// libp2p peer ID derived from the secp256k1 key
+	PeerID string `json:"peerId,omitempty"`
+}
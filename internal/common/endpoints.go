@@ -0,0 +1,103 @@
+package common
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Endpoints holds every address advertised in a node record. Unset ports are zero.
+type Endpoints struct {
+	IP    netip.Addr
+	IP6   netip.Addr
+	TCP   uint16
+	UDP   uint16
+	TCP6  uint16
+	UDP6  uint16
+	QUIC  uint16
+	QUIC6 uint16
+}
+
+// NodeEndpoints extracts the advertised endpoints from the record of n.
+func NodeEndpoints(n *enode.Node) Endpoints {
+	var e Endpoints
+
+	var ip4 enr.IPv4
+	if n.Load(&ip4) == nil {
+		e.IP, _ = netip.AddrFromSlice(net.IP(ip4).To4())
+	}
+	var ip6 enr.IPv6
+	if n.Load(&ip6) == nil {
+		e.IP6, _ = netip.AddrFromSlice(net.IP(ip6).To16())
+	}
+	// Records created by enode.NewV4 (discv4 neighbors) only carry "ip".
+	if !e.IP.IsValid() && !e.IP6.IsValid() {
+		if addr := n.IPAddr(); addr.Is4() {
+			e.IP = addr
+		} else if addr.IsValid() {
+			e.IP6 = addr
+		}
+	}
+
+	var tcp enr.TCP
+	if n.Load(&tcp) == nil {
+		e.TCP = uint16(tcp)
+	}
+	var udp enr.UDP
+	if n.Load(&udp) == nil {
+		e.UDP = uint16(udp)
+	}
+	var tcp6 enr.TCP6
+	if n.Load(&tcp6) == nil {
+		e.TCP6 = uint16(tcp6)
+	}
+	var udp6 enr.UDP6
+	if n.Load(&udp6) == nil {
+		e.UDP6 = uint16(udp6)
+	}
+	var quic enr.QUIC
+	if n.Load(&quic) == nil {
+		e.QUIC = uint16(quic)
+	}
+	var quic6 enr.QUIC6
+	if n.Load(&quic6) == nil {
+		e.QUIC6 = uint16(quic6)
+	}
+	return e
+}
+
+// Transports returns the advertised transports as a comma separated list, e.g.
+// "tcp,udp,udp6".
+func (e Endpoints) Transports() string {
+	var transports []string
+	for _, t := range []struct {
+		name string
+		port uint16
+	}{
+		{"tcp", e.TCP}, {"udp", e.UDP}, {"quic", e.QUIC},
+		{"tcp6", e.TCP6}, {"udp6", e.UDP6}, {"quic6", e.QUIC6},
+	} {
+		if t.port != 0 {
+			transports = append(transports, t.name)
+		}
+	}
+	return strings.Join(transports, ",")
+}
+
+// Mismatch reports whether the record advertises a different IP than the one
+// the node was observed at. The advertised IP of the same address family is
+// compared, records without one are not considered mismatching.
+func (e Endpoints) Mismatch(observed netip.Addr) bool {
+	observed = observed.Unmap()
+	if !observed.IsValid() {
+		return false
+	}
+	advertised := e.IP
+	if observed.Is6() {
+		advertised = e.IP6
+	}
+	return advertised.IsValid() && advertised != observed
+}
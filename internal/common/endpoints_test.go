@@ -0,0 +1,70 @@
+package common_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func TestNodeEndpoints(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IPv4(net.ParseIP("8.8.8.8")))
+	r.Set(enr.IPv6(net.ParseIP("2001:4860:4860::8888")))
+	r.Set(enr.UDP(30303))
+	r.Set(enr.TCP(30303))
+	r.Set(enr.UDP6(30304))
+	r.Set(enr.QUIC(9001))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	e := common.NodeEndpoints(n)
+	if e.IP != netip.MustParseAddr("8.8.8.8") || e.IP6 != netip.MustParseAddr("2001:4860:4860::8888") {
+		t.Errorf("Unexpected IPs: %v, %v", e.IP, e.IP6)
+	}
+	if e.UDP != 30303 || e.TCP != 30303 || e.UDP6 != 30304 || e.TCP6 != 0 || e.QUIC != 9001 {
+		t.Errorf("Unexpected ports: %+v", e)
+	}
+	if got := e.Transports(); got != "tcp,udp,quic,udp6" {
+		t.Errorf("Expected transports tcp,udp,quic,udp6, got %s", got)
+	}
+
+	tests := []struct {
+		observed string
+		mismatch bool
+	}{
+		{"8.8.8.8", false},
+		{"::ffff:8.8.8.8", false},
+		{"1.1.1.1", true},
+		{"2001:4860:4860::8888", false},
+		{"2001:4860:4860::8844", true},
+	}
+	for _, tt := range tests {
+		if got := e.Mismatch(netip.MustParseAddr(tt.observed)); got != tt.mismatch {
+			t.Errorf("Mismatch(%s) = %v, expected %v", tt.observed, got, tt.mismatch)
+		}
+	}
+	if e.Mismatch(netip.Addr{}) {
+		t.Error("Unknown observed address should not be a mismatch")
+	}
+}
+
+func TestNodeEndpointsV4(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	n := enode.NewV4(&key.PublicKey, net.ParseIP("2001:4860:4860::8888"), 30303, 30301)
+
+	e := common.NodeEndpoints(n)
+	if e.IP.IsValid() || e.IP6 != netip.MustParseAddr("2001:4860:4860::8888") {
+		t.Errorf("Unexpected IPs: %v, %v", e.IP, e.IP6)
+	}
+}
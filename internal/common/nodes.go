@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/netip"
 	"os"
 	"sort"
 	"time"
@@ -12,6 +13,12 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
+// Discovery protocol names used for per-protocol observations.
+const (
+	ProtocolV4 = "discv4"
+	ProtocolV5 = "discv5"
+)
+
 // nodes.json file format, holds a set of node records as a JSON object
 type NodeSet map[enode.ID]NodeJSON
 
@@ -27,6 +34,136 @@ type NodeJSON struct {
 	LastCheck    time.Time   `json:"lastCheck"`
 	Info         *ClientInfo `json:"clientInfo,omitempty"`
 	TooManyPeers bool        `json:"tooManyPeers,omitempty"`
+	// set when the node didn't answer ENR requests and was dialed from its enode (EIP-868 fallback)
+	ENRUnavailable bool `json:"enrUnavailable,omitempty"`
+	// routing table entries returned by FINDNODE (table crawl mode only)
+	Neighbors []Neighbor `json:"neighbors,omitempty"`
+	// per discovery protocol observations, keyed by protocol name (discv4, discv5)
+	Protocols map[string]Observation `json:"protocols,omitempty"`
+	// last successful RLPx handshake, i.e. when Info was collected
+	LastHandshake time.Time `json:"lastHandshake,omitempty"`
+	// UDP endpoint the last ENR response was received from
+	ObservedAddr netip.AddrPort `json:"observedAddr,omitempty"`
+	// consensus-layer ENR fields, set for nodes with an eth2 key
+	Consensus *ConsensusInfo `json:"consensus,omitempty"`
+	// libp2p probe result, set for consensus-layer nodes when beacon probing is enabled
+	Beacon *BeaconInfo `json:"beaconInfo,omitempty"`
+}
+
+// Observation holds what a single discovery protocol recorded for a node.
+type Observation struct {
+	Score         int       `json:"score"`
+	FirstResponse time.Time `json:"firstResponse"`
+	LastResponse  time.Time `json:"lastResponse"`
+}
+
+// Neighbor is an entry in the routing table of a node.
+type Neighbor struct {
+	ID       enode.ID `json:"id"`
+	Distance int      `json:"distance"`
+	Protocol string   `json:"protocol"`
+}
+
+// Observation returns the observation of the given discovery protocol.
+func (n NodeJSON) Observation(protocol string) Observation {
+	return n.Protocols[protocol]
+}
+
+// SetObservation stores the observation of the given discovery protocol. The
+// map is copied first since node sets share it after being copied by value.
+func (n *NodeJSON) SetObservation(protocol string, o Observation) {
+	protocols := make(map[string]Observation, len(n.Protocols)+1)
+	for k, v := range n.Protocols {
+		protocols[k] = v
+	}
+	protocols[protocol] = o
+	n.Protocols = protocols
+}
+
+// SeenBy returns the sorted names of the discovery protocols the node responded to.
+func (n NodeJSON) SeenBy() []string {
+	var protocols []string
+	for name, o := range n.Protocols {
+		if !o.LastResponse.IsZero() {
+			protocols = append(protocols, name)
+		}
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// ForProtocol returns a copy of the node holding only the observation and
+// routing table entries of the given protocol. A crawler run carries over the
+// other protocols' data from its input set, which would be stale after merging.
+func (n NodeJSON) ForProtocol(protocol string) NodeJSON {
+	var neighbors []Neighbor
+	for _, nb := range n.Neighbors {
+		if nb.Protocol == protocol {
+			neighbors = append(neighbors, nb)
+		}
+	}
+	n.Neighbors = neighbors
+
+	protocols := n.Protocols
+	n.Protocols = nil
+	if o, ok := protocols[protocol]; ok {
+		n.SetObservation(protocol, o)
+	}
+	return n
+}
+
+// MergeNodes merges the observations of the same node made by different
+// discovery protocols. The result does not depend on the argument order, apart
+// from ties which resolve to a.
+func MergeNodes(a, b NodeJSON) NodeJSON {
+	out := a
+
+	// Newest record wins.
+	if b.Seq > a.Seq || (b.Seq == a.Seq && b.LastResponse.After(a.LastResponse)) {
+		out.N, out.Seq = b.N, b.Seq
+	}
+	if b.LastResponse.After(a.LastResponse) && b.ObservedAddr.IsValid() {
+		out.ObservedAddr = b.ObservedAddr
+	}
+	// Most recent successful handshake wins.
+	if b.LastHandshake.After(a.LastHandshake) || (a.Info == nil && b.Info != nil) {
+		out.Info, out.LastHandshake = b.Info, b.LastHandshake
+	}
+	if b.Beacon != nil && (a.Beacon == nil || b.Beacon.ProbedAt.After(a.Beacon.ProbedAt)) {
+		out.Beacon = b.Beacon
+	}
+	if b.LastCheck.After(a.LastCheck) {
+		out.LastCheck = b.LastCheck
+		out.TooManyPeers = b.TooManyPeers
+	}
+
+	out.Score = max(a.Score, b.Score)
+	if out.FirstResponse.IsZero() || (!b.FirstResponse.IsZero() && b.FirstResponse.Before(out.FirstResponse)) {
+		out.FirstResponse = b.FirstResponse
+	}
+	if b.LastResponse.After(out.LastResponse) {
+		out.LastResponse = b.LastResponse
+	}
+	// Any ENR response over either protocol means the record is available.
+	out.ENRUnavailable = a.ENRUnavailable && b.ENRUnavailable
+
+	out.Neighbors = append(append([]Neighbor{}, a.Neighbors...), b.Neighbors...)
+	sort.Slice(out.Neighbors, func(i, j int) bool {
+		x, y := out.Neighbors[i], out.Neighbors[j]
+		if x.Protocol != y.Protocol {
+			return x.Protocol < y.Protocol
+		}
+		return bytes.Compare(x.ID[:], y.ID[:]) < 0
+	})
+	out.Protocols = nil
+	for _, src := range []NodeJSON{a, b} {
+		for name, o := range src.Protocols {
+			if prev, ok := out.Protocols[name]; !ok || o.LastResponse.After(prev.LastResponse) {
+				out.SetObservation(name, o)
+			}
+		}
+	}
+	return out
 }
 
 func LoadNodesJSON(file string) NodeSet {
@@ -0,0 +1,103 @@
+package common_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func testNode(t *testing.T) *enode.Node {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return enode.NewV4(&key.PublicKey, net.ParseIP("8.8.8.8"), 30303, 30303)
+}
+
+func TestNodeJSON_SetObservationCopiesMap(t *testing.T) {
+	var a common.NodeJSON
+	a.SetObservation(common.ProtocolV5, common.Observation{Score: 1})
+	b := a // node sets are copied by value
+	b.SetObservation(common.ProtocolV5, common.Observation{Score: 2})
+	if a.Observation(common.ProtocolV5).Score != 1 {
+		t.Error("SetObservation on a copy should not modify the original")
+	}
+}
+
+func TestNodeJSON_ForProtocol(t *testing.T) {
+	n := common.NodeJSON{
+		Neighbors: []common.Neighbor{
+			{Distance: 256, Protocol: common.ProtocolV4},
+			{Distance: 255, Protocol: common.ProtocolV5},
+		},
+	}
+	n.SetObservation(common.ProtocolV4, common.Observation{Score: 1})
+	n.SetObservation(common.ProtocolV5, common.Observation{Score: 2})
+
+	v5 := n.ForProtocol(common.ProtocolV5)
+	if len(v5.Neighbors) != 1 || v5.Neighbors[0].Protocol != common.ProtocolV5 {
+		t.Errorf("Expected only discv5 neighbors, got %+v", v5.Neighbors)
+	}
+	if len(v5.Protocols) != 1 || v5.Observation(common.ProtocolV5).Score != 2 {
+		t.Errorf("Expected only the discv5 observation, got %+v", v5.Protocols)
+	}
+	if len(n.Protocols) != 2 || len(n.Neighbors) != 2 {
+		t.Error("ForProtocol should not modify the original node")
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	node := testNode(t)
+	t0 := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	v5 := common.NodeJSON{
+		N:             node,
+		Score:         3,
+		FirstResponse: t0,
+		LastResponse:  t0.Add(time.Minute),
+		LastHandshake: t0.Add(2 * time.Minute),
+		Info:          &common.ClientInfo{ClientType: "Geth/v1.16.1"},
+		Neighbors:     []common.Neighbor{{Distance: 256, Protocol: common.ProtocolV5}},
+	}
+	v5.SetObservation(common.ProtocolV5, common.Observation{Score: 3, FirstResponse: t0, LastResponse: t0.Add(time.Minute)})
+
+	v4 := common.NodeJSON{
+		N:              node,
+		Score:          1,
+		FirstResponse:  t0.Add(-time.Hour),
+		LastResponse:   t0,
+		LastHandshake:  t0.Add(time.Minute),
+		Info:           &common.ClientInfo{ClientType: "Geth/v1.16.0"},
+		ENRUnavailable: true,
+		Neighbors:      []common.Neighbor{{Distance: 255, Protocol: common.ProtocolV4}},
+	}
+	v4.SetObservation(common.ProtocolV4, common.Observation{Score: 1, FirstResponse: t0.Add(-time.Hour), LastResponse: t0})
+
+	merged := common.MergeNodes(v5, v4)
+	if !reflect.DeepEqual(merged, common.MergeNodes(v4, v5)) {
+		t.Error("MergeNodes should not depend on argument order")
+	}
+	if merged.Info.ClientType != "Geth/v1.16.1" {
+		t.Errorf("Most recent handshake should win, got %s", merged.Info.ClientType)
+	}
+	if merged.Score != 3 {
+		t.Errorf("Expected score 3, got %d", merged.Score)
+	}
+	if !merged.FirstResponse.Equal(t0.Add(-time.Hour)) || !merged.LastResponse.Equal(t0.Add(time.Minute)) {
+		t.Errorf("Unexpected response times: first %v, last %v", merged.FirstResponse, merged.LastResponse)
+	}
+	if merged.ENRUnavailable {
+		t.Error("ENR should be available when one protocol got it")
+	}
+	if len(merged.Neighbors) != 2 {
+		t.Errorf("Expected neighbors of both protocols, got %+v", merged.Neighbors)
+	}
+	if got := merged.SeenBy(); !reflect.DeepEqual(got, []string{common.ProtocolV4, common.ProtocolV5}) {
+		t.Errorf("Expected node seen by both protocols, got %v", got)
+	}
+}
@@ -2,6 +2,7 @@ package crawler
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +13,9 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/200ug/peerlogger/internal/common"
 	dbpkg "github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/eth2"
+	"github.com/200ug/peerlogger/internal/hosting"
+	"github.com/200ug/peerlogger/internal/releases"
 	"github.com/200ug/peerlogger/internal/util"
 )
 
@@ -26,8 +30,45 @@ type Crawler struct {
 	Workers    uint64
 	Sepolia    bool
 	Hoodi      bool
+	Mode       string
+	// dial nodes without EIP-868 support using the enode from the neighbors response
+	ENRFallback bool
+	// listen on a dual-stack socket and try IPv6 endpoints first when dialing
+	DualStack  bool
+	PreferIPv6 bool
+	// probe consensus-layer nodes over libp2p instead of dialing them with RLPx
+	BeaconProbe bool
+	// advisories matched against the parsed client versions when writing nodes
+	Advisories *releases.Advisories
+	// classifies the hosting provider of nodes when writing them, may be nil
+	Hosting *hosting.Classifier
+	// anonymises the IPs of nodes when writing them, nil stores them as is
+	Privacy *util.IPPrivacy
+	// IP/pubkey blacklist and rules checked before dialing nodes, may be nil
+	Blacklist *util.Blacklist
 
 	NodeDB *enode.DB
+
+	geo util.GeoProvider // set by CrawlRound for the blacklist rules
+}
+
+const (
+	// ModeRandom discovers nodes through random lookups only.
+	ModeRandom = "random"
+	// ModeTable additionally dumps the routing table of every live node.
+	ModeTable = "table"
+)
+
+// ParseMode validates a crawl mode. An empty mode is ModeRandom.
+func ParseMode(s string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case "":
+		return ModeRandom, nil
+	case ModeRandom, ModeTable:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown crawl mode %q", s)
+	}
 }
 
 type crawler struct {
@@ -37,16 +78,28 @@ type crawler struct {
 	networkID uint64
 	nodeURL   string
 
-	disc resolver
+	disc     resolver
+	dumper   tableDumper
+	prober   *eth2.Prober
+	protocol string
 
 	inputIter enode.Iterator
+	neighbors *nodeQueue
 	iters     []enode.Iterator
 
+	// ENR fallback nodes awaiting their handshake, they are only added to
+	// the output once it succeeds
+	pending map[enode.ID]common.NodeJSON
+
 	ch     chan *enode.Node
 	closed chan struct{}
 
 	// settings
 	revalidateInterval time.Duration
+	enrFallback        bool
+	preferIPv6         bool
+	blacklist          *util.Blacklist
+	geo                util.GeoProvider
 
 	reqCh   chan *enode.Node
 	workers uint64
@@ -79,10 +132,16 @@ func NewCrawler(
 		inputIter: enode.IterNodes(input.Nodes()),
 		ch:        make(chan *enode.Node),
 		reqCh:     make(chan *enode.Node, 512), // TODO: define this in config
+		pending:   make(map[enode.ID]common.NodeJSON),
 		workers:   workers,
 		closed:    make(chan struct{}),
 	}
 	c.iters = append(c.iters, c.inputIter)
+	if dumper, ok := disc.(tableDumper); ok {
+		c.dumper = dumper
+		c.neighbors = newNodeQueue()
+		c.iters = append(c.iters, c.neighbors)
+	}
 	// Copy input to output initially. Any nodes that fail validation
 	// will be dropped from output during the run.
 	for id, n := range input {
@@ -166,15 +225,39 @@ func (c *crawler) getClientInfoLoop() {
 
 		var tooManyPeers bool
 		var scoreInc int
-
-		info, err := getClientInfo(c.genesis, c.networkID, c.nodeURL, n)
-		if err != nil {
-			log.Warn("GetClientInfo failed", "error", err, "nodeID", n.ID())
-			if strings.Contains(err.Error(), "too many peers") {
-				tooManyPeers = true
+		var info *common.ClientInfo
+		var beaconInfo *common.BeaconInfo
+
+		if c.prober != nil && eth2.HasEth2(n) {
+			var err error
+			beaconInfo, err = c.prober.Probe(n)
+			if err != nil {
+				log.Warn("Beacon probe failed", "error", err, "nodeID", n.ID())
+			} else {
+				scoreInc = 10
+			}
+			if beaconInfo != nil {
+				log.Info(
+					"Updating beacon info",
+					"agent", beaconInfo.AgentVersion,
+					"transport", beaconInfo.Transport,
+					"fork_digest", beaconInfo.ForkDigest,
+					"finalized_epoch", beaconInfo.FinalizedEpoch,
+					"head_slot", beaconInfo.HeadSlot,
+					"seq", beaconInfo.MetadataSeq,
+				)
 			}
 		} else {
-			scoreInc = 10
+			var err error
+			info, err = getClientInfo(c.genesis, c.networkID, c.nodeURL, n, c.preferIPv6)
+			if err != nil {
+				log.Warn("GetClientInfo failed", "error", err, "nodeID", n.ID())
+				if strings.Contains(err.Error(), "too many peers") {
+					tooManyPeers = true
+				}
+			} else {
+				scoreInc = 10
+			}
 		}
 
 		if info != nil {
@@ -191,43 +274,156 @@ func (c *crawler) getClientInfoLoop() {
 			)
 		}
 
-		c.Lock()
-		node := c.output[n.ID()]
-		node.N = n
-		node.Seq = n.Seq()
-		if info != nil {
-			node.Info = info
+		var neighbors []common.Neighbor
+		// Nodes dialed without ENR never completed the endpoint proof, so
+		// they wouldn't answer FINDNODE.
+		if c.dumper != nil && !c.enrUnavailable(n.ID()) {
+			nodes, err := c.dumper.DumpTable(n)
+			if err != nil {
+				log.Debug("Routing table dump failed", "error", err, "nodeID", n.ID())
+			}
+			for _, nb := range nodes {
+				neighbors = append(neighbors, common.Neighbor{
+					ID:       nb.ID(),
+					Distance: enode.LogDist(n.ID(), nb.ID()),
+					Protocol: c.protocol,
+				})
+			}
+			c.neighbors.push(nodes...)
 		}
-		node.TooManyPeers = tooManyPeers
-		node.Score += scoreInc
-		c.output[n.ID()] = node
-		c.Unlock()
+
+		c.storeClientInfo(n, clientInfo{
+			info:         info,
+			beacon:       beaconInfo,
+			neighbors:    neighbors,
+			tooManyPeers: tooManyPeers,
+			scoreInc:     scoreInc,
+		})
 	}
 }
 
-func (c *crawler) updateNode(n *enode.Node) {
+// clientInfo is the result of a handshake.
+type clientInfo struct {
+	info         *common.ClientInfo
+	beacon       *common.BeaconInfo
+	neighbors    []common.Neighbor
+	tooManyPeers bool
+	scoreInc     int
+}
+
+// storeClientInfo updates the output with the result of a handshake.
+func (c *crawler) storeClientInfo(n *enode.Node, r clientInfo) {
 	c.Lock()
 	defer c.Unlock()
 
+	node, ok := c.pending[n.ID()]
+	if ok {
+		delete(c.pending, n.ID())
+	} else {
+		node = c.output[n.ID()]
+	}
+	node.N = n
+	node.Seq = n.Seq()
+	if r.info != nil {
+		node.Info = r.info
+	}
+	if r.beacon != nil {
+		node.Beacon = r.beacon
+	}
+	if r.neighbors != nil {
+		node.Neighbors = r.neighbors
+	}
+	if r.scoreInc > 0 {
+		node.LastHandshake = time.Now().UTC().Truncate(time.Second)
+	}
+	node.TooManyPeers = r.tooManyPeers
+	node.Score += r.scoreInc
+	if node.ENRUnavailable {
+		// The handshake is the only liveness check for these nodes.
+		if node.Score <= 0 {
+			delete(c.output, n.ID())
+			return
+		}
+		if r.scoreInc > 0 {
+			if node.FirstResponse.IsZero() {
+				node.FirstResponse = node.LastCheck
+			}
+			node.LastResponse = node.LastCheck
+
+			obs := node.Observation(c.protocol)
+			if obs.FirstResponse.IsZero() {
+				obs.FirstResponse = node.LastCheck
+			}
+			obs.LastResponse = node.LastCheck
+			node.SetObservation(c.protocol, obs)
+		}
+	}
+	c.output[n.ID()] = node
+}
+
+func (c *crawler) updateNode(n *enode.Node) {
+	c.RLock()
+	_, pending := c.pending[n.ID()]
 	node, ok := c.output[n.ID()]
+	c.RUnlock()
 
-	// Skip validation of recently-seen nodes.
-	if ok && !node.TooManyPeers && time.Since(node.LastCheck) < c.revalidateInterval {
+	// Skip validation of pending and recently-seen nodes.
+	if pending || ok && !node.TooManyPeers && time.Since(node.LastCheck) < c.revalidateInterval {
 		return
 	}
 
-	node.LastCheck = time.Now().UTC().Truncate(time.Second)
+	// The lookups, the ENR request and the dial request are made without
+	// holding the lock, the workers need it to store their results.
+	attrs, allowed := c.allowed(n)
+	if !allowed {
+		c.Lock()
+		delete(c.output, n.ID())
+		c.Unlock()
+		return
+	}
+	checked := time.Now().UTC().Truncate(time.Second)
 
 	// Request the node record.
 	nn, err := c.disc.RequestENR(n)
+
+	if c.storeENR(n, nn, err, checked, attrs) {
+		c.reqCh <- n
+	}
+}
+
+// storeENR updates the output with the result of an ENR request and reports
+// whether the node is to be dialed.
+func (c *crawler) storeENR(n, nn *enode.Node, err error, checked time.Time, attrs util.NodeAttrs) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	// Re-read the node, a worker may have updated it meanwhile.
+	node := c.output[n.ID()]
+	node.LastCheck = checked
+	obs := node.Observation(c.protocol)
 	if err != nil {
 		if node.Score == 0 {
 			// Node doesn't implement EIP-868.
+			if c.enrFallback && c.protocol == common.ProtocolV4 && n.TCP() != 0 {
+				if !c.allowedClient(attrs, n, node) {
+					delete(c.output, n.ID())
+					return false
+				}
+				log.Debug("Dialing node without ENR", "id", n.ID())
+				node.N = n
+				node.Seq = n.Seq()
+				node.ENRUnavailable = true
+				c.pending[n.ID()] = node
+				return true
+			}
 			log.Debug("Skipping node", "id", n.ID())
-			return
+			return false
 		}
 		node.Score /= 2
+		obs.Score /= 2
 	} else {
+		node.ENRUnavailable = false
+		node.ObservedAddr, _ = n.UDPEndpoint()
 		node.N = nn
 		node.Seq = nn.Seq()
 		node.Score++
@@ -235,24 +431,46 @@ func (c *crawler) updateNode(n *enode.Node) {
 			node.FirstResponse = node.LastCheck
 		}
 		node.LastResponse = node.LastCheck
+
+		obs.Score++
+		if obs.FirstResponse.IsZero() {
+			obs.FirstResponse = node.LastCheck
+		}
+		obs.LastResponse = node.LastCheck
 	}
+	node.SetObservation(c.protocol, obs)
 
 	// Store/update node in output set.
 	if node.Score <= 0 {
 		log.Info("Removing node", "id", n.ID())
 		delete(c.output, n.ID())
-	} else {
-		log.Info("Updating node", "id", n.ID(), "seq", n.Seq(), "score", node.Score)
-		c.reqCh <- n
-		c.output[n.ID()] = node
+		return false
 	}
+	if !c.allowedClient(attrs, node.N, node) {
+		delete(c.output, n.ID())
+		return false
+	}
+	log.Info("Updating node", "id", n.ID(), "seq", n.Seq(), "score", node.Score)
+	c.output[n.ID()] = node
+	return true
+}
+
+// enrUnavailable reports whether a node is dialed without its record.
+func (c *crawler) enrUnavailable(id enode.ID) bool {
+	c.RLock()
+	defer c.RUnlock()
+	if _, ok := c.pending[id]; ok {
+		return true
+	}
+	return c.output[id].ENRUnavailable
 }
 
 func (c Crawler) CrawlRound(
 	inputSet common.NodeSet,
 	db *sql.DB,
-	geoipProvider *util.GeoIP,
+	geoipProvider util.GeoProvider,
 ) common.NodeSet {
+	c.geo = geoipProvider
 	var v4, v5 common.NodeSet
 	var wg sync.WaitGroup
 
@@ -274,20 +492,27 @@ func (c Crawler) CrawlRound(
 
 	output := make(common.NodeSet, len(v5)+len(v4))
 	for _, n := range v5 {
-		output[n.N.ID()] = n
+		output[n.N.ID()] = n.ForProtocol(common.ProtocolV5)
 	}
 	for _, n := range v4 {
+		n = n.ForProtocol(common.ProtocolV4)
+		if prev, ok := output[n.N.ID()]; ok {
+			n = common.MergeNodes(prev, n)
+		}
 		output[n.N.ID()] = n
 	}
 
+	network := c.consensusNetwork()
 	var nodes []common.NodeJSON
-	for _, node := range output {
+	for id, node := range output {
+		node.Consensus = eth2.DecodeENR(node.N, network)
+		output[id] = node
 		nodes = append(nodes, node)
 	}
 
 	// Write the node info to influx
 	if db != nil {
-		if err := dbpkg.UpdateNodes(db, geoipProvider, nodes); err != nil {
+		if err := dbpkg.UpdateNodes(db, geoipProvider, c.Advisories, c.Hosting, c.Privacy, nodes); err != nil {
 			panic(err)
 		}
 	}
@@ -297,7 +522,7 @@ func (c Crawler) CrawlRound(
 func (c Crawler) discv5(inputSet common.NodeSet) common.NodeSet {
 	ln, config := c.makeDiscoveryConfig()
 
-	socket := listen(ln, c.ListenAddr)
+	socket := listen(ln, c.ListenAddr, c.DualStack)
 
 	disc, err := discover.ListenV5(socket, ln, config)
 	if err != nil {
@@ -305,13 +530,16 @@ func (c Crawler) discv5(inputSet common.NodeSet) common.NodeSet {
 	}
 	defer disc.Close()
 
-	return c.runCrawler(disc, inputSet)
+	if c.Mode == ModeTable {
+		return c.runCrawler(tableResolver{disc, v5Dumper{disc}}, common.ProtocolV5, inputSet)
+	}
+	return c.runCrawler(disc, common.ProtocolV5, inputSet)
 }
 
 func (c Crawler) discv4(inputSet common.NodeSet) common.NodeSet {
 	ln, config := c.makeDiscoveryConfig()
 
-	socket := listen(ln, c.ListenAddr)
+	socket := newNeighborsConn(listen(ln, c.ListenAddr, c.DualStack))
 
 	disc, err := discover.ListenV4(socket, ln, config)
 	if err != nil {
@@ -319,10 +547,13 @@ func (c Crawler) discv4(inputSet common.NodeSet) common.NodeSet {
 	}
 	defer disc.Close()
 
-	return c.runCrawler(disc, inputSet)
+	if c.Mode == ModeTable {
+		return c.runCrawler(tableResolver{disc, v4Dumper{socket, config.PrivateKey}}, common.ProtocolV4, inputSet)
+	}
+	return c.runCrawler(disc, common.ProtocolV4, inputSet)
 }
 
-func (c Crawler) runCrawler(disc resolver, inputSet common.NodeSet) common.NodeSet {
+func (c Crawler) runCrawler(disc resolver, protocol string, inputSet common.NodeSet) common.NodeSet {
 	genesis := c.makeGenesis()
 	if genesis == nil {
 		genesis = core.DefaultGenesisBlock()
@@ -330,6 +561,19 @@ func (c Crawler) runCrawler(disc resolver, inputSet common.NodeSet) common.NodeS
 
 	crawler := NewCrawler(genesis, c.NetworkID, c.NodeURL, inputSet, c.Workers, disc, disc.RandomNodes())
 	crawler.revalidateInterval = 10 * time.Minute
+	crawler.protocol = protocol
+	crawler.enrFallback = c.ENRFallback
+	crawler.preferIPv6 = c.PreferIPv6
+	crawler.blacklist = c.Blacklist
+	crawler.geo = c.geo
+	if c.BeaconProbe {
+		prober, err := eth2.NewProber(15 * time.Second)
+		if err != nil {
+			panic(err)
+		}
+		defer prober.Close()
+		crawler.prober = prober
+	}
 	return crawler.Run(c.Timeout)
 }
 
@@ -345,3 +589,16 @@ func (c Crawler) makeGenesis() *core.Genesis {
 
 	return core.DefaultGenesisBlock()
 }
+
+// consensusNetwork returns the consensus-layer network matching the genesis,
+// used to resolve fork digests to fork names.
+func (c Crawler) consensusNetwork() *eth2.Network {
+	if c.Sepolia {
+		return eth2.Sepolia
+	}
+	if c.Hoodi {
+		return eth2.Hoodi
+	}
+
+	return eth2.Mainnet
+}
@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/200ug/peerlogger/internal/common"
+)
+
+func TestUpdateNodeENRFallback(t *testing.T) {
+	n := testNode(t, "192.0.2.1")
+	disc := &stubResolver{} // every ENR request fails
+	c := &crawler{
+		output:      make(common.NodeSet),
+		pending:     make(map[enode.ID]common.NodeJSON),
+		disc:        disc,
+		protocol:    common.ProtocolV4,
+		reqCh:       make(chan *enode.Node, 4),
+		enrFallback: true,
+	}
+
+	dialed := func() int {
+		var count int
+		for len(c.reqCh) > 0 {
+			if got := <-c.reqCh; got.ID() != n.ID() {
+				t.Errorf("Dialed unexpected node %v", got.ID())
+			}
+			count++
+		}
+		return count
+	}
+
+	// The node is dialed, but not part of the output before the handshake.
+	c.updateNode(n)
+	if _, ok := c.output[n.ID()]; ok {
+		t.Error("Expected the node not to be in the output before its handshake")
+	}
+	c.updateNode(n)
+	if got := dialed(); got != 1 {
+		t.Errorf("Expected a single dial while the handshake is pending, got %d", got)
+	}
+
+	// A failed handshake drops it.
+	c.storeClientInfo(n, clientInfo{})
+	if _, ok := c.output[n.ID()]; ok {
+		t.Error("Expected the node not to be in the output after a failed handshake")
+	}
+	if len(c.pending) != 0 {
+		t.Errorf("Expected no pending handshakes, got %d", len(c.pending))
+	}
+
+	// A successful one adds it.
+	c.updateNode(n)
+	if got := dialed(); got != 1 {
+		t.Errorf("Expected the node to be dialed again, got %d dials", got)
+	}
+	c.storeClientInfo(n, clientInfo{info: &common.ClientInfo{ClientType: "Geth/v1.16.2-stable"}, scoreInc: 10})
+	node, ok := c.output[n.ID()]
+	if !ok {
+		t.Fatal("Expected the node in the output after a successful handshake")
+	}
+	if !node.ENRUnavailable || node.Score != 10 || node.LastResponse.IsZero() {
+		t.Errorf("Unexpected node %+v", node)
+	}
+	if disc.requests != 2 {
+		t.Errorf("Expected an ENR request per round, got %d", disc.requests)
+	}
+}
+
+func TestUpdateNodeUnlocked(t *testing.T) {
+	n := testNode(t, "192.0.2.1")
+	disc := &stubResolver{records: map[enode.ID]*enode.Node{n.ID(): n}}
+	c := &crawler{
+		output:   make(common.NodeSet),
+		pending:  make(map[enode.ID]common.NodeJSON),
+		disc:     disc,
+		protocol: common.ProtocolV4,
+		reqCh:    make(chan *enode.Node), // a full dial queue
+	}
+	disc.onRequest = func() {
+		if !c.TryLock() {
+			t.Error("Expected the lock to be released during the ENR request")
+			return
+		}
+		c.Unlock()
+	}
+
+	// A worker storing its result before taking the next node must not
+	// block the dial request.
+	other := testNode(t, "192.0.2.2")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.storeClientInfo(other, clientInfo{scoreInc: 10})
+		<-c.reqCh
+	}()
+	c.updateNode(n)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("updateNode blocked the workers")
+	}
+	if _, ok := c.output[n.ID()]; !ok {
+		t.Error("Expected the node in the output")
+	}
+}
+
+func TestENRUnavailable(t *testing.T) {
+	pending, known, plain := testNode(t, "192.0.2.1"), testNode(t, "192.0.2.2"), testNode(t, "192.0.2.3")
+	c := &crawler{
+		output: common.NodeSet{
+			known.ID(): {N: known, ENRUnavailable: true},
+			plain.ID(): {N: plain},
+		},
+		pending: map[enode.ID]common.NodeJSON{pending.ID(): {N: pending, ENRUnavailable: true}},
+	}
+	// table dumps are skipped for nodes without an endpoint proof
+	for n, want := range map[*enode.Node]bool{pending: true, known: true, plain: false} {
+		if got := c.enrUnavailable(n.ID()); got != want {
+			t.Errorf("enrUnavailable(%v) = %v, want %v", n.ID(), got, want)
+		}
+	}
+}
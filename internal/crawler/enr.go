@@ -4,15 +4,132 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
 	"net"
 	"strconv"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
 )
 
+// RecordDump is the decoded form of a node record.
+type RecordDump struct {
+	ID  string `json:"id"`
+	Seq uint64 `json:"seq"`
+	// "valid", "none" for unsigned records (enode URLs) or the verification error
+	Signature string       `json:"signature"`
+	ENR       string       `json:"enr,omitempty"`
+	URLv4     string       `json:"enode,omitempty"`
+	Pairs     []RecordPair `json:"pairs"`
+}
+
+// RecordPair is a key/value pair of a node record. Value holds the formatted
+// value for well-known keys and the hex encoded RLP otherwise.
+type RecordPair struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Raw   string `json:"raw"`
+	// false if the value couldn't be decoded with the formatter for its key
+	Valid bool `json:"valid"`
+}
+
+// DecodeRecord parses a record in any of the formats accepted by parseNode
+// (enr:, enode://, hex, base64) and decodes its key/value pairs.
+func DecodeRecord(source string) (*RecordDump, error) {
+	source = strings.TrimSpace(source)
+	if strings.HasPrefix(source, "enode://") {
+		n, err := enode.ParseV4(source)
+		if err != nil {
+			return nil, err
+		}
+		return DumpNode(n), nil
+	}
+	r, err := parseRecord(source)
+	if err != nil {
+		return nil, err
+	}
+
+	d := dumpRecord(r)
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		d.Signature = err.Error()
+		return d, nil
+	}
+	d.ID, d.Signature, d.ENR = n.ID().String(), "valid", n.String()
+	if n.Pubkey() != nil {
+		d.URLv4 = n.URLv4()
+	}
+	return d, nil
+}
+
+// DumpNode decodes the record of an already verified or unsigned node.
+func DumpNode(n *enode.Node) *RecordDump {
+	d := dumpRecord(n.Record())
+	d.ID = n.ID().String()
+	if len(n.Record().Signature()) == 0 {
+		d.Signature = "none"
+	} else {
+		d.Signature, d.ENR = "valid", n.String()
+	}
+	if n.Pubkey() != nil {
+		d.URLv4 = n.URLv4()
+	}
+	return d
+}
+
+func dumpRecord(r *enr.Record) *RecordDump {
+	d := &RecordDump{Seq: r.Seq()}
+	kv := r.AppendElements(nil)[1:]
+	for i := 0; i < len(kv); i += 2 {
+		key := kv[i].(string)
+		val := kv[i+1].(rlp.RawValue)
+		formatter := attrFormatters[key]
+		if formatter == nil {
+			formatter = formatAttrRaw
+		}
+		fmtval, ok := formatter(val)
+		if !ok {
+			fmtval = hex.EncodeToString(val)
+		}
+		d.Pairs = append(d.Pairs, RecordPair{Key: key, Value: fmtval, Raw: hex.EncodeToString(val), Valid: ok})
+	}
+	return d
+}
+
+// WriteText pretty-prints the record, aligned like `devp2p enrdump`.
+func (d *RecordDump) WriteText(out io.Writer) {
+	if d.ID != "" {
+		fmt.Fprintf(out, "Node ID:   %s\n", d.ID)
+	}
+	fmt.Fprintf(out, "Signature: %s\n", d.Signature)
+	if d.URLv4 != "" {
+		fmt.Fprintf(out, "URLv4:     %s\n", d.URLv4)
+	}
+	if d.ENR != "" {
+		fmt.Fprintf(out, "ENR:       %s\n", d.ENR)
+	}
+	fmt.Fprintf(out, "Record has sequence number %d and %d key/value pairs.\n", d.Seq, len(d.Pairs))
+
+	var longestKey int
+	for _, p := range d.Pairs {
+		longestKey = max(longestKey, len(p.Key))
+	}
+	for _, p := range d.Pairs {
+		fmt.Fprintf(out, "  %s%s%s", strconv.Quote(p.Key), strings.Repeat(" ", longestKey-len(p.Key)+1), p.Value)
+		if !p.Valid {
+			fmt.Fprint(out, " (!)")
+		}
+		fmt.Fprintln(out)
+	}
+}
+
 // parseNode parses a node record and verifies its signature.
 func parseNode(source string) (*enode.Node, error) {
 	if strings.HasPrefix(source, "enode://") {
@@ -58,13 +175,25 @@ func decodeRecordBase64(b []byte) ([]byte, bool) {
 
 // attrFormatters contains formatting functions for well-known ENR keys.
 var attrFormatters = map[string]func(rlp.RawValue) (string, bool){
-	"id":   formatAttrString,
-	"ip":   formatAttrIP,
-	"ip6":  formatAttrIP,
-	"tcp":  formatAttrUint,
-	"tcp6": formatAttrUint,
-	"udp":  formatAttrUint,
-	"udp6": formatAttrUint,
+	"id":        formatAttrString,
+	"ip":        formatAttrIP,
+	"ip6":       formatAttrIP,
+	"tcp":       formatAttrUint,
+	"tcp6":      formatAttrUint,
+	"udp":       formatAttrUint,
+	"udp6":      formatAttrUint,
+	"secp256k1": formatAttrBytes,
+	"quic":      formatAttrUint,
+	"quic6":     formatAttrUint,
+	"eth":       formatAttrEth,
+	"snap":      formatAttrSnap,
+	"les":       formatAttrLes,
+	"client":    formatAttrClient,
+	"eth2":      formatAttrEth2,
+	"attnets":   formatAttrBitvector,
+	"syncnets":  formatAttrBitvector,
+	"cgc":       formatAttrUint,
+	"nfd":       formatAttrBytes,
 }
 
 func formatAttrRaw(v rlp.RawValue) (string, bool) {
@@ -79,12 +208,85 @@ func formatAttrString(v rlp.RawValue) (string, bool) {
 
 func formatAttrIP(v rlp.RawValue) (string, bool) {
 	content, _, err := rlp.SplitString(v)
-	if err != nil || len(content) != 4 && len(content) != 6 {
+	if err != nil || len(content) != 4 && len(content) != 16 {
 		return "", false
 	}
 	return net.IP(content).String(), true
 }
 
+func formatAttrBytes(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	return "0x" + hex.EncodeToString(content), err == nil
+}
+
+// formatAttrEth formats the eth entry: [[fork hash, fork next], ...].
+func formatAttrEth(v rlp.RawValue) (string, bool) {
+	var entry struct {
+		ForkID forkid.ID
+		Rest   []rlp.RawValue `rlp:"tail"`
+	}
+	if err := rlp.DecodeBytes(v, &entry); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("forkid hash=0x%x next=%d", entry.ForkID.Hash, entry.ForkID.Next), true
+}
+
+// formatAttrSnap formats the snap entry, which is an empty list.
+func formatAttrSnap(v rlp.RawValue) (string, bool) {
+	var entry struct {
+		Rest []rlp.RawValue `rlp:"tail"`
+	}
+	return "supported", rlp.DecodeBytes(v, &entry) == nil
+}
+
+// formatAttrLes formats the les entry: [vflux version].
+func formatAttrLes(v rlp.RawValue) (string, bool) {
+	var entry struct {
+		VfxVersion uint
+		Rest       []rlp.RawValue `rlp:"tail"`
+	}
+	if err := rlp.DecodeBytes(v, &entry); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("vflux=%d", entry.VfxVersion), true
+}
+
+// formatAttrClient formats the EIP-7636 client entry: [name, version, build].
+func formatAttrClient(v rlp.RawValue) (string, bool) {
+	var fields []string
+	if err := rlp.DecodeBytes(v, &fields); err != nil || len(fields) == 0 {
+		return "", false
+	}
+	return strconv.Quote(strings.Join(fields, "/")), true
+}
+
+// formatAttrEth2 formats the SSZ encoded eth2 entry (ENRForkID).
+func formatAttrEth2(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil {
+		return "", false
+	}
+	var dat beacon.Eth2Data
+	if err := dat.Deserialize(codec.NewDecodingReader(bytes.NewReader(content), uint64(len(content)))); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("fork_digest=%s next_fork_version=%s next_fork_epoch=%d",
+		dat.ForkDigest, dat.NextForkVersion, dat.NextForkEpoch), true
+}
+
+// formatAttrBitvector formats the attnets and syncnets subnet bitvectors.
+func formatAttrBitvector(v rlp.RawValue) (string, bool) {
+	content, _, err := rlp.SplitString(v)
+	if err != nil {
+		return "", false
+	}
+	var count int
+	for _, b := range content {
+		count += bits.OnesCount8(b)
+	}
+	return fmt.Sprintf("0x%x (%d subnets)", content, count), true
+}
+
 func formatAttrUint(v rlp.RawValue) (string, bool) {
 	var x uint64
 	if err := rlp.DecodeBytes(v, &x); err != nil {
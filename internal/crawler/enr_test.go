@@ -0,0 +1,101 @@
+package crawler_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/200ug/peerlogger/internal/crawler"
+)
+
+func TestDecodeRecord(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var r enr.Record
+	r.Set(enr.WithEntry("eth", struct {
+		ForkID forkid.ID
+		Rest   []rlp.RawValue `rlp:"tail"`
+	}{ForkID: forkid.ID{Hash: [4]byte{0xfc, 0x64, 0xec, 0x04}, Next: 1150000}}))
+	r.Set(enr.WithEntry("snap", []rlp.RawValue{}))
+	r.Set(enr.WithEntry("client", []string{"Geth", "1.16.2", "a1b2c3"}))
+	r.Set(enr.WithEntry("attnets", []byte{0x03, 0, 0, 0, 0, 0, 0, 0x01}))
+	r.Set(enr.WithEntry("eth2", []byte{0x00}))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	dump, err := crawler.DecodeRecord(n.String())
+	if err != nil {
+		t.Fatalf("DecodeRecord failed: %v", err)
+	}
+	if dump.Signature != "valid" || dump.ID != n.ID().String() {
+		t.Errorf("Expected valid signature for %s, got %q for %s", n.ID(), dump.Signature, dump.ID)
+	}
+
+	values := make(map[string]crawler.RecordPair)
+	for _, p := range dump.Pairs {
+		values[p.Key] = p
+	}
+	tests := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		{"eth", "forkid hash=0xfc64ec04 next=1150000", true},
+		{"snap", "supported", true},
+		{"client", `"Geth/1.16.2/a1b2c3"`, true},
+		{"attnets", "0x0300000000000001 (3 subnets)", true},
+		{"eth2", "00", false},
+	}
+	for _, tt := range tests {
+		p := values[tt.key]
+		if p.Value != tt.value || p.Valid != tt.valid {
+			t.Errorf("%s: expected %q (valid %v), got %q (valid %v)", tt.key, tt.value, tt.valid, p.Value, p.Valid)
+		}
+	}
+
+	var out bytes.Buffer
+	dump.WriteText(&out)
+	if !strings.Contains(out.String(), `"eth2"      00 (!)`) {
+		t.Errorf("Expected undecodable eth2 value to be flagged, got:\n%s", out.String())
+	}
+}
+
+func TestDecodeRecordInvalidSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	var r enr.Record
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	enc, err := rlp.EncodeToBytes(&r)
+	if err != nil {
+		t.Fatalf("Failed to encode record: %v", err)
+	}
+	// Flip a bit of the signature.
+	enc[bytes.Index(enc, r.Signature())] ^= 0x01
+
+	dump, err := crawler.DecodeRecord(hex.EncodeToString(enc))
+	if err != nil {
+		t.Fatalf("DecodeRecord failed: %v", err)
+	}
+	if dump.Signature == "valid" || dump.ENR != "" {
+		t.Errorf("Expected invalid signature, got %q", dump.Signature)
+	}
+}
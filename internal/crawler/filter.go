@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"net/netip"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// allowed checks the IP, node ID, ASN and country of a node before it is
+// contacted. The rules are checked with the client name, network ID and ENR
+// keys unknown; the returned attributes complete them in allowedClient.
+func (c *crawler) allowed(n *enode.Node) (util.NodeAttrs, bool) {
+	endpoints := common.NodeEndpoints(n)
+	attrs := util.NodeAttrs{ID: n.ID(), IPs: []netip.Addr{endpoints.IP, endpoints.IP6}}
+	if c.blacklist == nil {
+		return attrs, true
+	}
+
+	ip := endpoints.IP
+	if !ip.IsValid() {
+		ip = endpoints.IP6
+	}
+	if c.geo != nil && ip.IsValid() {
+		if geo, err := c.geo.Lookup(ip); err == nil && geo != nil {
+			if geo.ASNumber != nil {
+				attrs.ASN = *geo.ASNumber
+			}
+			if geo.CountryCode != nil {
+				attrs.Country = *geo.CountryCode
+			}
+		}
+	}
+
+	ok, reason := c.blacklist.Check(attrs)
+	if !ok {
+		log.Debug("Skipping blacklisted node", "id", n.ID(), "reason", reason)
+	}
+	return attrs, ok
+}
+
+// allowedClient checks the rules once the record is known. The client name
+// and network ID are known from the handshake of an earlier round, if any.
+func (c *crawler) allowedClient(attrs util.NodeAttrs, record *enode.Node, node common.NodeJSON) bool {
+	if c.blacklist == nil {
+		return true
+	}
+	attrs.Node = record
+	if node.Info != nil {
+		attrs.NetworkID = node.Info.NetworkID
+		if t := node.Info.ClientType; t != "tmp" && t != "eth2" {
+			attrs.Client = t
+		}
+	}
+	if attrs.Client == "" && node.Beacon != nil {
+		attrs.Client = node.Beacon.AgentVersion
+	}
+
+	ok, reason := c.blacklist.CheckRules(attrs)
+	if !ok {
+		log.Debug("Skipping blacklisted node", "id", record.ID(), "reason", reason)
+	}
+	return ok
+}
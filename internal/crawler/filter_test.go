@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// staticGeo resolves every IP to the same ASN and country.
+type staticGeo struct {
+	asn     int64
+	country string
+}
+
+func (g staticGeo) Lookup(netip.Addr) (*util.GeoData, error) {
+	return &util.GeoData{ASNumber: &g.asn, CountryCode: &g.country}, nil
+}
+
+func (staticGeo) Close() error { return nil }
+
+// stubResolver answers ENR requests with the record it was created with, or
+// fails them if it is nil.
+type stubResolver struct {
+	records   map[enode.ID]*enode.Node
+	requests  int
+	onRequest func()
+}
+
+func (r *stubResolver) RequestENR(n *enode.Node) (*enode.Node, error) {
+	r.requests++
+	if r.onRequest != nil {
+		r.onRequest()
+	}
+	if nn, ok := r.records[n.ID()]; ok {
+		return nn, nil
+	}
+	return nil, errors.New("timeout")
+}
+
+func (r *stubResolver) RandomNodes() enode.Iterator {
+	return enode.IterNodes(nil)
+}
+
+// testNode returns a signed record of a new key with the given entries.
+func testNode(t *testing.T, ip string, entries ...enr.Entry) *enode.Node {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	var r enr.Record
+	r.Set(enr.IPv4(net.ParseIP(ip)))
+	r.Set(enr.UDP(30303))
+	r.Set(enr.TCP(30303))
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	return n
+}
+
+func testBlacklist(t *testing.T, ips, pubkeys []string, rules util.Rules) *util.Blacklist {
+	t.Helper()
+	bl := util.NewBlacklist(ips, pubkeys)
+	if err := bl.SetRules(rules); err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+	return bl
+}
+
+func TestAllowed(t *testing.T) {
+	banned := testNode(t, "192.0.2.10")
+	bl := testBlacklist(t, []string{"203.0.113.0/24"}, []string{banned.ID().String()}, util.Rules{
+		Exclude: []*util.Rule{
+			{Name: "honeypots", ASNs: []int64{64496}, ENRKeys: []string{"hp"}},
+			{Name: "erigon", Client: "^erigon/"},
+			{Name: "testnet", NetworkIDs: []uint64{11155111}},
+		},
+		Include: []*util.Rule{{Countries: []string{"DE", "FR"}}},
+	})
+	c := &crawler{blacklist: bl, geo: staticGeo{asn: 64496, country: "DE"}}
+
+	geth := common.NodeJSON{Info: &common.ClientInfo{ClientType: "Geth/v1.16.2-stable/linux-amd64/go1.24.5", NetworkID: 1}}
+	tests := []struct {
+		name    string
+		node    *enode.Node
+		geo     util.GeoProvider
+		info    common.NodeJSON
+		allowed bool // before the ENR request
+		client  bool // after it
+	}{
+		{name: "plain", node: testNode(t, "192.0.2.1"), info: geth, allowed: true, client: true},
+		{name: "blacklisted ip", node: testNode(t, "203.0.113.7")},
+		{name: "blacklisted node", node: banned},
+		{name: "other country", node: testNode(t, "192.0.2.1"), geo: staticGeo{asn: 64496, country: "US"}},
+		{name: "unknown country", node: testNode(t, "192.0.2.1"), geo: staticGeo{asn: 64496}, allowed: true, client: true},
+		{name: "honeypot", node: testNode(t, "192.0.2.1", enr.WithEntry("hp", uint(1))), allowed: true},
+		{name: "honeypot key elsewhere", node: testNode(t, "192.0.2.1", enr.WithEntry("hp", uint(1))),
+			geo: staticGeo{asn: 64497, country: "DE"}, allowed: true, client: true},
+		{name: "client", node: testNode(t, "192.0.2.1"), allowed: true,
+			info: common.NodeJSON{Info: &common.ClientInfo{ClientType: "erigon/v2.60.10-125509e4/linux-amd64/go1.21.13"}}},
+		{name: "network", node: testNode(t, "192.0.2.1"), allowed: true,
+			info: common.NodeJSON{Info: &common.ClientInfo{ClientType: "Geth/v1.16.2-stable", NetworkID: 11155111}}},
+		{name: "beacon agent", node: testNode(t, "192.0.2.1"), allowed: true,
+			info: common.NodeJSON{Beacon: &common.BeaconInfo{AgentVersion: "erigon/v3.0.0"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.geo = staticGeo{asn: 64496, country: "DE"}
+			if tt.geo != nil {
+				c.geo = tt.geo
+			}
+			attrs, ok := c.allowed(tt.node)
+			if ok != tt.allowed {
+				t.Fatalf("allowed = %v, want %v", ok, tt.allowed)
+			}
+			if !ok {
+				return
+			}
+			if got := c.allowedClient(attrs, tt.node, tt.info); got != tt.client {
+				t.Errorf("allowedClient = %v, want %v", got, tt.client)
+			}
+		})
+	}
+
+	// Without a blacklist every node is allowed.
+	c = &crawler{}
+	if _, ok := c.allowed(testNode(t, "203.0.113.7")); !ok {
+		t.Error("Expected nodes to be allowed without a blacklist")
+	}
+}
+
+func TestUpdateNodeBlacklisted(t *testing.T) {
+	blocked := testNode(t, "203.0.113.7")
+	erigon := testNode(t, "192.0.2.2")
+	geth := testNode(t, "192.0.2.3")
+	disc := &stubResolver{records: map[enode.ID]*enode.Node{
+		blocked.ID(): blocked,
+		erigon.ID():  erigon,
+		geth.ID():    geth,
+	}}
+	c := &crawler{
+		output: common.NodeSet{
+			blocked.ID(): {N: blocked, Score: 5},
+			erigon.ID(): {N: erigon, Score: 5,
+				Info: &common.ClientInfo{ClientType: "erigon/v2.60.10-125509e4/linux-amd64/go1.21.13"}},
+		},
+		disc:      disc,
+		protocol:  common.ProtocolV4,
+		reqCh:     make(chan *enode.Node, 4),
+		blacklist: testBlacklist(t, []string{"203.0.113.0/24"}, nil, util.Rules{Exclude: []*util.Rule{{Client: "^erigon/"}}}),
+	}
+
+	// A blacklisted endpoint is dropped without being contacted.
+	c.updateNode(blocked)
+	if _, ok := c.output[blocked.ID()]; ok {
+		t.Error("Expected the blacklisted node to be removed from the output")
+	}
+	if disc.requests != 0 {
+		t.Errorf("Expected no ENR request to a blacklisted IP, got %d", disc.requests)
+	}
+
+	// A blacklisted client is dropped once the record is fetched.
+	c.updateNode(erigon)
+	if _, ok := c.output[erigon.ID()]; ok {
+		t.Error("Expected the blacklisted client to be removed from the output")
+	}
+
+	c.updateNode(geth)
+	if _, ok := c.output[geth.ID()]; !ok {
+		t.Error("Expected the allowed node in the output")
+	}
+	select {
+	case n := <-c.reqCh:
+		if n.ID() != geth.ID() {
+			t.Errorf("Expected only the allowed node to be dialed, got %v", n.ID())
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the allowed node to be dialed")
+	}
+	if len(c.reqCh) != 0 {
+		t.Errorf("Expected a single dial, got %d more", len(c.reqCh))
+	}
+}
@@ -1,11 +1,13 @@
 package crawler
 
 import (
+	"cmp"
 	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/ethereum/go-ethereum/core"
@@ -26,14 +28,15 @@ var (
 	lastStatusUpdate time.Time
 )
 
-func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *enode.Node) (*common.ClientInfo, error) {
+func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *enode.Node, preferIPv6 bool) (*common.ClientInfo, error) {
 	var info common.ClientInfo
 
-	conn, sk, err := dial(n)
+	conn, sk, err := dial(n, preferIPv6)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
+	info.DialAddr = conn.remoteAddr
 
 	if err = conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
 		return nil, fmt.Errorf("cannot set conn deadline: %w", err)
@@ -73,13 +76,45 @@ func getClientInfo(genesis *core.Genesis, networkID uint64, nodeURL string, n *e
 	return &info, nil
 }
 
-// dial attempts to dial the given node and perform a handshake,
-func dial(n *enode.Node) (*Conn, *ecdsa.PrivateKey, error) {
+// dialAddrs returns the TCP endpoints advertised by n in the order they
+// should be tried. IPv6 uses the tcp6 port, or tcp if the record has none.
+func dialAddrs(n *enode.Node, preferIPv6 bool) []netip.AddrPort {
+	e := common.NodeEndpoints(n)
+
+	var v4, v6 []netip.AddrPort
+	if e.IP.IsValid() && e.TCP != 0 {
+		v4 = append(v4, netip.AddrPortFrom(e.IP, e.TCP))
+	}
+	if port := cmp.Or(e.TCP6, e.TCP); e.IP6.IsValid() && port != 0 {
+		v6 = append(v6, netip.AddrPortFrom(e.IP6, port))
+	}
+	if preferIPv6 {
+		return append(v6, v4...)
+	}
+	return append(v4, v6...)
+}
+
+// dial attempts to dial the given node and perform a handshake, trying each
+// advertised address family until a TCP connection succeeds.
+func dial(n *enode.Node, preferIPv6 bool) (*Conn, *ecdsa.PrivateKey, error) {
 	var conn Conn
 
 	// dial
+	addrs := dialAddrs(n, preferIPv6)
+	if len(addrs) == 0 {
+		return nil, nil, fmt.Errorf("no TCP endpoint in record")
+	}
 	dialer := net.Dialer{Timeout: 10 * time.Second}
-	fd, err := dialer.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
+	var (
+		fd  net.Conn
+		err error
+	)
+	for _, addr := range addrs {
+		if fd, err = dialer.Dial("tcp", addr.String()); err == nil {
+			conn.remoteAddr = addr
+			break
+		}
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -32,8 +32,14 @@ func (c Crawler) makeDiscoveryConfig() (*enode.LocalNode, discover.Config) {
 	return enode.NewLocalNode(c.NodeDB, cfg.PrivateKey), cfg
 }
 
-func listen(ln *enode.LocalNode, addr string) *net.UDPConn {
-	socket, err := net.ListenPacket("udp4", addr)
+// listen opens the discovery socket. A dual-stack socket accepts both IPv4
+// and IPv6 traffic when addr has an unspecified host.
+func listen(ln *enode.LocalNode, addr string, dualStack bool) *net.UDPConn {
+	network := "udp4"
+	if dualStack {
+		network = "udp"
+	}
+	socket, err := net.ListenPacket(network, addr)
 	if err != nil {
 		panic(err)
 	}
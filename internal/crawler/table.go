@@ -0,0 +1,359 @@
+package crawler
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// tableBuckets is the number of log-distance buckets queried per node. This
+	// matches the geth table layout (17 buckets), everything closer than the
+	// last bucket is practically never populated on a real network.
+	tableBuckets = 17
+
+	// neighborsTimeout is how long we wait for NEIGHBORS replies to a v4 FINDNODE.
+	neighborsTimeout = 500 * time.Millisecond
+
+	// bucketSize is the maximum number of entries per bucket and FINDNODE response.
+	bucketSize = 16
+
+	// tableDumpTimeout bounds a routing table dump, which runs on the dial
+	// workers. Buckets not queried by then are skipped.
+	tableDumpTimeout = 3 * time.Second
+)
+
+var (
+	errFindnodePending = errors.New("findnode already pending for node")
+	errNoNeighbors     = errors.New("no FINDNODE responses")
+)
+
+// tableDumper reconstructs the routing table of a remote node by querying
+// every log-distance bucket with FINDNODE.
+type tableDumper interface {
+	DumpTable(n *enode.Node) ([]*enode.Node, error)
+}
+
+// tableResolver is a resolver which can also dump remote routing tables.
+type tableResolver struct {
+	resolver
+	tableDumper
+}
+
+// v5Dumper dumps routing tables over discv5, which supports FINDNODE by distance.
+type v5Dumper struct {
+	disc *discover.UDPv5
+}
+
+func (d v5Dumper) DumpTable(n *enode.Node) ([]*enode.Node, error) {
+	var (
+		result []*enode.Node
+		seen   = make(map[enode.ID]struct{})
+		failed int
+	)
+	collect := func(distances []uint) {
+		nodes, err := d.disc.Findnode(n, distances)
+		if err != nil {
+			failed++
+		}
+		for _, nb := range nodes {
+			if _, ok := seen[nb.ID()]; !ok {
+				seen[nb.ID()] = struct{}{}
+				result = append(result, nb)
+			}
+		}
+	}
+
+	// Full buckets only fit into a single response when queried one at a time.
+	deadline := time.Now().Add(tableDumpTimeout)
+	for dist := 256; dist > 256-tableBuckets; dist-- {
+		if time.Now().After(deadline) {
+			failed += dist - (256 - tableBuckets)
+			break
+		}
+		collect([]uint{uint(dist)})
+	}
+	// Catch-all for anything closer, the response is capped at one bucket.
+	lower := make([]uint, 0, 256-tableBuckets)
+	for dist := 256 - tableBuckets; dist > 0; dist-- {
+		lower = append(lower, uint(dist))
+	}
+	collect(lower)
+
+	if failed == tableBuckets+1 {
+		return nil, errNoNeighbors
+	}
+	return result, nil
+}
+
+// v4Dumper dumps routing tables over discv4. FINDNODE/v4 only takes a target
+// key, so we look up generated targets that land in each bucket of the remote
+// node and collect the NEIGHBORS replies from the shared socket.
+type v4Dumper struct {
+	conn *neighborsConn
+	key  *ecdsa.PrivateKey
+}
+
+func (d v4Dumper) DumpTable(n *enode.Node) ([]*enode.Node, error) {
+	addr, ok := n.UDPEndpoint()
+	if !ok {
+		return nil, errors.New("node has no UDP endpoint")
+	}
+
+	var (
+		result   []*enode.Node
+		seen     = make(map[enode.ID]struct{})
+		failed   int
+		deadline = time.Now().Add(tableDumpTimeout)
+	)
+	for dist := 256; dist > 256-tableBuckets; dist-- {
+		if time.Now().After(deadline) {
+			failed += dist - (256 - tableBuckets)
+			break
+		}
+		nodes, err := d.findnode(n.ID(), addr, v4Target(n.ID(), dist))
+		if err != nil {
+			failed++
+		}
+		for _, nb := range nodes {
+			if _, ok := seen[nb.ID()]; !ok {
+				seen[nb.ID()] = struct{}{}
+				result = append(result, nb)
+			}
+		}
+	}
+
+	if failed == tableBuckets {
+		return nil, errNoNeighbors
+	}
+	return result, nil
+}
+
+// findnode sends a single FINDNODE/v4 request and waits for up to one bucket
+// of NEIGHBORS entries. The remote end must already have an endpoint proof for
+// us, which is the case after a successful RequestENR.
+func (d v4Dumper) findnode(id enode.ID, addr netip.AddrPort, target v4wire.Pubkey) ([]*enode.Node, error) {
+	replies, err := d.conn.expect(id)
+	if err != nil {
+		return nil, err
+	}
+	defer d.conn.done(id)
+
+	packet, _, err := v4wire.Encode(d.key, &v4wire.Findnode{
+		Target:     target,
+		Expiration: uint64(time.Now().Add(20 * time.Second).Unix()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.conn.WriteToUDPAddrPort(packet, addr); err != nil {
+		return nil, err
+	}
+
+	var (
+		nodes    []*enode.Node
+		received int
+		timeout  = time.NewTimer(neighborsTimeout)
+	)
+	defer timeout.Stop()
+	for received < bucketSize {
+		select {
+		case reply := <-replies:
+			for _, rn := range reply.Nodes {
+				received++
+				key, err := v4wire.DecodePubkey(crypto.S256(), rn.ID)
+				if err != nil {
+					continue
+				}
+				nodes = append(nodes, enode.NewV4(key, rn.IP, int(rn.TCP), int(rn.UDP)))
+			}
+		case <-timeout.C:
+			if received == 0 {
+				return nil, errors.New("FINDNODE/v4 timeout")
+			}
+			return nodes, nil
+		}
+	}
+	return nodes, nil
+}
+
+// v4TargetBits is the hash prefix length of the precomputed FINDNODE/v4
+// targets. The first difference of a target at the queried distances lies
+// within the first tableBuckets bits.
+const v4TargetBits = tableBuckets
+
+var (
+	v4TargetsOnce sync.Once
+	v4Targets     []uint32 // hash prefix -> counter of a target with it
+)
+
+// v4Target returns a FINDNODE/v4 target whose hash lies at the given
+// log-distance from id, which must be one of the queried buckets. The target
+// does not need to be a valid curve point, remote nodes only ever hash it.
+func v4Target(id enode.ID, dist int) v4wire.Pubkey {
+	// Keep the bits of id before the first difference, flip the bit at
+	// it. The bits after it don't change the distance.
+	prefix := binary.BigEndian.Uint32(id[:4]) >> (32 - v4TargetBits)
+	prefix ^= 1 << (v4TargetBits - 1 - (256 - dist))
+	return counterTarget(v4TargetTable()[prefix])
+}
+
+// v4TargetTable finds a target for every hash prefix of v4TargetBits bits on
+// first use. Targets hold a counter in their first bytes, so only the counter
+// is kept.
+func v4TargetTable() []uint32 {
+	v4TargetsOnce.Do(func() {
+		var (
+			table   = make([]uint32, 1<<v4TargetBits)
+			found   = make([]bool, len(table))
+			missing = len(table)
+			hasher  = crypto.NewKeccakState()
+			hash    [32]byte
+		)
+		for i := uint32(0); missing > 0; i++ {
+			target := counterTarget(i)
+			hasher.Reset()
+			hasher.Write(target[:])
+			hasher.Read(hash[:])
+			prefix := binary.BigEndian.Uint32(hash[:4]) >> (32 - v4TargetBits)
+			if !found[prefix] {
+				found[prefix] = true
+				table[prefix] = i
+				missing--
+			}
+		}
+		v4Targets = table
+	})
+	return v4Targets
+}
+
+func counterTarget(i uint32) v4wire.Pubkey {
+	var target v4wire.Pubkey
+	binary.BigEndian.PutUint32(target[:], i)
+	return target
+}
+
+// neighborsConn wraps the discv4 socket and hands NEIGHBORS packets from nodes
+// with a pending FINDNODE to the waiting dumper. The packets are still passed
+// on to discv4, which discards them as unsolicited.
+type neighborsConn struct {
+	*net.UDPConn
+
+	mu      sync.Mutex
+	pending map[enode.ID]chan *v4wire.Neighbors
+}
+
+func newNeighborsConn(conn *net.UDPConn) *neighborsConn {
+	return &neighborsConn{
+		UDPConn: conn,
+		pending: make(map[enode.ID]chan *v4wire.Neighbors),
+	}
+}
+
+func (c *neighborsConn) ReadFromUDPAddrPort(b []byte) (int, netip.AddrPort, error) {
+	n, addr, err := c.UDPConn.ReadFromUDPAddrPort(b)
+	if err != nil {
+		return n, addr, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return n, addr, err
+	}
+	packet, fromKey, _, decErr := v4wire.Decode(b[:n])
+	if decErr != nil {
+		return n, addr, err
+	}
+	if neighbors, ok := packet.(*v4wire.Neighbors); ok {
+		if ch, ok := c.pending[fromKey.ID()]; ok {
+			select {
+			case ch <- neighbors:
+			default:
+			}
+		}
+	}
+	return n, addr, err
+}
+
+func (c *neighborsConn) expect(id enode.ID) (<-chan *v4wire.Neighbors, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.pending[id]; ok {
+		return nil, errFindnodePending
+	}
+	ch := make(chan *v4wire.Neighbors, bucketSize)
+	c.pending[id] = ch
+	return ch, nil
+}
+
+func (c *neighborsConn) done(id enode.ID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+// nodeQueue is an iterator over nodes learned from FINDNODE responses. Each
+// node is only queued once per crawl.
+type nodeQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*enode.Node
+	seen   map[enode.ID]struct{}
+	cur    *enode.Node
+	closed bool
+}
+
+func newNodeQueue() *nodeQueue {
+	q := &nodeQueue{seen: make(map[enode.ID]struct{})}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *nodeQueue) push(nodes ...*enode.Node) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, n := range nodes {
+		if _, ok := q.seen[n.ID()]; ok {
+			continue
+		}
+		q.seen[n.ID()] = struct{}{}
+		q.queue = append(q.queue, n)
+	}
+	q.cond.Signal()
+}
+
+func (q *nodeQueue) Next() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.queue) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.cur, q.queue = q.queue[0], q.queue[1:]
+	return true
+}
+
+func (q *nodeQueue) Node() *enode.Node {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cur
+}
+
+func (q *nodeQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
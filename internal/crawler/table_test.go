@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestV4Target(t *testing.T) {
+	for range 100 {
+		var id enode.ID
+		rand.Read(id[:])
+		for dist := 256; dist > 256-tableBuckets; dist-- {
+			target := v4Target(id, dist)
+			if got := enode.LogDist(id, enode.ID(crypto.Keccak256Hash(target[:]))); got != dist {
+				t.Fatalf("Target for %v at distance %d lies at distance %d", id, dist, got)
+			}
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]string{"": ModeRandom, "random": ModeRandom, " Table ": ModeTable} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMode("tabel"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+// listenUDP opens a socket on the loopback interface.
+func listenUDP(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func localAddr(conn *net.UDPConn) netip.AddrPort {
+	return conn.LocalAddr().(*net.UDPAddr).AddrPort()
+}
+
+// sendPacket encodes a packet signed by key and sends it to addr.
+func sendPacket(t *testing.T, conn *net.UDPConn, key *ecdsa.PrivateKey, p v4wire.Packet, addr netip.AddrPort) {
+	t.Helper()
+	packet, _, err := v4wire.Encode(key, p)
+	if err != nil {
+		t.Fatalf("Failed to encode packet: %v", err)
+	}
+	if _, err := conn.WriteToUDPAddrPort(packet, addr); err != nil {
+		t.Fatalf("Failed to send packet: %v", err)
+	}
+}
+
+// neighborsNodes returns n NEIGHBORS entries of new keys.
+func neighborsNodes(n int) []v4wire.Node {
+	nodes := make([]v4wire.Node, n)
+	for i := range nodes {
+		key, _ := crypto.GenerateKey()
+		nodes[i] = v4wire.Node{IP: net.IPv4(192, 0, 2, byte(i+1)), UDP: 30303, TCP: 30303, ID: v4wire.EncodePubkey(&key.PublicKey)}
+	}
+	return nodes
+}
+
+func TestNeighborsConn(t *testing.T) {
+	conn := newNeighborsConn(listenUDP(t))
+	remote := listenUDP(t)
+	remoteKey, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	id := enode.PubkeyToIDV4(&remoteKey.PublicKey)
+	expiration := uint64(time.Now().Add(time.Minute).Unix())
+
+	replies, err := conn.expect(id)
+	if err != nil {
+		t.Fatalf("expect failed: %v", err)
+	}
+	if _, err := conn.expect(id); err != errFindnodePending {
+		t.Errorf("Expected errFindnodePending for a second request, got %v", err)
+	}
+
+	read := func() {
+		t.Helper()
+		buf := make([]byte, 1280)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, addr, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		// the packet is still passed on to discv4
+		if n == 0 || addr != localAddr(remote) {
+			t.Errorf("Expected the packet to be returned, got %d bytes from %v", n, addr)
+		}
+	}
+
+	// NEIGHBORS of the pending node are handed over.
+	nodes := neighborsNodes(3)
+	sendPacket(t, remote, remoteKey, &v4wire.Neighbors{Nodes: nodes, Expiration: expiration}, localAddr(conn.UDPConn))
+	read()
+	select {
+	case reply := <-replies:
+		if len(reply.Nodes) != len(nodes) {
+			t.Errorf("Expected %d nodes, got %d", len(nodes), len(reply.Nodes))
+		}
+	default:
+		t.Error("Expected the NEIGHBORS packet to be intercepted")
+	}
+
+	// Other packets and NEIGHBORS of other nodes are not.
+	sendPacket(t, remote, remoteKey, &v4wire.Ping{Version: 4, Expiration: expiration}, localAddr(conn.UDPConn))
+	read()
+	sendPacket(t, remote, otherKey, &v4wire.Neighbors{Nodes: nodes, Expiration: expiration}, localAddr(conn.UDPConn))
+	read()
+	conn.done(id)
+	sendPacket(t, remote, remoteKey, &v4wire.Neighbors{Nodes: nodes, Expiration: expiration}, localAddr(conn.UDPConn))
+	read()
+	if len(replies) != 0 {
+		t.Errorf("Expected no further replies, got %d", len(replies))
+	}
+}
+
+func TestV4DumperDedup(t *testing.T) {
+	conn := newNeighborsConn(listenUDP(t))
+	localKey, _ := crypto.GenerateKey()
+	remote := listenUDP(t)
+	remoteKey, _ := crypto.GenerateKey()
+
+	// discv4 reads the shared socket in the crawler.
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			if _, _, err := conn.ReadFromUDPAddrPort(buf); err != nil {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+
+	// The remote node answers every FINDNODE with a full bucket: the same
+	// eight nodes and eight new ones, split over two packets like geth does.
+	shared := neighborsNodes(8)
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			n, addr, err := remote.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if p, _, _, err := v4wire.Decode(buf[:n]); err != nil || p.Kind() != v4wire.FindnodePacket {
+				continue
+			}
+			nodes := append(neighborsNodes(8), shared...)
+			expiration := uint64(time.Now().Add(time.Minute).Unix())
+			for _, chunk := range [][]v4wire.Node{nodes[:12], nodes[12:]} {
+				packet, _, _ := v4wire.Encode(remoteKey, &v4wire.Neighbors{Nodes: chunk, Expiration: expiration})
+				remote.WriteToUDPAddrPort(packet, addr)
+			}
+		}
+	}()
+
+	addr := localAddr(remote)
+	n := enode.NewV4(&remoteKey.PublicKey, addr.Addr().AsSlice(), 0, int(addr.Port()))
+	nodes, err := v4Dumper{conn, localKey}.DumpTable(n)
+	if err != nil {
+		t.Fatalf("DumpTable failed: %v", err)
+	}
+	if want := len(shared) + tableBuckets*8; len(nodes) != want {
+		t.Errorf("Expected %d distinct nodes, got %d", want, len(nodes))
+	}
+	seen := make(map[enode.ID]bool)
+	for _, nb := range nodes {
+		if seen[nb.ID()] {
+			t.Errorf("Node %v returned twice", nb.ID())
+		}
+		seen[nb.ID()] = true
+	}
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// AdoptionSeries is the adoption curve of a client release.
+type AdoptionSeries struct {
+	Client    string          `json:"client"`
+	Version   string          `json:"version"`
+	FirstSeen time.Time       `json:"first_seen"`
+	Points    []AdoptionPoint `json:"points"`
+}
+
+// AdoptionPoint is the number of nodes running a release in a crawl.
+type AdoptionPoint struct {
+	Crawl time.Time `json:"crawl"`
+	Nodes int       `json:"nodes"`
+	// ClientNodes and CrawlNodes are the nodes of the client and of the
+	// whole crawl the shares are computed from.
+	ClientNodes int     `json:"client_nodes"`
+	CrawlNodes  int     `json:"crawl_nodes"`
+	ClientShare float64 `json:"client_share"`
+	CrawlShare  float64 `json:"crawl_share"`
+}
+
+type releaseKey struct {
+	client, version string
+}
+
+// adoptionCounter counts the nodes per client release while writing a crawl.
+type adoptionCounter struct {
+	releases map[releaseKey]int
+	clients  map[string]int
+	total    int
+}
+
+func newAdoptionCounter() *adoptionCounter {
+	return &adoptionCounter{
+		releases: make(map[releaseKey]int),
+		clients:  make(map[string]int),
+	}
+}
+
+// add counts a node. Nodes without a client name only count towards the
+// crawl total, nodes without a version also towards the client total.
+func (a *adoptionCounter) add(client, version string) {
+	a.total++
+	if client == "" {
+		return
+	}
+	a.clients[client]++
+	if version != "" {
+		a.releases[releaseKey{client, version}]++
+	}
+}
+
+// insertAdoption writes the per-release node counts of a crawl and records
+// releases seen for the first time. Both tables are kept across restarts.
+func insertAdoption(tx *sql.Tx, now time.Time, a *adoptionCounter) error {
+	if len(a.releases) == 0 {
+		return nil
+	}
+	insert, err := tx.Prepare(
+		`INSERT INTO release_adoption(
+			crawl,
+			client,
+			version,
+			nodes,
+			client_nodes,
+			crawl_nodes
+		) VALUES ($1,$2,$3,$4,$5,$6)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	firstSeen, err := tx.Prepare(
+		`INSERT INTO release_first_seen(
+			client,
+			version,
+			first_seen
+		) VALUES ($1,$2,$3)
+		ON CONFLICT DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer firstSeen.Close()
+
+	keys := make([]releaseKey, 0, len(a.releases))
+	for k := range a.releases {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].client != keys[j].client {
+			return keys[i].client < keys[j].client
+		}
+		return keys[i].version < keys[j].version
+	})
+	for _, k := range keys {
+		if _, err := insert.Exec(now, k.client, k.version, a.releases[k], a.clients[k.client], a.total); err != nil {
+			return err
+		}
+		if _, err := firstSeen.Exec(k.client, k.version, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAdoption returns the adoption curves of the releases of a client (all
+// clients if empty) over the crawls since the given time, ordered by client
+// and first observation.
+func ReadAdoption(db *sql.DB, client string, since time.Time) ([]AdoptionSeries, error) {
+	rows, err := db.Query(
+		`SELECT
+			a.client,
+			a.version,
+			f.first_seen,
+			a.crawl,
+			a.nodes,
+			a.client_nodes,
+			a.crawl_nodes
+		FROM release_adoption a
+		JOIN release_first_seen f ON f.client = a.client AND f.version = a.version
+		WHERE ($1 = '' OR a.client = $1) AND a.crawl >= $2
+		ORDER BY a.client, f.first_seen, a.version, a.crawl`,
+		client, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []AdoptionSeries
+	for rows.Next() {
+		var (
+			key       releaseKey
+			firstSeen time.Time
+			p         AdoptionPoint
+		)
+		if err := rows.Scan(&key.client, &key.version, &firstSeen, &p.Crawl, &p.Nodes, &p.ClientNodes, &p.CrawlNodes); err != nil {
+			return nil, err
+		}
+		p.ClientShare = share(p.Nodes, p.ClientNodes)
+		p.CrawlShare = share(p.Nodes, p.CrawlNodes)
+
+		if n := len(series); n == 0 || series[n-1].Client != key.client || series[n-1].Version != key.version {
+			series = append(series, AdoptionSeries{Client: key.client, Version: key.version, FirstSeen: firstSeen})
+		}
+		last := &series[len(series)-1]
+		last.Points = append(last.Points, p)
+	}
+	return series, rows.Err()
+}
+
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
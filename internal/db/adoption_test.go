@@ -0,0 +1,62 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+)
+
+// expectAdoption expects the release adoption rows of a crawl written by
+// UpdateNodes, given as client, version, nodes, client nodes, crawl nodes.
+func expectAdoption(mock sqlmock.Sqlmock, rows ...[]any) {
+	mock.ExpectPrepare("INSERT INTO release_adoption")
+	mock.ExpectPrepare("INSERT INTO release_first_seen")
+	for _, r := range rows {
+		mock.ExpectExec("INSERT INTO release_adoption").
+			WithArgs(sqlmock.AnyArg(), r[0], r[1], r[2], r[3], r[4]).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO release_first_seen").
+			WithArgs(r[0], r[1], sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+}
+
+func TestReadAdoption(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	first := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	mock.ExpectQuery("FROM release_adoption").WithArgs("geth", first).
+		WillReturnRows(sqlmock.NewRows([]string{"client", "version", "first_seen", "crawl", "nodes", "client_nodes", "crawl_nodes"}).
+			AddRow("geth", "1.16.1", first, first, 30, 40, 100).
+			AddRow("geth", "1.16.1", first, second, 20, 40, 100).
+			AddRow("geth", "1.16.2", second, second, 20, 40, 100))
+
+	series, err := db.ReadAdoption(mockDB, "geth", first)
+	if err != nil {
+		t.Fatalf("ReadAdoption failed: %v", err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	if series[0].Version != "1.16.1" || len(series[0].Points) != 2 || !series[0].FirstSeen.Equal(first) {
+		t.Errorf("Unexpected series %+v", series[0])
+	}
+	if p := series[0].Points[0]; p.ClientShare != 0.75 || p.CrawlShare != 0.3 {
+		t.Errorf("Unexpected shares %+v", p)
+	}
+	if series[1].Version != "1.16.2" || len(series[1].Points) != 1 || series[1].Points[0].ClientShare != 0.5 {
+		t.Errorf("Unexpected series %+v", series[1])
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Exposure is the number of nodes of a client affected by an advisory in a
+// crawl.
+type Exposure struct {
+	Crawl    time.Time `json:"crawl"`
+	Advisory string    `json:"advisory"`
+	Client   string    `json:"client"`
+	Nodes    int       `json:"nodes"`
+	// Total is the number of nodes of the client in the crawl.
+	Total int `json:"total"`
+}
+
+// exposureKey identifies an advisory of a client. One advisory ID may be
+// listed for several clients.
+type exposureKey struct {
+	advisory, client string
+}
+
+// exposureCounter counts advisory matches while writing a crawl.
+type exposureCounter struct {
+	nodes  map[exposureKey]int // affected nodes
+	totals map[string]int      // client -> nodes
+}
+
+func newExposureCounter() *exposureCounter {
+	return &exposureCounter{
+		nodes:  make(map[exposureKey]int),
+		totals: make(map[string]int),
+	}
+}
+
+func (e *exposureCounter) add(client string, advisories []string) {
+	if client == "" {
+		return
+	}
+	e.totals[client]++
+	for _, id := range advisories {
+		e.nodes[exposureKey{id, client}]++
+	}
+}
+
+// insertExposure writes the per-advisory and client exposure counts of a
+// crawl. Rows are kept across restarts to follow exposure over time.
+func insertExposure(tx *sql.Tx, now time.Time, e *exposureCounter) error {
+	if len(e.nodes) == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(
+		`INSERT INTO advisory_exposure(
+			crawl,
+			advisory,
+			client,
+			nodes,
+			total
+		) VALUES ($1,$2,$3,$4,$5)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	keys := make([]exposureKey, 0, len(e.nodes))
+	for key := range e.nodes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].advisory != keys[j].advisory {
+			return keys[i].advisory < keys[j].advisory
+		}
+		return keys[i].client < keys[j].client
+	})
+	for _, key := range keys {
+		if _, err := stmt.Exec(now, key.advisory, key.client, e.nodes[key], e.totals[key.client]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadExposure returns the advisory exposure counts, of a single crawl if
+// crawl is non-zero, ordered by crawl and affected nodes.
+func ReadExposure(db *sql.DB, crawl time.Time) ([]Exposure, error) {
+	query := `SELECT crawl, advisory, client, nodes, total FROM advisory_exposure`
+	var args []any
+	if !crawl.IsZero() {
+		query += ` WHERE crawl = $1`
+		args = append(args, crawl)
+	}
+	rows, err := db.Query(query+` ORDER BY crawl, nodes DESC, advisory, client`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exposure []Exposure
+	for rows.Next() {
+		var e Exposure
+		if err := rows.Scan(&e.Crawl, &e.Advisory, &e.Client, &e.Nodes, &e.Total); err != nil {
+			return nil, err
+		}
+		exposure = append(exposure, e)
+	}
+	return exposure, rows.Err()
+}
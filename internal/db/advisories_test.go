@@ -0,0 +1,167 @@
+package db_test
+
+import (
+	"database/sql/driver"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/releases"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/lib/pq"
+)
+
+func TestUpdateNodesMatchesAdvisories(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	advisories, err := releases.ParseAdvisories([]byte(`{"advisories": [
+		{"id": "GHSA-0001", "client": "geth", "affected": ["<1.16.2"]},
+		{"id": "GHSA-0002", "client": "geth", "affected": [">=1.16.0, <=1.16.1"]},
+		{"id": "GHSA-0003", "client": "besu", "affected": ["<25.1.0"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseAdvisories failed: %v", err)
+	}
+
+	var nodes []common.NodeJSON
+	for _, name := range []string{
+		"Geth/v1.16.1-stable-a1b2c3d4/linux-amd64/go1.24.5",
+		"Geth/v1.16.2-stable-a1b2c3d4/linux-amd64/go1.24.5",
+	} {
+		key, _ := crypto.GenerateKey()
+		nodes = append(nodes, common.NodeJSON{
+			N:    enode.NewV4(&key.PublicKey, net.ParseIP("8.8.8.8"), 30303, 30303),
+			Info: &common.ClientInfo{ClientType: name, TotalDifficulty: big.NewInt(0)},
+		})
+	}
+
+	args := func(matched []string) []driver.Value {
+		return nodeArgs(t, map[string]driver.Value{"advisories": pq.Array(matched)})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args([]string{"GHSA-0001", "GHSA-0002"})...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args(nil)...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO advisory_exposure")
+	mock.ExpectExec("INSERT INTO advisory_exposure").
+		WithArgs(sqlmock.AnyArg(), "GHSA-0001", "geth", 1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO advisory_exposure").
+		WithArgs(sqlmock.AnyArg(), "GHSA-0002", "geth", 1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAdoption(mock,
+		[]any{"geth", "1.16.1", 1, 2, 2},
+		[]any{"geth", "1.16.2", 1, 2, 2})
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, advisories, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestReadExposure(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	crawl := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM advisory_exposure WHERE crawl").WithArgs(crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"crawl", "advisory", "client", "nodes", "total"}).
+			AddRow(crawl, "GHSA-0001", "geth", 12, 40))
+
+	exposure, err := db.ReadExposure(mockDB, crawl)
+	if err != nil {
+		t.Fatalf("ReadExposure failed: %v", err)
+	}
+	if len(exposure) != 1 || exposure[0].Nodes != 12 || exposure[0].Total != 40 {
+		t.Errorf("Unexpected exposure %+v", exposure)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestUpdateNodesSharedAdvisory(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	// one ID listed for two clients is counted per client
+	advisories, err := releases.ParseAdvisories([]byte(`{"advisories": [
+		{"id": "GHSA-0001", "client": "geth", "affected": ["<1.16.2"]},
+		{"id": "GHSA-0001", "client": "besu", "affected": ["<25.1.0"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseAdvisories failed: %v", err)
+	}
+
+	var nodes []common.NodeJSON
+	for _, name := range []string{
+		"Geth/v1.16.1-stable-a1b2c3d4/linux-amd64/go1.24.5",
+		"Geth/v1.16.2-stable-a1b2c3d4/linux-amd64/go1.24.5",
+		"besu/v24.12.0/linux-x86_64/openjdk-java-21",
+	} {
+		key, _ := crypto.GenerateKey()
+		nodes = append(nodes, common.NodeJSON{
+			N:    enode.NewV4(&key.PublicKey, net.ParseIP("8.8.8.8"), 30303, 30303),
+			Info: &common.ClientInfo{ClientType: name, TotalDifficulty: big.NewInt(0)},
+		})
+	}
+
+	args := func(matched []string) []driver.Value {
+		return nodeArgs(t, map[string]driver.Value{"advisories": pq.Array(matched)})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args([]string{"GHSA-0001"})...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args(nil)...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args([]string{"GHSA-0001"})...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO advisory_exposure")
+	mock.ExpectExec("INSERT INTO advisory_exposure").
+		WithArgs(sqlmock.AnyArg(), "GHSA-0001", "besu", 1, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO advisory_exposure").
+		WithArgs(sqlmock.AnyArg(), "GHSA-0001", "geth", 1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAdoption(mock,
+		[]any{"besu", "24.12.0", 1, 1, 3},
+		[]any{"geth", "1.16.1", 1, 2, 3},
+		[]any{"geth", "1.16.2", 1, 2, 3})
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, advisories, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// Audit actions of blacklist changes.
+const (
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+)
+
+// BlacklistEntry is an IP, CIDR block or node key blacklisted at runtime.
+type BlacklistEntry struct {
+	Kind   string `json:"kind"`
+	Entry  string `json:"entry"`
+	Reason string `json:"reason,omitempty"`
+	// Expires is nil for entries which don't expire.
+	Expires *time.Time `json:"expires,omitempty"`
+	AddedBy string     `json:"added_by"`
+	Added   time.Time  `json:"added"`
+}
+
+// BlacklistChange is an audit record of an added or removed entry.
+type BlacklistChange struct {
+	At      time.Time  `json:"at"`
+	Actor   string     `json:"actor"`
+	Action  string     `json:"action"`
+	Kind    string     `json:"kind"`
+	Entry   string     `json:"entry"`
+	Reason  string     `json:"reason,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// AddBlacklistEntry stores an entry, replacing the reason and expiry of an
+// existing one, and records the change. The entry is normalised first.
+func AddBlacklistEntry(db *sql.DB, e BlacklistEntry) (BlacklistEntry, error) {
+	var err error
+	if e.Kind, e.Entry, err = util.ParseBlacklistEntry(e.Entry); err != nil {
+		return e, err
+	}
+	if e.AddedBy == "" {
+		return e, errors.New("blacklist changes require an actor")
+	}
+	if e.Added.IsZero() {
+		e.Added = time.Now()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return e, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		`INSERT INTO blacklist_entries(
+			kind,
+			entry,
+			reason,
+			expires,
+			added_by,
+			added
+		) VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT (kind, entry) DO UPDATE SET
+			reason = EXCLUDED.reason,
+			expires = EXCLUDED.expires,
+			added_by = EXCLUDED.added_by,
+			added = EXCLUDED.added`,
+		e.Kind, e.Entry, nullString(e.Reason), nullPtr(e.Expires), e.AddedBy, e.Added,
+	)
+	if err != nil {
+		return e, err
+	}
+	if err := insertBlacklistChange(tx, e.Added, e.AddedBy, ActionAdd, e); err != nil {
+		return e, err
+	}
+	return e, tx.Commit()
+}
+
+// RemoveBlacklistEntry deletes an entry and records the change. It reports
+// false if there was no such entry.
+func RemoveBlacklistEntry(db *sql.DB, entry, actor string) (bool, error) {
+	kind, entry, err := util.ParseBlacklistEntry(entry)
+	if err != nil {
+		return false, err
+	}
+	if actor == "" {
+		return false, errors.New("blacklist changes require an actor")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM blacklist_entries WHERE kind = $1 AND entry = $2`, kind, entry)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	e := BlacklistEntry{Kind: kind, Entry: entry}
+	if err := insertBlacklistChange(tx, time.Now(), actor, ActionRemove, e); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func insertBlacklistChange(tx *sql.Tx, at time.Time, actor, action string, e BlacklistEntry) error {
+	_, err := tx.Exec(
+		`INSERT INTO blacklist_audit(
+			at,
+			actor,
+			action,
+			kind,
+			entry,
+			reason,
+			expires
+		) VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+		at, actor, action, e.Kind, e.Entry, nullString(e.Reason), nullPtr(e.Expires),
+	)
+	return err
+}
+
+// ReadBlacklistEntries returns the entries, ordered by kind and entry.
+// Entries expired at the given time are skipped unless it is zero.
+func ReadBlacklistEntries(db *sql.DB, at time.Time) ([]BlacklistEntry, error) {
+	query := `SELECT kind, entry, reason, expires, added_by, added FROM blacklist_entries`
+	var args []any
+	if !at.IsZero() {
+		query += ` WHERE expires IS NULL OR expires > $1`
+		args = append(args, at)
+	}
+	rows, err := db.Query(query+` ORDER BY kind, entry`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []BlacklistEntry
+	for rows.Next() {
+		var (
+			e       BlacklistEntry
+			reason  sql.NullString
+			expires sql.NullTime
+		)
+		if err := rows.Scan(&e.Kind, &e.Entry, &reason, &expires, &e.AddedBy, &e.Added); err != nil {
+			return nil, err
+		}
+		e.Reason = reason.String
+		if expires.Valid {
+			e.Expires = &expires.Time
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ReadBlacklistAudit returns the latest changes, newest first.
+func ReadBlacklistAudit(db *sql.DB, limit int) ([]BlacklistChange, error) {
+	rows, err := db.Query(
+		`SELECT at, actor, action, kind, entry, reason, expires
+		FROM blacklist_audit
+		ORDER BY at DESC
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []BlacklistChange
+	for rows.Next() {
+		var (
+			c       BlacklistChange
+			reason  sql.NullString
+			expires sql.NullTime
+		)
+		if err := rows.Scan(&c.At, &c.Actor, &c.Action, &c.Kind, &c.Entry, &reason, &expires); err != nil {
+			return nil, err
+		}
+		c.Reason = reason.String
+		if expires.Valid {
+			c.Expires = &expires.Time
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ManagedBlacklist applies the entries stored in the database on top of the
+// entries of the blacklist files. Sync has to be called periodically to pick
+// up expired entries and changes made by other processes (e.g. the CLI).
+type ManagedBlacklist struct {
+	*util.Blacklist
+	db *sql.DB
+	// entries of the blacklist files
+	ips, pubkeys []string
+
+	mu      sync.Mutex
+	applied []string // stored entries of the last sync
+}
+
+// NewManagedBlacklist wraps a blacklist loaded from the given file entries.
+func NewManagedBlacklist(db *sql.DB, bl *util.Blacklist, ips, pubkeys []string) *ManagedBlacklist {
+	return &ManagedBlacklist{Blacklist: bl, db: db, ips: ips, pubkeys: pubkeys}
+}
+
+// Sync reloads the blacklist if the active stored entries changed.
+func (m *ManagedBlacklist) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := ReadBlacklistEntries(m.db, time.Now())
+	if err != nil {
+		return err
+	}
+	active := make([]string, len(entries))
+	ips := slices.Clone(m.ips)
+	pubkeys := slices.Clone(m.pubkeys)
+	for i, e := range entries {
+		active[i] = e.Kind + " " + e.Entry
+		if e.Kind == util.EntryIP {
+			ips = append(ips, e.Entry)
+		} else {
+			pubkeys = append(pubkeys, e.Entry)
+		}
+	}
+	if m.applied != nil && slices.Equal(active, m.applied) {
+		return nil
+	}
+	m.Reload(ips, pubkeys)
+	m.applied = active
+	log.Info("Applied stored blacklist entries", "entries", len(entries))
+	return nil
+}
+
+// Add stores an entry and applies it immediately.
+func (m *ManagedBlacklist) Add(e BlacklistEntry) (BlacklistEntry, error) {
+	e, err := AddBlacklistEntry(m.db, e)
+	if err != nil {
+		return e, err
+	}
+	return e, m.Sync()
+}
+
+// Remove deletes an entry and applies the change immediately.
+func (m *ManagedBlacklist) Remove(entry, actor string) (bool, error) {
+	removed, err := RemoveBlacklistEntry(m.db, entry, actor)
+	if err != nil || !removed {
+		return removed, err
+	}
+	return true, m.Sync()
+}
@@ -0,0 +1,128 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func TestAddBlacklistEntry(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	added := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	expires := added.Add(72 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blacklist_entries").
+		WithArgs(util.EntryIP, "203.0.113.0/24", "scanner", expires, "alice", added).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO blacklist_audit").
+		WithArgs(added, "alice", db.ActionAdd, util.EntryIP, "203.0.113.0/24", "scanner", expires).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	e, err := db.AddBlacklistEntry(mockDB, db.BlacklistEntry{
+		Entry:   "203.0.113.77/24",
+		Reason:  "scanner",
+		Expires: &expires,
+		AddedBy: "alice",
+		Added:   added,
+	})
+	if err != nil {
+		t.Fatalf("AddBlacklistEntry failed: %v", err)
+	}
+	if e.Kind != util.EntryIP || e.Entry != "203.0.113.0/24" {
+		t.Errorf("Expected the masked CIDR block, got %s %s", e.Kind, e.Entry)
+	}
+
+	if _, err := db.AddBlacklistEntry(mockDB, db.BlacklistEntry{Entry: "nonsense", AddedBy: "alice"}); err == nil {
+		t.Error("Expected an error for an invalid entry")
+	}
+	if _, err := db.AddBlacklistEntry(mockDB, db.BlacklistEntry{Entry: "192.0.2.1"}); err == nil {
+		t.Error("Expected an error without an actor")
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestRemoveBlacklistEntry(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blacklist_entries").WithArgs(util.EntryIP, "192.0.2.1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO blacklist_audit").
+		WithArgs(sqlmock.AnyArg(), "bob", db.ActionRemove, util.EntryIP, "192.0.2.1", nil, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	removed, err := db.RemoveBlacklistEntry(mockDB, "192.0.2.1", "bob")
+	if err != nil || !removed {
+		t.Fatalf("RemoveBlacklistEntry = %v, %v; want true", removed, err)
+	}
+
+	// unknown entries aren't audited
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blacklist_entries").WithArgs(util.EntryIP, "192.0.2.2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	removed, err = db.RemoveBlacklistEntry(mockDB, "192.0.2.2", "bob")
+	if err != nil || removed {
+		t.Errorf("RemoveBlacklistEntry = %v, %v; want false", removed, err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestManagedBlacklistSync(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	columns := []string{"kind", "entry", "reason", "expires", "added_by", "added"}
+	added := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM blacklist_entries WHERE expires IS NULL OR expires > \\$1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(util.EntryIP, "203.0.113.0/24", "scanner", nil, "alice", added))
+
+	bl := db.NewManagedBlacklist(mockDB, util.NewBlacklist([]string{"192.0.2.1"}, nil), []string{"192.0.2.1"}, nil)
+	if err := bl.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !bl.IsIPBlacklisted("192.0.2.1") || !bl.IsIPBlacklisted("203.0.113.9") {
+		t.Error("Expected the file and stored entries to be applied")
+	}
+
+	// the stored entry expired
+	mock.ExpectQuery("FROM blacklist_entries").WillReturnRows(sqlmock.NewRows(columns))
+	if err := bl.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if !bl.IsIPBlacklisted("192.0.2.1") || bl.IsIPBlacklisted("203.0.113.9") {
+		t.Error("Expected only the file entries after expiry")
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
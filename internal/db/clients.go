@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+// ClientVersion is the parsed client of a node in a crawl.
+type ClientVersion struct {
+	ID      string
+	Client  string
+	Version vparser.Version
+}
+
+// LatestNodeCrawl returns the timestamp of the most recent node write.
+func LatestNodeCrawl(db *sql.DB) (time.Time, error) {
+	var crawl sql.NullTime
+	if err := db.QueryRow(`SELECT MAX(now) FROM nodes`).Scan(&crawl); err != nil {
+		return time.Time{}, err
+	}
+	if !crawl.Valid {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return crawl.Time, nil
+}
+
+// ReadClientVersions returns the parsed clients of the nodes written at the
+// given timestamp. Nodes without a parsed version have a zero Version.
+func ReadClientVersions(db *sql.DB, crawl time.Time) ([]ClientVersion, error) {
+	rows, err := db.Query(
+		`SELECT
+			id,
+			client_name,
+			COALESCE(client_major, 0),
+			COALESCE(client_minor, 0),
+			COALESCE(client_patch, 0),
+			COALESCE(client_tag, '')
+		FROM nodes WHERE now = $1 AND client_name IS NOT NULL`,
+		crawl,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []ClientVersion
+	for rows.Next() {
+		var c ClientVersion
+		if err := rows.Scan(&c.ID, &c.Client, &c.Version.Major, &c.Version.Minor, &c.Version.Patch, &c.Version.Tag); err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
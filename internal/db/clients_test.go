@@ -0,0 +1,50 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+func TestReadClientVersions(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	crawl := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT MAX\\(now\\) FROM nodes").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(crawl))
+	mock.ExpectQuery("FROM nodes WHERE now").WithArgs(crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "client_name", "major", "minor", "patch", "tag"}).
+			AddRow("aa", "geth", 1, 16, 2, "stable").
+			AddRow("bb", "nimbus", 0, 0, 0, ""))
+
+	latest, err := db.LatestNodeCrawl(mockDB)
+	if err != nil {
+		t.Fatalf("LatestNodeCrawl failed: %v", err)
+	}
+	clients, err := db.ReadClientVersions(mockDB, latest)
+	if err != nil {
+		t.Fatalf("ReadClientVersions failed: %v", err)
+	}
+	if len(clients) != 2 {
+		t.Fatalf("Expected 2 clients, got %d", len(clients))
+	}
+	want := vparser.Version{Major: 1, Minor: 16, Patch: 2, Tag: "stable"}
+	if clients[0].Client != "geth" || clients[0].Version != want {
+		t.Errorf("Unexpected client %+v", clients[0])
+	}
+	if clients[1].Version != (vparser.Version{}) {
+		t.Errorf("Expected zero version, got %+v", clients[1].Version)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
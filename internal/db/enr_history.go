@@ -0,0 +1,353 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/eth2"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// Kinds of ENR change events.
+const (
+	ChangeIP         = "ip_changed"
+	ChangePort       = "port_changed"
+	ChangeForkDigest = "fork_digest_changed"
+)
+
+// ENRRecord is a stored version of a node record. ENR and Record are empty
+// for versions stored in a privacy mode other than full.
+type ENRRecord struct {
+	NodeID string `json:"nodeId"`
+	Seq    uint64 `json:"seq"`
+	ENR    string `json:"enr,omitempty"`
+	Record []byte `json:"-"`
+	// Fields are the compared fields, with IPs as stored in the nodes table.
+	Fields    map[string]string `json:"fields,omitempty"`
+	FirstSeen time.Time         `json:"firstSeen"`
+}
+
+// RecordChange is a difference between two versions of a node record.
+type RecordChange struct {
+	Kind  string `json:"kind"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ENRChange is a change event derived when a new record version was stored.
+type ENRChange struct {
+	NodeID   string    `json:"nodeId"`
+	Seq      uint64    `json:"seq"`
+	PrevSeq  uint64    `json:"prevSeq"`
+	Detected time.Time `json:"detected"`
+	RecordChange
+}
+
+// recordFields are the fields compared between record versions, in the
+// order of their change events.
+var recordFields = []struct{ field, kind string }{
+	{"ip", ChangeIP},
+	{"ip6", ChangeIP},
+	{"tcp", ChangePort},
+	{"udp", ChangePort},
+	{"tcp6", ChangePort},
+	{"udp6", ChangePort},
+	{"quic", ChangePort},
+	{"quic6", ChangePort},
+	{"eth2", ChangeForkDigest},
+}
+
+// RecordChanges compares the endpoints and fork digest of two versions of a record.
+func RecordChanges(prev, cur *enode.Node) []RecordChange {
+	return fieldChanges(fieldValues(prev), fieldValues(cur))
+}
+
+// fieldValues returns the compared fields of a record, keyed by field name.
+// Unset fields are omitted.
+func fieldValues(n *enode.Node) map[string]string {
+	values := make(map[string]string)
+	set := func(field, v string) {
+		if v != "" {
+			values[field] = v
+		}
+	}
+	addr := func(ip netip.Addr) string {
+		if !ip.IsValid() {
+			return ""
+		}
+		return ip.String()
+	}
+	port := func(p uint16) string {
+		if p == 0 {
+			return ""
+		}
+		return strconv.Itoa(int(p))
+	}
+
+	e := common.NodeEndpoints(n)
+	set("ip", addr(e.IP))
+	set("ip6", addr(e.IP6))
+	set("tcp", port(e.TCP))
+	set("udp", port(e.UDP))
+	set("tcp6", port(e.TCP6))
+	set("udp6", port(e.UDP6))
+	set("quic", port(e.QUIC))
+	set("quic6", port(e.QUIC6))
+	set("eth2", forkDigest(n))
+	return values
+}
+
+// anonymizeFields applies the privacy mode to the IP fields.
+func anonymizeFields(privacy *util.IPPrivacy, values map[string]string) {
+	for _, f := range recordFields {
+		if v, ok := values[f.field]; ok && f.kind == ChangeIP {
+			if v = anonymizeValue(privacy, v); v != "" {
+				values[f.field] = v
+			} else {
+				delete(values, f.field)
+			}
+		}
+	}
+}
+
+func fieldChanges(prev, cur map[string]string) []RecordChange {
+	var changes []RecordChange
+	for _, f := range recordFields {
+		if old, new := prev[f.field], cur[f.field]; old != new {
+			changes = append(changes, RecordChange{Kind: f.kind, Field: f.field, Old: old, New: new})
+		}
+	}
+	return changes
+}
+
+func forkDigest(n *enode.Node) string {
+	if cl := eth2.DecodeENR(n, nil); cl != nil {
+		return cl.ForkDigest
+	}
+	return ""
+}
+
+// insertRecords stores every signed record whose seq hasn't been seen before
+// and derives change events against the previous version. Besides the raw
+// record, the compared fields are stored with their IPs anonymised like the
+// node rows. Raw records contain the IPs, so they are only stored in the full
+// privacy mode; the other modes keep the fields alone.
+func insertRecords(tx *sql.Tx, now time.Time, privacy *util.IPPrivacy, nodes []common.NodeJSON) error {
+	var signed []*enode.Node
+	for _, n := range nodes {
+		// Records rebuilt from enode URLs (EIP-868 fallback) have no signature.
+		if n.N != nil && len(n.N.Record().Signature()) > 0 {
+			signed = append(signed, n.N)
+		}
+	}
+	if len(signed) == 0 {
+		return nil
+	}
+
+	insert, err := tx.Prepare(
+		`INSERT INTO enr_history(
+			node_id,
+			seq,
+			enr,
+			record,
+			fields,
+			first_seen
+		) VALUES ($1,$2,$3,$4,$5,$6)
+		ON CONFLICT DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	previous, err := tx.Prepare(
+		`SELECT seq, fields FROM enr_history
+		WHERE node_id = $1 AND seq < $2
+		ORDER BY seq DESC LIMIT 1`,
+	)
+	if err != nil {
+		return err
+	}
+	defer previous.Close()
+	insertChange, err := tx.Prepare(
+		`INSERT INTO enr_changes(
+			node_id,
+			seq,
+			prev_seq,
+			detected,
+			kind,
+			field,
+			old_value,
+			new_value
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer insertChange.Close()
+
+	var stored, changed int
+	for _, n := range signed {
+		var enrText, record any // NULL unless addresses are stored as is
+		if privacy.StoresFull() {
+			b, err := rlp.EncodeToBytes(n.Record())
+			if err != nil {
+				return err
+			}
+			enrText, record = n.String(), b
+		}
+		fields := fieldValues(n)
+		anonymizeFields(privacy, fields)
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		res, err := insert.Exec(n.ID().String(), nullSeq(n.Seq()), enrText, record, string(encoded), now)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			continue
+		}
+		stored++
+
+		var (
+			prevSeq    uint64
+			prevFields sql.NullString
+		)
+		err = previous.QueryRow(n.ID().String(), nullSeq(n.Seq())).Scan(&prevSeq, &prevFields)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+		prev, err := storedFields(prevFields)
+		if err != nil || prev == nil {
+			log.Warn("Skipping undecodable stored record", "id", n.ID(), "seq", prevSeq, "error", err)
+			continue
+		}
+		// earlier versions may have been stored in another privacy mode
+		anonymizeFields(privacy, prev)
+		for _, c := range fieldChanges(prev, fields) {
+			_, err := insertChange.Exec(
+				n.ID().String(),
+				nullSeq(n.Seq()),
+				nullSeq(prevSeq),
+				now,
+				c.Kind,
+				c.Field,
+				c.Old,
+				c.New,
+			)
+			if err != nil {
+				return err
+			}
+			changed++
+		}
+	}
+	log.Info("Wrote node records to database", "new", stored, "changes", changed)
+	return nil
+}
+
+// storedFields returns the compared fields of a stored version, nil if they
+// were dropped.
+func storedFields(fields sql.NullString) (map[string]string, error) {
+	if !fields.Valid {
+		return nil, nil
+	}
+	values := make(map[string]string)
+	return values, json.Unmarshal([]byte(fields.String), &values)
+}
+
+// anonymizeValue applies the privacy mode to an IP stored as text. Truncated
+// prefixes are only replaced by the modes not storing addresses, other values
+// (e.g. HMACs) are kept.
+func anonymizeValue(privacy *util.IPPrivacy, v string) string {
+	if ip, err := netip.ParseAddr(v); err == nil {
+		return privacy.Value(ip)
+	}
+	if prefix, err := netip.ParsePrefix(v); err == nil && !privacy.Stores() {
+		return privacy.Value(prefix.Addr())
+	}
+	return v
+}
+
+// nullSeq maps sequence numbers that don't fit into BIGINT to NULL.
+func nullSeq(seq uint64) sql.NullInt64 {
+	return nullUint(seq, true)
+}
+
+// decodeRecord decodes and verifies a stored record.
+func decodeRecord(b []byte) (*enode.Node, error) {
+	var r enr.Record
+	if err := rlp.DecodeBytes(b, &r); err != nil {
+		return nil, err
+	}
+	return enode.New(enode.ValidSchemes, &r)
+}
+
+// ReadENRHistory returns all stored versions of a node record, oldest first.
+func ReadENRHistory(db *sql.DB, id string) ([]ENRRecord, error) {
+	rows, err := db.Query(
+		`SELECT node_id, seq, enr, record, fields, first_seen
+		FROM enr_history WHERE node_id = $1
+		ORDER BY seq`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ENRRecord
+	for rows.Next() {
+		var (
+			r      ENRRecord
+			enr    sql.NullString
+			fields sql.NullString
+		)
+		if err := rows.Scan(&r.NodeID, &r.Seq, &enr, &r.Record, &fields, &r.FirstSeen); err != nil {
+			return nil, err
+		}
+		r.ENR = enr.String
+		if r.Fields, err = storedFields(fields); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// ReadENRChanges returns the change events of a node, oldest first.
+func ReadENRChanges(db *sql.DB, id string) ([]ENRChange, error) {
+	rows, err := db.Query(
+		`SELECT node_id, seq, prev_seq, detected, kind, field, old_value, new_value
+		FROM enr_changes WHERE node_id = $1
+		ORDER BY seq, field`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []ENRChange
+	for rows.Next() {
+		var c ENRChange
+		if err := rows.Scan(&c.NodeID, &c.Seq, &c.PrevSeq, &c.Detected, &c.Kind, &c.Field, &c.Old, &c.New); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
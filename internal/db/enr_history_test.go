@@ -0,0 +1,218 @@
+package db_test
+
+import (
+	"crypto/ecdsa"
+	"database/sql/driver"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/util"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+func signedNode(t *testing.T, key *ecdsa.PrivateKey, ip net.IP, port int, entries ...enr.Entry) *enode.Node {
+	t.Helper()
+	var r enr.Record
+	r.SetSeq(uint64(port))
+	r.Set(enr.IPv4(ip))
+	r.Set(enr.UDP(port))
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	return n
+}
+
+func TestRecordChanges(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	eth2A := enr.WithEntry("eth2", []byte{0x6a, 0x95, 0xa1, 0xa9, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	eth2B := enr.WithEntry("eth2", []byte{0xad, 0x53, 0x2c, 0xeb, 5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	tests := []struct {
+		name string
+		prev *enode.Node
+		cur  *enode.Node
+		want []db.RecordChange
+	}{
+		{
+			name: "unchanged",
+			prev: signedNode(t, key, net.ParseIP("192.0.2.1"), 30303, eth2A),
+			cur:  signedNode(t, key, net.ParseIP("192.0.2.1"), 30303, eth2A),
+		},
+		{
+			name: "ip and port",
+			prev: signedNode(t, key, net.ParseIP("192.0.2.1"), 30303),
+			cur:  signedNode(t, key, net.ParseIP("192.0.2.2"), 30304),
+			want: []db.RecordChange{
+				{Kind: db.ChangeIP, Field: "ip", Old: "192.0.2.1", New: "192.0.2.2"},
+				{Kind: db.ChangePort, Field: "udp", Old: "30303", New: "30304"},
+			},
+		},
+		{
+			name: "fork digest",
+			prev: signedNode(t, key, net.ParseIP("192.0.2.1"), 30303, eth2A),
+			cur:  signedNode(t, key, net.ParseIP("192.0.2.1"), 30303, eth2B),
+			want: []db.RecordChange{
+				{Kind: db.ChangeForkDigest, Field: "eth2", Old: "0x6a95a1a9", New: "0xad532ceb"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := db.RecordChanges(tt.prev, tt.cur)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d changes, got %+v", len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Change %d: expected %+v, got %+v", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateNodesWithRecords(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	key, _ := crypto.GenerateKey()
+	prev := signedNode(t, key, net.ParseIP("192.0.2.1"), 30303)
+	cur := signedNode(t, key, net.ParseIP("192.0.2.2"), 30304)
+	id := cur.ID().String()
+
+	nodes := []common.NodeJSON{{N: cur, Seq: cur.Seq(), Score: 1, LastResponse: time.Now()}}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO enr_history")
+	mock.ExpectPrepare("SELECT seq, fields FROM enr_history")
+	mock.ExpectPrepare("INSERT INTO enr_changes")
+	mock.ExpectExec("INSERT INTO enr_history").
+		WithArgs(id, int64(cur.Seq()), cur.String(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT seq, fields FROM enr_history").WithArgs(id, int64(cur.Seq())).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "fields"}).AddRow(prev.Seq(), `{"ip":"192.0.2.1","udp":"30303"}`))
+	mock.ExpectExec("INSERT INTO enr_changes").
+		WithArgs(id, int64(cur.Seq()), int64(prev.Seq()), sqlmock.AnyArg(), db.ChangeIP, "ip", "192.0.2.1", "192.0.2.2").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO enr_changes").
+		WithArgs(id, int64(cur.Seq()), int64(prev.Seq()), sqlmock.AnyArg(), db.ChangePort, "udp", "30303", "30304").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, nil, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// A known seq inserts nothing and derives no changes.
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO enr_history")
+	mock.ExpectPrepare("SELECT seq, fields FROM enr_history")
+	mock.ExpectPrepare("INSERT INTO enr_changes")
+	mock.ExpectExec("INSERT INTO enr_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, nil, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+// noRawIP matches NULL and values not containing the given IPs.
+type noRawIP []string
+
+func (ips noRawIP) Match(v driver.Value) bool {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return true
+	}
+	for _, ip := range ips {
+		if strings.Contains(s, ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateNodesRecordsWithoutRawIPs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	privacy, err := util.NewIPPrivacy(util.PrivacyHMAC, "secret", 24, 48)
+	if err != nil {
+		t.Fatalf("NewIPPrivacy failed: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	prev := signedNode(t, key, net.ParseIP("192.0.2.1"), 30303)
+	cur := signedNode(t, key, net.ParseIP("192.0.2.2"), 30304)
+	id := cur.ID().String()
+	prevFields := `{"ip":"` + privacy.Hash(netip.MustParseAddr("192.0.2.1")) + `","udp":"30303"}`
+	raw := noRawIP{"192.0.2.1", "192.0.2.2", "enr:"}
+
+	nodes := []common.NodeJSON{{N: cur, Seq: cur.Seq(), Score: 1, LastResponse: time.Now()}}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO enr_history")
+	mock.ExpectPrepare("SELECT seq, fields FROM enr_history")
+	mock.ExpectPrepare("INSERT INTO enr_changes")
+	// neither the enr text nor the RLP record are stored
+	mock.ExpectExec("INSERT INTO enr_history").
+		WithArgs(id, int64(cur.Seq()), nil, nil, raw, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT seq, fields FROM enr_history").WithArgs(id, int64(cur.Seq())).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "fields"}).AddRow(prev.Seq(), prevFields))
+	mock.ExpectExec("INSERT INTO enr_changes").
+		WithArgs(id, int64(cur.Seq()), int64(prev.Seq()), sqlmock.AnyArg(), db.ChangeIP, "ip",
+			privacy.Hash(netip.MustParseAddr("192.0.2.1")), privacy.Hash(netip.MustParseAddr("192.0.2.2"))).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO enr_changes").
+		WithArgs(id, int64(cur.Seq()), int64(prev.Seq()), sqlmock.AnyArg(), db.ChangePort, "udp", "30303", "30304").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, nil, nil, privacy, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+package db
+
+// NodeColumns exposes the columns written by UpdateNodes to the tests.
+var NodeColumns = nodeColumns
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/hosting"
+)
+
+// HostingShare is the number of nodes hosted by a provider in a crawl.
+type HostingShare struct {
+	Crawl    time.Time        `json:"crawl"`
+	Category hosting.Category `json:"category"`
+	Provider string           `json:"provider"`
+	Nodes    int              `json:"nodes"`
+	// Total is the number of classified nodes in the crawl.
+	Total int `json:"total"`
+}
+
+// hostingCounter counts the nodes per provider while writing a crawl.
+type hostingCounter struct {
+	nodes map[hosting.Classification]int
+	total int
+}
+
+func newHostingCounter() *hostingCounter {
+	return &hostingCounter{nodes: make(map[hosting.Classification]int)}
+}
+
+func (h *hostingCounter) add(class hosting.Classification) {
+	h.nodes[class]++
+	h.total++
+}
+
+// insertHosting writes the per-provider node counts of a crawl, including the
+// unknown nodes. Rows are kept across restarts.
+func insertHosting(tx *sql.Tx, now time.Time, h *hostingCounter) error {
+	if h.total == 0 {
+		return nil
+	}
+	stmt, err := tx.Prepare(
+		`INSERT INTO hosting_share(
+			crawl,
+			category,
+			provider,
+			nodes,
+			total
+		) VALUES ($1,$2,$3,$4,$5)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	classes := make([]hosting.Classification, 0, len(h.nodes))
+	for class := range h.nodes {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Category != classes[j].Category {
+			return classes[i].Category < classes[j].Category
+		}
+		return classes[i].Provider < classes[j].Provider
+	})
+	for _, class := range classes {
+		if _, err := stmt.Exec(now, string(class.Category), class.Provider, h.nodes[class], h.total); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadHosting returns the per-provider node counts, of a single crawl if
+// crawl is non-zero, ordered by crawl and nodes.
+func ReadHosting(db *sql.DB, crawl time.Time) ([]HostingShare, error) {
+	query := `SELECT crawl, category, provider, nodes, total FROM hosting_share`
+	var args []any
+	if !crawl.IsZero() {
+		query += ` WHERE crawl = $1`
+		args = append(args, crawl)
+	}
+	rows, err := db.Query(query+` ORDER BY crawl, nodes DESC, category, provider`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []HostingShare
+	for rows.Next() {
+		var s HostingShare
+		if err := rows.Scan(&s.Crawl, &s.Category, &s.Provider, &s.Nodes, &s.Total); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
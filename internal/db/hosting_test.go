@@ -0,0 +1,101 @@
+package db_test
+
+import (
+	"database/sql/driver"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/hosting"
+	"github.com/200ug/peerlogger/internal/util"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestUpdateNodesClassifiesHosting(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	classifier, err := hosting.ParseClassifier(
+		[]byte(`{"providers": [{"name": "aws", "category": "cloud", "prefixes": ["3.0.0.0/8"]}]}`),
+		[]byte(`{"providers": [{"name": "hetzner", "category": "hosting", "asns": [24940]}]}`),
+	)
+	if err != nil {
+		t.Fatalf("ParseClassifier failed: %v", err)
+	}
+	asn := int64(24940)
+	geo := staticGeo{&util.GeoData{ASNumber: &asn}}
+
+	var nodes []common.NodeJSON
+	for _, ip := range []string{"3.4.0.1", "88.99.0.1", "88.99.0.2"} {
+		key, _ := crypto.GenerateKey()
+		nodes = append(nodes, common.NodeJSON{
+			N:    enode.NewV4(&key.PublicKey, net.ParseIP(ip), 30303, 30303),
+			Info: &common.ClientInfo{TotalDifficulty: big.NewInt(0)},
+		})
+	}
+
+	args := func(provider, category string) []driver.Value {
+		return nodeArgs(t, map[string]driver.Value{"hosting_provider": provider, "hosting_category": category})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args("aws", "cloud")...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args("hetzner", "hosting")...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args("hetzner", "hosting")...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO hosting_share")
+	mock.ExpectExec("INSERT INTO hosting_share").
+		WithArgs(sqlmock.AnyArg(), "cloud", "aws", 1, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO hosting_share").
+		WithArgs(sqlmock.AnyArg(), "hosting", "hetzner", 2, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, geo, nil, classifier, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestReadHosting(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	crawl := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM hosting_share WHERE crawl").WithArgs(crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"crawl", "category", "provider", "nodes", "total"}).
+			AddRow(crawl, "cloud", "aws", 30, 100).
+			AddRow(crawl, "unknown", "", 10, 100))
+
+	shares, err := db.ReadHosting(mockDB, crawl)
+	if err != nil {
+		t.Fatalf("ReadHosting failed: %v", err)
+	}
+	if len(shares) != 2 || shares[0].Category != hosting.Cloud || shares[1].Category != hosting.Unknown {
+		t.Errorf("Unexpected shares %+v", shares)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
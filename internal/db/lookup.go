@@ -0,0 +1,83 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// LookupNode returns the latest stored record of a node. Nodes without a
+// signed record in enr_history are rebuilt as unsigned v4 nodes from their
+// most recent row in the nodes table.
+func LookupNode(db *sql.DB, id string) (*enode.Node, error) {
+	var record []byte
+	err := db.QueryRow(
+		`SELECT record FROM enr_history WHERE node_id = $1 AND record IS NOT NULL
+		ORDER BY seq DESC LIMIT 1`,
+		id,
+	).Scan(&record)
+	if err == nil {
+		return decodeRecord(record)
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var (
+		pk       string
+		ip       sql.NullString
+		tcp, udp sql.NullInt32
+	)
+	err = db.QueryRow(
+		`SELECT
+			COALESCE(pk, ''),
+			host(ip),
+			tcp,
+			udp
+		FROM nodes WHERE id = $1
+		ORDER BY now DESC LIMIT 1`,
+		id,
+	).Scan(&pk, &ip, &tcp, &udp)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := parsePubkey(pk)
+	if err != nil {
+		return nil, err
+	}
+	return enode.NewV4(pub, net.ParseIP(ip.String), int(tcp.Int32), int(udp.Int32)), nil
+}
+
+// parsePubkey parses the hex encoded uncompressed pubkey written by
+// UpdateNodes, as well as the legacy "X: <int>, Y: <int>" format.
+func parsePubkey(pk string) (*ecdsa.PublicKey, error) {
+	if !isLegacyPubkey(pk) {
+		b, err := hex.DecodeString(pk)
+		if err != nil || len(b) != 64 {
+			return nil, fmt.Errorf("invalid pubkey %q", pk)
+		}
+		return crypto.UnmarshalPubkey(append([]byte{0x04}, b...))
+	}
+
+	var xs, ys string
+	if _, err := fmt.Sscanf(pk, "X: %s Y: %s", &xs, &ys); err != nil {
+		return nil, fmt.Errorf("invalid pubkey %q: %w", pk, err)
+	}
+	x, okX := new(big.Int).SetString(strings.TrimSuffix(xs, ","), 10)
+	y, okY := new(big.Int).SetString(ys, 10)
+	if !okX || !okY {
+		return nil, fmt.Errorf("invalid pubkey %q", pk)
+	}
+	pub := &ecdsa.PublicKey{Curve: crypto.S256(), X: x, Y: y}
+	if !pub.Curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("pubkey %q is not on the curve", pk)
+	}
+	return pub, nil
+}
@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestLookupNode(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	pk := fmt.Sprintf("X: %v, Y: %v", key.PublicKey.X.String(), key.PublicKey.Y.String())
+	id := "0b2d6e2a"
+
+	mock.ExpectQuery("FROM enr_history").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"record"}))
+	mock.ExpectQuery("FROM nodes WHERE id").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"pk", "ip", "tcp", "udp"}).
+			AddRow(pk, "192.0.2.1", 30303, 30301))
+
+	n, err := db.LookupNode(mockDB, id)
+	if err != nil {
+		t.Fatalf("LookupNode failed: %v", err)
+	}
+	if !n.Pubkey().Equal(&key.PublicKey) {
+		t.Errorf("Expected pubkey %v, got %v", key.PublicKey, n.Pubkey())
+	}
+	if n.IP().String() != "192.0.2.1" || n.TCP() != 30303 || n.UDP() != 30301 {
+		t.Errorf("Unexpected endpoint: %v:%d/%d", n.IP(), n.TCP(), n.UDP())
+	}
+
+	mock.ExpectQuery("FROM enr_history").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"record"}))
+	mock.ExpectQuery("FROM nodes WHERE id").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"pk", "ip", "tcp", "udp"}).
+			AddRow("X: 1, Y: 2", nil, nil, nil))
+	if _, err := db.LookupNode(mockDB, id); err == nil {
+		t.Error("Expected error for a pubkey that is not on the curve")
+	}
+
+	// Pubkeys written since the hex migration.
+	mock.ExpectQuery("FROM enr_history").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"record"}))
+	mock.ExpectQuery("FROM nodes WHERE id").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"pk", "ip", "tcp", "udp"}).
+			AddRow(hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)[1:]), "192.0.2.1", 30303, 30301))
+	if n, err = db.LookupNode(mockDB, id); err != nil || !n.Pubkey().Equal(&key.PublicKey) {
+		t.Errorf("LookupNode with hex pubkey failed: %v", err)
+	}
+
+	// Signed records from enr_history take precedence.
+	signed := signedNode(t, key, net.ParseIP("192.0.2.2"), 30303)
+	record, _ := rlp.EncodeToBytes(signed.Record())
+	mock.ExpectQuery("FROM enr_history").WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"record"}).AddRow(record))
+	n, err = db.LookupNode(mockDB, id)
+	if err != nil {
+		t.Fatalf("LookupNode failed: %v", err)
+	}
+	if n.Seq() != signed.Seq() || n.IP().String() != "192.0.2.2" {
+		t.Errorf("Expected stored record %v, got %v", signed, n)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// MigratePubkeys converts rows written before pubkeys were stored in hex:
+// "X: <int>, Y: <int>" pubkeys are rewritten as hex and the binary pubkey and
+// node ID columns are filled in. It returns the number of updated rows.
+func MigratePubkeys(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS pk_compressed BYTEA;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS pk_uncompressed BYTEA;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS node_id BYTEA;
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT pk FROM nodes WHERE pk LIKE 'X: %'`)
+	if err != nil {
+		return 0, err
+	}
+	var legacy []string
+	for rows.Next() {
+		var pk string
+		if err := rows.Scan(&pk); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		legacy = append(legacy, pk)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(
+		`UPDATE nodes SET
+			pk = $1,
+			pk_compressed = $2,
+			pk_uncompressed = $3
+		WHERE pk = $4`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var updated int64
+	for _, pk := range legacy {
+		pub, err := parsePubkey(pk)
+		if err != nil {
+			log.Warn("Skipping invalid pubkey", "pk", pk, "error", err)
+			continue
+		}
+		uncompressed := crypto.FromECDSAPub(pub)[1:]
+		res, err := stmt.Exec(hex.EncodeToString(uncompressed), crypto.CompressPubkey(pub), uncompressed, pk)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		updated += n
+	}
+
+	// Node IDs are already stored as hex in the id column.
+	res, err := tx.Exec(`UPDATE nodes SET node_id = decode(id, 'hex') WHERE node_id IS NULL AND id ~ '^[0-9a-f]{64}$'`)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	log.Info("Migrated node keys", "pubkeys", len(legacy), "rows", updated, "node_ids", n)
+
+	return updated, tx.Commit()
+}
+
+// isLegacyPubkey reports whether pk is in the "X: <int>, Y: <int>" format.
+func isLegacyPubkey(pk string) bool {
+	return strings.HasPrefix(pk, "X: ")
+}
@@ -0,0 +1,47 @@
+package db_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMigratePubkeys(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	key, _ := crypto.GenerateKey()
+	legacy := fmt.Sprintf("X: %v, Y: %v", key.PublicKey.X.String(), key.PublicKey.Y.String())
+	uncompressed := crypto.FromECDSAPub(&key.PublicKey)[1:]
+
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE nodes ADD COLUMN IF NOT EXISTS pk_compressed").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT DISTINCT pk FROM nodes").
+		WillReturnRows(sqlmock.NewRows([]string{"pk"}).AddRow(legacy).AddRow("X: 1, Y: 2"))
+	mock.ExpectPrepare("UPDATE nodes SET")
+	mock.ExpectExec("UPDATE nodes SET").
+		WithArgs(hex.EncodeToString(uncompressed), crypto.CompressPubkey(&key.PublicKey), uncompressed, legacy).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("UPDATE nodes SET node_id").WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	updated, err := db.MigratePubkeys(mockDB)
+	if err != nil {
+		t.Fatalf("MigratePubkeys failed: %v", err)
+	}
+	if updated != 3 {
+		t.Errorf("Expected 3 updated rows, got %d", updated)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
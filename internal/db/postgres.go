@@ -1,29 +1,147 @@
 package db
 
 import (
-	"bytes"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"net/netip"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/hosting"
+	"github.com/200ug/peerlogger/internal/releases"
 	"github.com/200ug/peerlogger/internal/util"
+	"github.com/200ug/peerlogger/internal/vparser"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
-	"github.com/ethereum/go-ethereum/p2p/enr"
-
-	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
-	"github.com/protolambda/ztyp/codec"
 )
 
-// ETH2 is a SSZ encoded field.
-type ETH2 []byte
+// nodeColumns are the columns written by UpdateNodes, in the order of the
+// statement arguments.
+var nodeColumns = []string{
+	"id",
+	"now",
+	"client_type",
+	"pk",
+	"software_version",
+	"capabilities",
+	"network_id",
+	"fork_id",
+	"blockheight",
+	"total_difficulty",
+	"head_hash",
+	"ip",
+	"country",
+	"city",
+	"first_seen",
+	"last_seen",
+	"seq",
+	"score",
+	"conn_type",
+	"asn",
+	"enr_unavailable",
+	"protocols",
+	"discv4_first_seen",
+	"discv4_last_seen",
+	"discv4_score",
+	"discv5_first_seen",
+	"discv5_last_seen",
+	"discv5_score",
+	"last_handshake",
+	"ip6",
+	"tcp",
+	"udp",
+	"tcp6",
+	"udp6",
+	"quic",
+	"quic6",
+	"observed_ip",
+	"observed_port",
+	"ip_private",
+	"ip_bogon",
+	"ip_mismatch",
+	"dial_family",
+	"fork_digest",
+	"fork_name",
+	"next_fork_version",
+	"next_fork_epoch",
+	"next_fork_digest",
+	"attnets",
+	"attnets_count",
+	"syncnets",
+	"syncnets_count",
+	"custody_group_count",
+	"peer_id",
+	"agent_version",
+	"libp2p_protocols",
+	"libp2p_transport",
+	"status_fork_digest",
+	"finalized_epoch",
+	"finalized_slot",
+	"head_root",
+	"head_slot",
+	"metadata_seq",
+	"last_beacon_probe",
+	"pk_compressed",
+	"pk_uncompressed",
+	"node_id",
+	"client_name",
+	"client_label",
+	"client_version",
+	"client_major",
+	"client_minor",
+	"client_patch",
+	"client_tag",
+	"client_build",
+	"client_date",
+	"client_os",
+	"client_arch",
+	"client_language",
+	"client_language_version",
+	"client_confidence",
+	"advisories",
+	"country_code",
+	"continent_code",
+	"continent",
+	"subdivision_code",
+	"subdivision",
+	"timezone",
+	"latitude",
+	"longitude",
+	"accuracy_radius",
+	"as_org",
+	"hosting_provider",
+	"hosting_category",
+	"ip_hmac",
+	"ip6_hmac",
+	"observed_ip_hmac",
+	"country6",
+	"country_code6",
+	"asn6",
+	"as_org6",
+	"hosting_provider6",
+	"hosting_category6",
+}
 
-func (v ETH2) ENRKey() string { return "eth2" }
+// insertNodes is the statement of UpdateNodes.
+var insertNodes = fmt.Sprintf(`INSERT INTO nodes(%s) VALUES (%s)`,
+	strings.Join(nodeColumns, ", "), placeholders(len(nodeColumns)))
 
-func UpdateNodes(db *sql.DB, geoipProvider *util.GeoIP, nodes []common.NodeJSON) error {
+// placeholders returns the parameter list $1,...,$n.
+func placeholders(n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(params, ",")
+}
+
+func UpdateNodes(db *sql.DB, geoipProvider util.GeoProvider, advisories *releases.Advisories, classifier *hosting.Classifier, privacy *util.IPPrivacy, nodes []common.NodeJSON) error {
 	log.Info("Writing nodes to database", "nodes", len(nodes))
 
 	now := time.Now()
@@ -31,35 +149,16 @@ func UpdateNodes(db *sql.DB, geoipProvider *util.GeoIP, nodes []common.NodeJSON)
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare(
-		`INSERT INTO nodes(
-			id,
-			now,
-			client_type,
-			pk,
-			software_version,
-			capabilities,
-			network_id,
-			fork_id,
-			blockheight,
-			total_difficulty,
-			head_hash,
-			ip,
-			country,
-			city,
-			first_seen,
-			last_seen,
-			seq,
-			score,
-			conn_type,
-			asn
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`,
-	)
+	stmt, err := tx.Prepare(insertNodes)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	unparsed := make(map[string]int)
+	exposure := newExposureCounter()
+	adoption := newAdoptionCounter()
+	hostingShares := newHostingCounter()
 	for _, n := range nodes {
 		info := &common.ClientInfo{}
 		if n.Info != nil {
@@ -69,54 +168,69 @@ func UpdateNodes(db *sql.DB, geoipProvider *util.GeoIP, nodes []common.NodeJSON)
 		if info.ClientType == "" && n.TooManyPeers {
 			info.ClientType = "tmp"
 		}
-		connType := ""
-		var portUDP enr.UDP
-		if n.N.Load(&portUDP) == nil {
-			connType = "UDP"
-		}
-		var portTCP enr.TCP
-		if n.N.Load(&portTCP) == nil {
-			connType = "TCP"
+		endpoints := common.NodeEndpoints(n.N)
+		observedIP := n.ObservedAddr.Addr().Unmap()
+		var private, bogon bool
+		for _, ip := range []netip.Addr{endpoints.IP, endpoints.IP6, observedIP} {
+			if ip.IsValid() {
+				private = private || util.IsPrivateIP(ip)
+				bogon = bogon || util.IsBogonIP(ip)
+			}
 		}
 		fid := fmt.Sprintf("Hash: %v, Next %v", info.ForkID.Hash, info.ForkID.Next)
 
-		var eth2 ETH2
-		if n.N.Load(&eth2) == nil {
+		cl := &common.ConsensusInfo{}
+		if n.Consensus != nil {
+			cl = n.Consensus
 			info.ClientType = "eth2"
-			var dat beacon.Eth2Data
-			err = dat.Deserialize(codec.NewDecodingReader(bytes.NewReader(eth2), uint64(len(eth2))))
-			if err == nil {
-				fid = fmt.Sprintf("Hash: %v, Next %v", dat.ForkDigest, dat.NextForkEpoch)
-			}
+			fid = fmt.Sprintf("Hash: %v, Next %v", cl.ForkDigest, cl.NextForkEpoch)
+		}
+		bi := &common.BeaconInfo{}
+		if n.Beacon != nil {
+			bi = n.Beacon
+		}
+		parsed, version, parseErr := parseClientName(info.ClientType, bi.AgentVersion)
+		if parseErr != nil {
+			unparsed[unparsedReason(parseErr)]++
+			log.Debug("Unparsed client name", "id", n.N.ID(), "err", parseErr)
 		}
+		matched := advisories.Match(parsed.Name, parsed.Version)
+		exposure.add(parsed.Name, matched)
+		adoption.add(parsed.Name, version)
 		var caps string
 		for _, c := range info.Capabilities {
 			caps = fmt.Sprintf("%v, %v", caps, c.String())
 		}
 		var pk string
-		if n.N.Pubkey() != nil {
-			pk = fmt.Sprintf("X: %v, Y: %v", n.N.Pubkey().X.String(), n.N.Pubkey().Y.String())
+		var pkCompressed, pkUncompressed []byte
+		if pub := n.N.Pubkey(); pub != nil {
+			pkCompressed = crypto.CompressPubkey(pub)
+			pkUncompressed = crypto.FromECDSAPub(pub)[1:]
+			pk = hex.EncodeToString(pkUncompressed)
 		}
 
+		v4, v5 := n.Observation(common.ProtocolV4), n.Observation(common.ProtocolV5)
+
 		var country, city string
+		addr, _ := netip.ParseAddr(n.N.IP().String())
+		geo, class := lookupAddr(geoipProvider, classifier, addr)
 		var asn uint64
-
-		if geoipProvider != nil {
-			addr, parseErr := netip.ParseAddr(n.N.IP().String())
-			if parseErr == nil {
-				geoData, err := geoipProvider.Lookup(addr)
-				if err == nil && geoData != nil {
-					if geoData.CountryName != nil {
-						country = *geoData.CountryName
-					}
-					if geoData.CityName != nil {
-						city = *geoData.CityName
-					}
-					if geoData.ASNumber != nil {
-						asn = uint64(*geoData.ASNumber)
-					}
-				}
-			}
+		if geo.ASNumber != nil {
+			asn = uint64(*geo.ASNumber)
+		}
+		if geo.CountryName != nil {
+			country = *geo.CountryName
+		}
+		if geo.CityName != nil {
+			city = *geo.CityName
+		}
+		if classifier != nil {
+			hostingShares.add(class)
+		}
+		// the IPv6 endpoint of dual-stack records may be hosted elsewhere
+		geo6, class6 := &util.GeoData{}, hosting.Classification{}
+		if endpoints.IP6.IsValid() {
+			geo6, class6 = lookupAddr(geoipProvider, classifier, endpoints.IP6)
 		}
 
 		_, err = stmt.Exec(
@@ -131,24 +245,269 @@ func UpdateNodes(db *sql.DB, geoipProvider *util.GeoIP, nodes []common.NodeJSON)
 			info.Blockheight,
 			info.TotalDifficulty.String(),
 			info.HeadHash.String(),
-			n.N.IP().String(),
+			nullString(privacy.Address(endpoints.IP)),
 			country,
 			city,
 			n.FirstResponse,
 			n.LastResponse,
 			n.Seq,
 			n.Score,
-			connType,
+			endpoints.Transports(),
 			asn,
+			n.ENRUnavailable,
+			pq.Array(n.SeenBy()),
+			nullTime(v4.FirstResponse),
+			nullTime(v4.LastResponse),
+			v4.Score,
+			nullTime(v5.FirstResponse),
+			nullTime(v5.LastResponse),
+			v5.Score,
+			nullTime(n.LastHandshake),
+			nullString(privacy.Address(endpoints.IP6)),
+			nullPort(endpoints.TCP),
+			nullPort(endpoints.UDP),
+			nullPort(endpoints.TCP6),
+			nullPort(endpoints.UDP6),
+			nullPort(endpoints.QUIC),
+			nullPort(endpoints.QUIC6),
+			nullString(privacy.Address(observedIP)),
+			nullPort(n.ObservedAddr.Port()),
+			private,
+			bogon,
+			endpoints.Mismatch(observedIP),
+			info.DialFamily(),
+			nullString(cl.ForkDigest),
+			nullString(cl.ForkName),
+			nullString(cl.NextForkVersion),
+			nullEpoch(cl.NextForkEpoch),
+			nullString(cl.NextForkDigest),
+			nullString(cl.Attnets),
+			cl.AttnetsCount,
+			nullString(cl.Syncnets),
+			cl.SyncnetsCount,
+			cl.CustodyGroupCount,
+			nullString(cl.PeerID),
+			nullString(bi.AgentVersion),
+			pq.Array(bi.Protocols),
+			nullString(bi.Transport),
+			nullString(bi.ForkDigest),
+			nullUint(bi.FinalizedEpoch, n.Beacon != nil),
+			nullUint(bi.FinalizedSlot(), n.Beacon != nil),
+			nullString(bi.HeadRoot),
+			nullUint(bi.HeadSlot, n.Beacon != nil),
+			nullUint(bi.MetadataSeq, n.Beacon != nil),
+			nullTime(bi.ProbedAt),
+			pkCompressed,
+			pkUncompressed,
+			n.N.ID().Bytes(),
+			nullString(parsed.Name),
+			nullString(parsed.Label),
+			nullString(version),
+			nullInt(parsed.Version.Major, version != ""),
+			nullInt(parsed.Version.Minor, version != ""),
+			nullInt(parsed.Version.Patch, version != ""),
+			nullString(parsed.Version.Tag),
+			nullString(parsed.Version.Build),
+			nullString(parsed.Version.Date),
+			nullString(parsed.Os.Os),
+			nullString(parsed.Os.Architecture),
+			nullString(parsed.Language.Name),
+			nullString(parsed.Language.Version),
+			nullString(confidence(parsed, parseErr)),
+			pq.Array(matched),
+			nullPtr(geo.CountryCode),
+			nullPtr(geo.ContinentCode),
+			nullPtr(geo.ContinentName),
+			nullPtr(geo.SubdivisionCode),
+			nullPtr(geo.SubdivisionName),
+			nullPtr(geo.TimeZone),
+			nullPtr(geo.Latitude),
+			nullPtr(geo.Longitude),
+			nullPtr(geo.AccuracyRadius),
+			nullPtr(geo.ASOrganization),
+			nullString(class.Provider),
+			nullString(string(class.Category)),
+			nullString(privacy.Hash(endpoints.IP)),
+			nullString(privacy.Hash(endpoints.IP6)),
+			nullString(privacy.Hash(observedIP)),
+			nullPtr(geo6.CountryName),
+			nullPtr(geo6.CountryCode),
+			nullPtr(geo6.ASNumber),
+			nullPtr(geo6.ASOrganization),
+			nullString(class6.Provider),
+			nullString(string(class6.Category)),
 		)
 		if err != nil {
 			return err
 		}
 	}
 
+	for reason, count := range unparsed {
+		log.Warn("Client names not parsed", "reason", reason, "count", count)
+	}
+
+	if err := insertExposure(tx, now, exposure); err != nil {
+		return err
+	}
+	if err := insertAdoption(tx, now, adoption); err != nil {
+		return err
+	}
+	if err := insertHosting(tx, now, hostingShares); err != nil {
+		return err
+	}
+	if err := insertNeighbors(tx, now, nodes); err != nil {
+		return err
+	}
+	if err := insertRecords(tx, now, privacy, nodes); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// parseClientName parses the devp2p Hello name, or the libp2p agent version
+// of consensus-layer nodes. Nodes without a name are not an error.
+func parseClientName(clientType, agentVersion string) (vparser.ParsedInfo, string, error) {
+	name := clientType
+	if name == "tmp" || name == "eth2" {
+		name = agentVersion
+	}
+	if name == "" {
+		return vparser.ParsedInfo{}, "", nil
+	}
+	parsed, err := vparser.ParseVersionString(name)
+	if err != nil {
+		return vparser.ParsedInfo{}, "", fmt.Errorf("client name %q: %w", name, err)
+	}
+	var version string
+	if parsed.Version != (vparser.Version{}) {
+		version = parsed.Version.Semver()
+	}
+	return parsed, version, nil
+}
+
+// unparsedReason groups parse errors by their cause for the batch summary.
+func unparsedReason(err error) string {
+	for _, reason := range []error{vparser.ErrEnode, vparser.ErrUnknownFormat, vparser.ErrInvalidVersion} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return err.Error()
+}
+
+// confidence returns the value of the client_confidence column, NULL for
+// nodes without a client name.
+func confidence(parsed vparser.ParsedInfo, err error) string {
+	if err == nil && parsed.Name == "" {
+		return ""
+	}
+	return parsed.Confidence.String()
+}
+
+// insertNeighbors writes the routing table entries collected in table crawl mode.
+func insertNeighbors(tx *sql.Tx, now time.Time, nodes []common.NodeJSON) error {
+	var edges int
+	for _, n := range nodes {
+		edges += len(n.Neighbors)
+	}
+	if edges == 0 {
+		return nil
+	}
+	log.Info("Writing neighbors to database", "edges", edges)
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO neighbors(
+			node_id,
+			neighbor_id,
+			now,
+			distance,
+			protocol
+		) VALUES ($1,$2,$3,$4,$5)
+		ON CONFLICT DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, n := range nodes {
+		for _, nb := range n.Neighbors {
+			_, err = stmt.Exec(
+				n.N.ID().String(),
+				nb.ID.String(),
+				now,
+				nb.Distance,
+				nb.Protocol,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nullTime maps unset timestamps to NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullString maps empty strings to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// lookupAddr returns the geo data and hosting classification of an address.
+// The geo data is empty if the address is invalid or unknown to the provider,
+// the classification if there is no classifier.
+func lookupAddr(geoipProvider util.GeoProvider, classifier *hosting.Classifier, addr netip.Addr) (*util.GeoData, hosting.Classification) {
+	geo := &util.GeoData{}
+	if geoipProvider != nil && addr.IsValid() {
+		if geoData, err := geoipProvider.Lookup(addr); err == nil && geoData != nil {
+			geo = geoData
+		}
+	}
+	var class hosting.Classification
+	if classifier != nil {
+		var asn int64
+		if geo.ASNumber != nil {
+			asn = *geo.ASNumber
+		}
+		class = classifier.Classify(addr, asn)
+	}
+	return geo, class
+}
+
+// nullEpoch maps unset and FAR_FUTURE_EPOCH (no fork scheduled) to NULL,
+// the latter doesn't fit into BIGINT anyway.
+func nullEpoch(epoch uint64) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(epoch), Valid: epoch != 0 && epoch < math.MaxInt64}
+}
+
+// nullUint maps values that were not collected or don't fit into BIGINT to NULL.
+func nullUint(v uint64, valid bool) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: valid && v <= math.MaxInt64}
+}
+
+// nullInt maps values that were not collected to NULL.
+func nullInt(v int, valid bool) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: valid}
+}
+
+// nullPtr maps nil pointers to NULL.
+func nullPtr[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// nullPort maps unset ports to NULL.
+func nullPort(port uint16) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(port), Valid: port != 0}
+}
+
 func CreateDB(db *sql.DB) error {
 	sqlStmt := `
 	CREATE TABLE IF NOT EXISTS nodes (
@@ -172,9 +531,269 @@ func CreateDB(db *sql.DB) error {
 		score           BIGINT,
 		conn_type       TEXT,
 		asn             BIGINT,
+		enr_unavailable BOOLEAN,
+		protocols       TEXT[],
+		discv4_first_seen TIMESTAMP,
+		discv4_last_seen TIMESTAMP,
+		discv4_score    BIGINT,
+		discv5_first_seen TIMESTAMP,
+		discv5_last_seen TIMESTAMP,
+		discv5_score    BIGINT,
+		last_handshake  TIMESTAMP,
+		ip6             INET,
+		tcp             INT,
+		udp             INT,
+		tcp6            INT,
+		udp6            INT,
+		quic            INT,
+		quic6           INT,
+		observed_ip     INET,
+		observed_port   INT,
+		ip_private      BOOLEAN,
+		ip_bogon        BOOLEAN,
+		ip_mismatch     BOOLEAN,
+		dial_family     TEXT,
+		fork_digest     TEXT,
+		fork_name       TEXT,
+		next_fork_version TEXT,
+		next_fork_epoch BIGINT,
+		next_fork_digest TEXT,
+		attnets         TEXT,
+		attnets_count   INT,
+		syncnets        TEXT,
+		syncnets_count  INT,
+		custody_group_count BIGINT,
+		peer_id         TEXT,
+		agent_version   TEXT,
+		libp2p_protocols TEXT[],
+		libp2p_transport TEXT,
+		status_fork_digest TEXT,
+		finalized_epoch BIGINT,
+		finalized_slot  BIGINT,
+		head_root       TEXT,
+		head_slot       BIGINT,
+		metadata_seq    BIGINT,
+		last_beacon_probe TIMESTAMP,
+		pk_compressed   BYTEA,
+		pk_uncompressed BYTEA,
+		node_id         BYTEA,
+		client_name     TEXT,
+		client_label    TEXT,
+		client_version  TEXT,
+		client_major    INT,
+		client_minor    INT,
+		client_patch    INT,
+		client_tag      TEXT,
+		client_build    TEXT,
+		client_date     TEXT,
+		client_os       TEXT,
+		client_arch     TEXT,
+		client_language TEXT,
+		client_language_version TEXT,
+		client_confidence TEXT,
+		advisories      TEXT[],
+		country_code    TEXT,
+		continent_code  TEXT,
+		continent       TEXT,
+		subdivision_code TEXT,
+		subdivision     TEXT,
+		timezone        TEXT,
+		latitude        DOUBLE PRECISION,
+		longitude       DOUBLE PRECISION,
+		accuracy_radius INT,
+		as_org          TEXT,
+		hosting_provider TEXT,
+		hosting_category TEXT,
+		ip_hmac         TEXT,
+		ip6_hmac        TEXT,
+		observed_ip_hmac TEXT,
+		country6        TEXT,
+		country_code6   TEXT,
+		asn6            BIGINT,
+		as_org6         TEXT,
+		hosting_provider6 TEXT,
+		hosting_category6 TEXT,
 		PRIMARY KEY (id, now)
 	);
+	-- columns added after the initial schema (no-op for fresh databases)
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS enr_unavailable BOOLEAN;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS protocols TEXT[];
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv4_first_seen TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv4_last_seen TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv4_score BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv5_first_seen TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv5_last_seen TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS discv5_score BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS last_handshake TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip6 INET;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS tcp INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS udp INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS tcp6 INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS udp6 INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS quic INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS quic6 INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS observed_ip INET;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS observed_port INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip_private BOOLEAN;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip_bogon BOOLEAN;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip_mismatch BOOLEAN;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS dial_family TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS fork_digest TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS fork_name TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS next_fork_version TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS next_fork_epoch BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS next_fork_digest TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS attnets TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS attnets_count INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS syncnets TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS syncnets_count INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS custody_group_count BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS peer_id TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS agent_version TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS libp2p_protocols TEXT[];
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS libp2p_transport TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS status_fork_digest TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS finalized_epoch BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS finalized_slot BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS head_root TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS head_slot BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS metadata_seq BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS last_beacon_probe TIMESTAMP;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS pk_compressed BYTEA;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS pk_uncompressed BYTEA;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS node_id BYTEA;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_name TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_label TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_version TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_major INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_minor INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_patch INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_tag TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_build TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_date TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_os TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_arch TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_language TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_language_version TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS client_confidence TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS advisories TEXT[];
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS country_code TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS continent_code TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS continent TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS subdivision_code TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS subdivision TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS timezone TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS accuracy_radius INT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS as_org TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS hosting_provider TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS hosting_category TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip_hmac TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS ip6_hmac TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS observed_ip_hmac TEXT;
+	-- geo and hosting data of the IPv6 endpoint of dual-stack records
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS country6 TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS country_code6 TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS asn6 BIGINT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS as_org6 TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS hosting_provider6 TEXT;
+	ALTER TABLE nodes ADD COLUMN IF NOT EXISTS hosting_category6 TEXT;
+	CREATE INDEX IF NOT EXISTS nodes_node_id_idx ON nodes (node_id);
+	CREATE INDEX IF NOT EXISTS nodes_pk_idx ON nodes (pk);
+	CREATE TABLE IF NOT EXISTS neighbors (
+		node_id         TEXT NOT NULL,
+		neighbor_id     TEXT NOT NULL,
+		now             TIMESTAMP NOT NULL,
+		distance        INT,
+		protocol        TEXT NOT NULL,
+		PRIMARY KEY (node_id, neighbor_id, protocol, now)
+	);
+	CREATE TABLE IF NOT EXISTS enr_history (
+		node_id         TEXT NOT NULL,
+		seq             BIGINT NOT NULL,
+		-- raw records are only kept in the full privacy mode
+		enr             TEXT,
+		record          BYTEA,
+		fields          TEXT,
+		first_seen      TIMESTAMP NOT NULL,
+		PRIMARY KEY (node_id, seq)
+	);
+	CREATE TABLE IF NOT EXISTS enr_changes (
+		node_id         TEXT NOT NULL,
+		seq             BIGINT NOT NULL,
+		prev_seq        BIGINT NOT NULL,
+		detected        TIMESTAMP NOT NULL,
+		kind            TEXT NOT NULL,
+		field           TEXT NOT NULL,
+		old_value       TEXT,
+		new_value       TEXT,
+		PRIMARY KEY (node_id, seq, field)
+	);
+	CREATE TABLE IF NOT EXISTS advisory_exposure (
+		crawl           TIMESTAMP NOT NULL,
+		advisory        TEXT NOT NULL,
+		client          TEXT NOT NULL,
+		nodes           INT NOT NULL,
+		total           INT NOT NULL,
+		PRIMARY KEY (crawl, advisory, client)
+	);
+	CREATE TABLE IF NOT EXISTS release_adoption (
+		crawl           TIMESTAMP NOT NULL,
+		client          TEXT NOT NULL,
+		version         TEXT NOT NULL,
+		nodes           INT NOT NULL,
+		client_nodes    INT NOT NULL,
+		crawl_nodes     INT NOT NULL,
+		PRIMARY KEY (crawl, client, version)
+	);
+	CREATE INDEX IF NOT EXISTS release_adoption_client_idx ON release_adoption (client, crawl);
+	CREATE TABLE IF NOT EXISTS release_first_seen (
+		client          TEXT NOT NULL,
+		version         TEXT NOT NULL,
+		first_seen      TIMESTAMP NOT NULL,
+		PRIMARY KEY (client, version)
+	);
+	CREATE TABLE IF NOT EXISTS hosting_share (
+		crawl           TIMESTAMP NOT NULL,
+		category        TEXT NOT NULL,
+		provider        TEXT NOT NULL,
+		nodes           INT NOT NULL,
+		total           INT NOT NULL,
+		PRIMARY KEY (crawl, category, provider)
+	);
+	CREATE TABLE IF NOT EXISTS blacklist_entries (
+		kind            TEXT NOT NULL,
+		entry           TEXT NOT NULL,
+		reason          TEXT,
+		expires         TIMESTAMP,
+		added_by        TEXT NOT NULL,
+		added           TIMESTAMP NOT NULL,
+		PRIMARY KEY (kind, entry)
+	);
+	CREATE TABLE IF NOT EXISTS blacklist_audit (
+		at              TIMESTAMP NOT NULL,
+		actor           TEXT NOT NULL,
+		action          TEXT NOT NULL,
+		kind            TEXT NOT NULL,
+		entry           TEXT NOT NULL,
+		reason          TEXT,
+		expires         TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS blacklist_audit_at_idx ON blacklist_audit (at);
+	CREATE TABLE IF NOT EXISTS sybil_findings (
+		detected        TIMESTAMP NOT NULL,
+		kind            TEXT NOT NULL,
+		key             TEXT NOT NULL,
+		nodes           INT NOT NULL,
+		ips             INT NOT NULL,
+		threshold       INT NOT NULL,
+		node_ids        TEXT[],
+		blacklisted     BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	CREATE INDEX IF NOT EXISTS sybil_findings_detected_idx ON sybil_findings (detected);
 	DELETE FROM nodes;
+	DELETE FROM neighbors;
 	`
 	_, err := db.Exec(sqlStmt)
 	return err
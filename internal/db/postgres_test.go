@@ -1,8 +1,11 @@
 package db_test
 
 import (
+	"database/sql/driver"
 	"math/big"
 	"net"
+	"net/netip"
+	"slices"
 	"testing"
 	"time"
 
@@ -10,8 +13,10 @@ import (
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/200ug/peerlogger/internal/common"
 	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/hosting"
 	"github.com/200ug/peerlogger/internal/util"
 )
 
@@ -57,7 +62,7 @@ func TestUpdateNodes(t *testing.T) {
 	}
 
 	// Test the UpdateNodes function
-	err = db.UpdateNodes(mockDB, geoIP, nodes)
+	err = db.UpdateNodes(mockDB, geoIP, nil, nil, nil, nodes)
 	if err != nil {
 		t.Errorf("UpdateNodes failed: %v", err)
 	}
@@ -104,7 +109,7 @@ func TestUpdateNodesWithNilGeoIP(t *testing.T) {
 	}
 
 	// Test with nil GeoIP provider
-	err = db.UpdateNodes(mockDB, nil, nodes)
+	err = db.UpdateNodes(mockDB, nil, nil, nil, nil, nodes)
 	if err != nil {
 		t.Errorf("UpdateNodes failed: %v", err)
 	}
@@ -134,4 +139,265 @@ func TestCreateDB(t *testing.T) {
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Mock expectations not met: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestUpdateNodesWithNeighbors(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	// Create test node data with two routing table entries
+	privKey, _ := crypto.GenerateKey()
+	testNode := enode.NewV4(&privKey.PublicKey, net.ParseIP("8.8.8.8"), 30303, 30303)
+	neighborKey, _ := crypto.GenerateKey()
+	neighbor := enode.NewV4(&neighborKey.PublicKey, net.ParseIP("1.1.1.1"), 30303, 30303)
+
+	nodes := []common.NodeJSON{
+		{
+			N:     testNode,
+			Seq:   1,
+			Score: 10,
+			Neighbors: []common.Neighbor{
+				{ID: neighbor.ID(), Distance: enode.LogDist(testNode.ID(), neighbor.ID()), Protocol: "discv5"},
+				{ID: neighbor.ID(), Distance: enode.LogDist(testNode.ID(), neighbor.ID()), Protocol: "discv4"},
+			},
+		},
+	}
+
+	// Mock the transaction, node insert and neighbor inserts
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO neighbors")
+	mock.ExpectExec("INSERT INTO neighbors").
+		WithArgs(testNode.ID().String(), neighbor.ID().String(), sqlmock.AnyArg(), sqlmock.AnyArg(), "discv5").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO neighbors").
+		WithArgs(testNode.ID().String(), neighbor.ID().String(), sqlmock.AnyArg(), sqlmock.AnyArg(), "discv4").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = db.UpdateNodes(mockDB, nil, nil, nil, nil, nodes)
+	if err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestUpdateNodesParsesClientName(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	privKey, _ := crypto.GenerateKey()
+	testNode := enode.NewV4(&privKey.PublicKey, net.ParseIP("8.8.8.8"), 30303, 30303)
+	nodes := []common.NodeJSON{
+		{
+			N:     testNode,
+			Seq:   1,
+			Score: 10,
+			Info: &common.ClientInfo{
+				ClientType:      "Geth/v1.16.2-stable-a1b2c3d4/linux-amd64/go1.24.5",
+				TotalDifficulty: big.NewInt(0),
+			},
+		},
+	}
+
+	// Only the client columns are checked.
+	args := nodeArgs(t, map[string]driver.Value{
+		"client_type":             "Geth/v1.16.2-stable-a1b2c3d4/linux-amd64/go1.24.5",
+		"client_name":             "geth",
+		"client_label":            nil,
+		"client_version":          "1.16.2",
+		"client_major":            int64(1),
+		"client_minor":            int64(16),
+		"client_patch":            int64(2),
+		"client_tag":              "stable",
+		"client_build":            "a1b2c3d4",
+		"client_date":             nil,
+		"client_os":               "linux",
+		"client_arch":             "amd64",
+		"client_language":         "go",
+		"client_language_version": "1.24.5",
+		"client_confidence":       "high",
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args...).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAdoption(mock, []any{"geth", "1.16.2", 1, 1, 1})
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, nil, nil, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+// nodeArgs returns the expected UpdateNodes arguments of a node by column
+// name. Columns which aren't given match any value.
+func nodeArgs(t *testing.T, values map[string]driver.Value) []driver.Value {
+	t.Helper()
+	for col := range values {
+		if !slices.Contains(db.NodeColumns, col) {
+			t.Fatalf("UpdateNodes doesn't write column %q", col)
+		}
+	}
+	args := make([]driver.Value, len(db.NodeColumns))
+	for i, col := range db.NodeColumns {
+		if v, ok := values[col]; ok {
+			args[i] = v
+		} else {
+			args[i] = sqlmock.AnyArg()
+		}
+	}
+	return args
+}
+
+type staticGeo struct {
+	data *util.GeoData
+}
+
+func (s staticGeo) Lookup(netip.Addr) (*util.GeoData, error) { return s.data, nil }
+func (s staticGeo) Close() error                             { return nil }
+
+func TestUpdateNodesStoresGeoData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	country, code, continentCode, continent := "Germany", "DE", "EU", "Europe"
+	subCode, sub, tz, org := "SN", "Saxony", "Europe/Berlin", "Hetzner Online GmbH"
+	lat, lon, radius, asn := 50.4777, 12.3649, uint16(20), int64(24940)
+	geo := staticGeo{&util.GeoData{
+		CountryName: &country, CountryCode: &code, ASNumber: &asn,
+		ContinentCode: &continentCode, ContinentName: &continent,
+		SubdivisionCode: &subCode, SubdivisionName: &sub, TimeZone: &tz,
+		Latitude: &lat, Longitude: &lon, AccuracyRadius: &radius, ASOrganization: &org,
+	}}
+
+	privKey, _ := crypto.GenerateKey()
+	nodes := []common.NodeJSON{{
+		N:    enode.NewV4(&privKey.PublicKey, net.ParseIP("88.99.0.1"), 30303, 30303),
+		Info: &common.ClientInfo{TotalDifficulty: big.NewInt(0)},
+	}}
+
+	args := nodeArgs(t, map[string]driver.Value{
+		"country":          "Germany",
+		"city":             "",
+		"asn":              int64(24940),
+		"country_code":     "DE",
+		"continent_code":   "EU",
+		"continent":        "Europe",
+		"subdivision_code": "SN",
+		"subdivision":      "Saxony",
+		"timezone":         "Europe/Berlin",
+		"latitude":         lat,
+		"longitude":        lon,
+		"accuracy_radius":  int64(20),
+		"as_org":           org,
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(args...).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, geo, nil, nil, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+// addrGeo resolves each address to its own geo data.
+type addrGeo map[netip.Addr]*util.GeoData
+
+func (g addrGeo) Lookup(ip netip.Addr) (*util.GeoData, error) { return g[ip], nil }
+func (addrGeo) Close() error                                  { return nil }
+
+func TestUpdateNodesStoresIPv6GeoData(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	classifier, err := hosting.ParseClassifier(
+		[]byte(`{"providers": [{"name": "aws", "category": "cloud", "prefixes": ["2600:1f00::/24"]}]}`),
+		[]byte(`{"providers": [{"name": "hetzner", "category": "hosting", "asns": [24940]}]}`),
+	)
+	if err != nil {
+		t.Fatalf("ParseClassifier failed: %v", err)
+	}
+	de, us := "DE", "US"
+	hetzner, amazon := int64(24940), int64(16509)
+	geo := addrGeo{
+		netip.MustParseAddr("88.99.0.1"):    {CountryCode: &de, ASNumber: &hetzner},
+		netip.MustParseAddr("2600:1f18::1"): {CountryCode: &us, ASNumber: &amazon},
+	}
+
+	key, _ := crypto.GenerateKey()
+	dual := signedNode(t, key, net.ParseIP("88.99.0.1"), 30303, enr.IPv6(net.ParseIP("2600:1f18::1")))
+	key, _ = crypto.GenerateKey()
+	nodes := []common.NodeJSON{
+		{N: dual, Info: &common.ClientInfo{TotalDifficulty: big.NewInt(0)}},
+		{N: enode.NewV4(&key.PublicKey, net.ParseIP("88.99.0.1"), 30303, 30303),
+			Info: &common.ClientInfo{TotalDifficulty: big.NewInt(0)}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO nodes")
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(nodeArgs(t, map[string]driver.Value{
+		"country_code":      "DE",
+		"asn":               int64(24940),
+		"hosting_provider":  "hetzner",
+		"country_code6":     "US",
+		"asn6":              int64(16509),
+		"hosting_provider6": "aws",
+		"hosting_category6": "cloud",
+	})...).WillReturnResult(sqlmock.NewResult(1, 1))
+	// records without an IPv6 endpoint leave its columns empty
+	mock.ExpectExec("INSERT INTO nodes").WithArgs(nodeArgs(t, map[string]driver.Value{
+		"country_code6":     nil,
+		"asn6":              nil,
+		"hosting_provider6": nil,
+		"hosting_category6": nil,
+	})...).WillReturnResult(sqlmock.NewResult(1, 1))
+	// the hosting shares count each node once, by its primary endpoint
+	mock.ExpectPrepare("INSERT INTO hosting_share")
+	mock.ExpectExec("INSERT INTO hosting_share").
+		WithArgs(sqlmock.AnyArg(), "hosting", "hetzner", 2, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectPrepare("INSERT INTO enr_history")
+	mock.ExpectPrepare("SELECT seq, fields FROM enr_history")
+	mock.ExpectPrepare("INSERT INTO enr_changes")
+	mock.ExpectExec("INSERT INTO enr_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err := db.UpdateNodes(mockDB, geo, nil, classifier, nil, nodes); err != nil {
+		t.Errorf("UpdateNodes failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/200ug/peerlogger/internal/sybil"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// ipColumns are the node columns holding IPs, with the columns of their HMACs.
+var ipColumns = []struct{ addr, hash string }{
+	{"ip", "ip_hmac"},
+	{"ip6", "ip6_hmac"},
+	{"observed_ip", "observed_ip_hmac"},
+}
+
+// Anonymized is the number of rows changed by AnonymizeIPs.
+type Anonymized struct {
+	// Nodes counts node rows once per anonymised IP column.
+	Nodes   int64 `json:"nodes"`
+	Changes int64 `json:"changes"`
+	// Records counts ENR history rows whose signed record was dropped.
+	Records int64 `json:"records"`
+	// Findings counts sybil findings whose IP or prefix key was anonymised.
+	Findings int64 `json:"findings"`
+}
+
+// AnonymizeIPs applies the privacy mode to the data written before the given
+// time: the IPs of node rows, ENR change events and sybil findings are
+// replaced, and stored records are dropped from the ENR history as their
+// signed content can't be altered, keeping their anonymised fields. Rows which
+// are already anonymised are skipped, so the job can run repeatedly.
+func AnonymizeIPs(db *sql.DB, privacy *util.IPPrivacy, before time.Time) (Anonymized, error) {
+	var result Anonymized
+	if privacy == nil || privacy.Mode == util.PrivacyFull {
+		return result, errors.New("retention requires an anonymising privacy mode")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, col := range ipColumns {
+		cond := col.addr + " IS NOT NULL"
+		args := []any{before}
+		if privacy.Mode == util.PrivacyTruncated {
+			// skip rows truncated at ingestion or by an earlier run
+			cond = fmt.Sprintf("masklen(%[1]s) > CASE family(%[1]s) WHEN 4 THEN $2 ELSE $3 END", col.addr)
+			args = append(args, privacy.Bits4, privacy.Bits6)
+		}
+		addrs, err := queryStrings(tx, fmt.Sprintf(
+			`SELECT DISTINCT %s::text FROM nodes WHERE now < $1 AND %s`, col.addr, cond),
+			args...,
+		)
+		if err != nil {
+			return result, err
+		}
+		stmt, err := tx.Prepare(fmt.Sprintf(
+			`UPDATE nodes SET
+				%[1]s = $1,
+				%[2]s = COALESCE(%[2]s, $2)
+			WHERE %[1]s = $3::inet AND now < $4`, col.addr, col.hash),
+		)
+		if err != nil {
+			return result, err
+		}
+		for _, addr := range addrs {
+			prefix, err := netip.ParsePrefix(addr)
+			if err != nil {
+				continue
+			}
+			ip := prefix.Addr()
+			res, err := stmt.Exec(nullString(privacy.Address(ip)), nullString(privacy.Hash(ip)), addr, before)
+			if err != nil {
+				stmt.Close()
+				return result, err
+			}
+			n, _ := res.RowsAffected()
+			result.Nodes += n
+		}
+		stmt.Close()
+	}
+
+	for _, col := range []string{"old_value", "new_value"} {
+		values, err := queryStrings(tx, fmt.Sprintf(
+			`SELECT DISTINCT %[1]s FROM enr_changes
+			WHERE kind = $1 AND detected < $2 AND %[1]s IS NOT NULL`, col),
+			ChangeIP, before,
+		)
+		if err != nil {
+			return result, err
+		}
+		stmt, err := tx.Prepare(fmt.Sprintf(
+			`UPDATE enr_changes SET %[1]s = $1
+			WHERE kind = $2 AND detected < $3 AND %[1]s = $4`, col),
+		)
+		if err != nil {
+			return result, err
+		}
+		for _, v := range values {
+			anonymized := anonymizeValue(privacy, v)
+			if anonymized == v {
+				continue
+			}
+			res, err := stmt.Exec(anonymized, ChangeIP, before, v)
+			if err != nil {
+				stmt.Close()
+				return result, err
+			}
+			n, _ := res.RowsAffected()
+			result.Changes += n
+		}
+		stmt.Close()
+	}
+
+	for _, kind := range []sybil.Kind{sybil.IP, sybil.Prefix} {
+		keys, err := queryStrings(tx,
+			`SELECT DISTINCT key FROM sybil_findings WHERE kind = $1 AND detected < $2`,
+			string(kind), before,
+		)
+		if err != nil {
+			return result, err
+		}
+		stmt, err := tx.Prepare(
+			`UPDATE sybil_findings SET key = $1
+			WHERE kind = $2 AND detected < $3 AND key = $4`,
+		)
+		if err != nil {
+			return result, err
+		}
+		for _, key := range keys {
+			anonymized := sybil.AnonymizeKey(privacy, kind, key)
+			if anonymized == key {
+				continue
+			}
+			res, err := stmt.Exec(anonymized, string(kind), before, key)
+			if err != nil {
+				stmt.Close()
+				return result, err
+			}
+			n, _ := res.RowsAffected()
+			result.Findings += n
+		}
+		stmt.Close()
+	}
+
+	if result.Records, err = anonymizeRecords(tx, privacy, before); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	log.Info("Anonymised stored IPs", "before", before, "mode", privacy.Mode,
+		"nodes", result.Nodes, "changes", result.Changes, "records", result.Records, "findings", result.Findings)
+	return result, nil
+}
+
+// anonymizeRecords drops the signed records of the ENR history written before
+// the given time, which can't be altered, and keeps their fields with the IPs
+// anonymised, so the history stays comparable to the rows written in the
+// anonymising modes.
+func anonymizeRecords(tx *sql.Tx, privacy *util.IPPrivacy, before time.Time) (int64, error) {
+	type row struct {
+		id     string
+		seq    int64
+		fields sql.NullString
+	}
+	rows, err := tx.Query(
+		`SELECT node_id, seq, fields FROM enr_history
+		WHERE first_seen < $1 AND record IS NOT NULL`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var records []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.seq, &r.fields); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		records = append(records, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(
+		`UPDATE enr_history SET enr = NULL, record = NULL, fields = $1
+		WHERE node_id = $2 AND seq = $3`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var anonymized int64
+	for _, r := range records {
+		var fields any
+		if values, err := storedFields(r.fields); err == nil && values != nil {
+			anonymizeFields(privacy, values)
+			encoded, err := json.Marshal(values)
+			if err != nil {
+				return anonymized, err
+			}
+			fields = string(encoded)
+		} else {
+			log.Warn("Dropping ENR record with invalid fields", "id", r.id, "seq", r.seq, "err", err)
+		}
+		res, err := stmt.Exec(fields, r.id, r.seq)
+		if err != nil {
+			return anonymized, err
+		}
+		n, _ := res.RowsAffected()
+		anonymized += n
+	}
+	return anonymized, nil
+}
+
+// queryStrings returns the single string column of a query.
+func queryStrings(tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,138 @@
+package db_test
+
+import (
+	"database/sql/driver"
+	"math/big"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/util"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestUpdateNodesAppliesPrivacy(t *testing.T) {
+	privacy, err := util.NewIPPrivacy(util.PrivacyHMAC, "secret", 24, 48)
+	if err != nil {
+		t.Fatalf("NewIPPrivacy failed: %v", err)
+	}
+	truncated, _ := util.NewIPPrivacy(util.PrivacyTruncated, "", 24, 48)
+
+	privKey, _ := crypto.GenerateKey()
+	ip := net.ParseIP("88.99.10.20")
+	nodes := []common.NodeJSON{{
+		N:    enode.NewV4(&privKey.PublicKey, ip, 30303, 30303),
+		Info: &common.ClientInfo{TotalDifficulty: big.NewInt(0)},
+	}}
+	hash := privacy.Hash(netip.MustParseAddr("88.99.10.20"))
+
+	for _, tt := range []struct {
+		privacy    *util.IPPrivacy
+		ip, ipHash any
+	}{
+		{privacy, nil, hash},
+		{truncated, "88.99.10.0/24", nil},
+		{nil, "88.99.10.20", nil},
+	} {
+		mockDB, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+
+		args := nodeArgs(t, map[string]driver.Value{"ip": tt.ip, "ip_hmac": tt.ipHash})
+
+		mock.ExpectBegin()
+		mock.ExpectPrepare("INSERT INTO nodes")
+		mock.ExpectExec("INSERT INTO nodes").WithArgs(args...).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		if err := db.UpdateNodes(mockDB, nil, nil, nil, tt.privacy, nodes); err != nil {
+			t.Errorf("UpdateNodes failed: %v", err)
+		}
+
+		// Verify all expectations were met
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Mock expectations not met: %v", err)
+		}
+		mockDB.Close()
+	}
+}
+
+func TestAnonymizeIPs(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	privacy, _ := util.NewIPPrivacy(util.PrivacyTruncated, "", 24, 48)
+	before := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT DISTINCT ip::text FROM nodes").WithArgs(before, 24, 48).
+		WillReturnRows(sqlmock.NewRows([]string{"ip"}).AddRow("88.99.10.20/32"))
+	mock.ExpectPrepare("UPDATE nodes SET")
+	mock.ExpectExec("UPDATE nodes SET").WithArgs("88.99.10.0/24", nil, "88.99.10.20/32", before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("SELECT DISTINCT ip6::text FROM nodes").WithArgs(before, 24, 48).
+		WillReturnRows(sqlmock.NewRows([]string{"ip6"}))
+	mock.ExpectPrepare("UPDATE nodes SET")
+	mock.ExpectQuery("SELECT DISTINCT observed_ip::text FROM nodes").WithArgs(before, 24, 48).
+		WillReturnRows(sqlmock.NewRows([]string{"observed_ip"}))
+	mock.ExpectPrepare("UPDATE nodes SET")
+	mock.ExpectQuery("SELECT DISTINCT old_value FROM enr_changes").WithArgs(db.ChangeIP, before).
+		WillReturnRows(sqlmock.NewRows([]string{"old_value"}).AddRow("2a01:4f8:10a:1::2").AddRow("88.99.10.0/24"))
+	mock.ExpectPrepare("UPDATE enr_changes SET old_value")
+	mock.ExpectExec("UPDATE enr_changes SET old_value").
+		WithArgs("2a01:4f8:10a::/48", db.ChangeIP, before, "2a01:4f8:10a:1::2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT DISTINCT new_value FROM enr_changes").WithArgs(db.ChangeIP, before).
+		WillReturnRows(sqlmock.NewRows([]string{"new_value"}))
+	mock.ExpectPrepare("UPDATE enr_changes SET new_value")
+	mock.ExpectQuery("SELECT DISTINCT key FROM sybil_findings").WithArgs("ip", before).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("198.51.100.7"))
+	mock.ExpectPrepare("UPDATE sybil_findings SET key")
+	mock.ExpectExec("UPDATE sybil_findings SET key").
+		WithArgs("198.51.100.0/24", "ip", before, "198.51.100.7").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT DISTINCT key FROM sybil_findings").WithArgs("prefix", before).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("198.51.100.0/24"))
+	mock.ExpectPrepare("UPDATE sybil_findings SET key")
+	// only rows with a signed record are touched, anonymised rows are kept
+	mock.ExpectQuery("SELECT node_id, seq, fields FROM enr_history WHERE first_seen < \\$1 AND record IS NOT NULL").
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "seq", "fields"}).
+			AddRow("a3f0", 7, `{"ip":"88.99.10.20","udp":"30303"}`).
+			AddRow("b4e1", 2, `{"ip6":"2a01:4f8:10a:1::2"}`))
+	mock.ExpectPrepare("UPDATE enr_history SET enr = NULL, record = NULL")
+	mock.ExpectExec("UPDATE enr_history SET enr = NULL, record = NULL").
+		WithArgs(`{"ip":"88.99.10.0/24","udp":"30303"}`, "a3f0", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE enr_history SET enr = NULL, record = NULL").
+		WithArgs(`{"ip6":"2a01:4f8:10a::/48"}`, "b4e1", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := db.AnonymizeIPs(mockDB, privacy, before)
+	if err != nil {
+		t.Fatalf("AnonymizeIPs failed: %v", err)
+	}
+	if result != (db.Anonymized{Nodes: 3, Changes: 1, Records: 2, Findings: 2}) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	full, _ := util.NewIPPrivacy(util.PrivacyFull, "", 24, 48)
+	if _, err := db.AnonymizeIPs(mockDB, full, before); err == nil {
+		t.Error("Expected an error for the full mode")
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
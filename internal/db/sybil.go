@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/lib/pq"
+
+	"github.com/200ug/peerlogger/internal/sybil"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// SybilFinding is a stored finding of the sybil detector.
+type SybilFinding struct {
+	Detected time.Time `json:"detected"`
+	sybil.Finding
+	// Blacklisted is set if the offenders were added to the blacklist.
+	Blacklisted bool `json:"blacklisted"`
+}
+
+// InsertSybilFindings writes the findings of a detector run. The key of IP
+// and prefix findings is anonymised like the node IPs. Rows are kept across
+// restarts.
+func InsertSybilFindings(db *sql.DB, privacy *util.IPPrivacy, findings []SybilFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(
+		`INSERT INTO sybil_findings(
+			detected,
+			kind,
+			key,
+			nodes,
+			ips,
+			threshold,
+			node_ids,
+			blacklisted
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, f := range findings {
+		key := sybil.AnonymizeKey(privacy, f.Kind, f.Key)
+		ids := make([]string, len(f.NodeIDs))
+		for i, id := range f.NodeIDs {
+			ids[i] = id.String()
+		}
+		if _, err := stmt.Exec(f.Detected, string(f.Kind), key, f.Nodes, f.IPs, f.Threshold,
+			pq.Array(ids), f.Blacklisted); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SybilStore is the store of the sybil monitor: findings are written to
+// sybil_findings and offenders added to the managed blacklist.
+type SybilStore struct {
+	db        *sql.DB
+	privacy   *util.IPPrivacy
+	blacklist *ManagedBlacklist
+}
+
+// NewSybilStore creates the store. The privacy mode applies to the stored
+// finding keys.
+func NewSybilStore(db *sql.DB, privacy *util.IPPrivacy, blacklist *ManagedBlacklist) *SybilStore {
+	return &SybilStore{db: db, privacy: privacy, blacklist: blacklist}
+}
+
+// Blacklisted returns the stored entries active at the given time.
+func (s *SybilStore) Blacklisted(at time.Time) ([]string, error) {
+	entries, err := ReadBlacklistEntries(s.db, at)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]string, len(entries))
+	for i, e := range entries {
+		active[i] = e.Entry
+	}
+	return active, nil
+}
+
+// Blacklist stores the entries and applies them once all are added.
+func (s *SybilStore) Blacklist(entries []string, reason string, expires time.Time) error {
+	for _, entry := range entries {
+		_, err := AddBlacklistEntry(s.db, BlacklistEntry{
+			Entry:   entry,
+			Reason:  reason,
+			Expires: &expires,
+			AddedBy: sybil.Actor,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if s.blacklist == nil {
+		return nil
+	}
+	return s.blacklist.Sync()
+}
+
+// SaveFindings writes the results of a monitor run.
+func (s *SybilStore) SaveFindings(detected time.Time, results []sybil.Result) error {
+	findings := make([]SybilFinding, len(results))
+	for i, r := range results {
+		findings[i] = SybilFinding{Detected: detected, Finding: r.Finding, Blacklisted: r.Blacklisted}
+	}
+	return InsertSybilFindings(s.db, s.privacy, findings)
+}
+
+// LatestSybilRun returns the time of the latest detector run with findings,
+// or sql.ErrNoRows if there are none.
+func LatestSybilRun(db *sql.DB) (time.Time, error) {
+	var detected sql.NullTime
+	if err := db.QueryRow(`SELECT MAX(detected) FROM sybil_findings`).Scan(&detected); err != nil {
+		return time.Time{}, err
+	}
+	if !detected.Valid {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return detected.Time, nil
+}
+
+// ReadSybilFindings returns the findings of a single run if detected is
+// non-zero, ordered by run, kind and descending node count.
+func ReadSybilFindings(db *sql.DB, detected time.Time) ([]SybilFinding, error) {
+	query := `SELECT detected, kind, key, nodes, ips, threshold, node_ids, blacklisted FROM sybil_findings`
+	var args []any
+	if !detected.IsZero() {
+		query += ` WHERE detected = $1`
+		args = append(args, detected)
+	}
+	rows, err := db.Query(query+` ORDER BY detected, kind, nodes DESC, key`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []SybilFinding
+	for rows.Next() {
+		var (
+			f   SybilFinding
+			ids []string
+		)
+		if err := rows.Scan(&f.Detected, &f.Kind, &f.Key, &f.Nodes, &f.IPs, &f.Threshold,
+			pq.Array(&ids), &f.Blacklisted); err != nil {
+			return nil, err
+		}
+		for _, s := range ids {
+			if id, err := enode.ParseID(s); err == nil {
+				f.NodeIDs = append(f.NodeIDs, id)
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
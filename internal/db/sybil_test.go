@@ -0,0 +1,129 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/sybil"
+	"github.com/200ug/peerlogger/internal/util"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestInsertSybilFindings(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	privacy, err := util.NewIPPrivacy(util.PrivacyTruncated, "", 16, 48)
+	if err != nil {
+		t.Fatalf("NewIPPrivacy failed: %v", err)
+	}
+	detected := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	id := enode.HexID("a3f0000000000000000000000000000000000000000000000000000000000001")
+	findings := []db.SybilFinding{
+		{Detected: detected, Finding: sybil.Finding{Kind: sybil.IP, Key: "198.51.100.7", Nodes: 20, IPs: 1, Threshold: 8, NodeIDs: []enode.ID{id}}},
+		{Detected: detected, Finding: sybil.Finding{Kind: sybil.Prefix, Key: "198.51.100.0/24", Nodes: 70, IPs: 30, Threshold: 64, NodeIDs: []enode.ID{id}}},
+		{Detected: detected, Finding: sybil.Finding{Kind: sybil.Keyspace, Key: "a3f0/13", Nodes: 4, IPs: 4, Threshold: 4, NodeIDs: []enode.ID{id}}, Blacklisted: true},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO sybil_findings")
+	// the key of IP and prefix findings is anonymised like the node IPs
+	mock.ExpectExec("INSERT INTO sybil_findings").
+		WithArgs(detected, "ip", "198.51.0.0/16", 20, 1, 8, sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO sybil_findings").
+		WithArgs(detected, "prefix", "198.51.0.0/16", 70, 30, 64, sqlmock.AnyArg(), false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO sybil_findings").
+		WithArgs(detected, "keyspace", "a3f0/13", 4, 4, 4, sqlmock.AnyArg(), true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := db.InsertSybilFindings(mockDB, privacy, findings); err != nil {
+		t.Fatalf("InsertSybilFindings failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestReadSybilFindings(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	detected := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	id := "a3f0000000000000000000000000000000000000000000000000000000000001"
+	mock.ExpectQuery("SELECT MAX\\(detected\\) FROM sybil_findings").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(detected))
+	mock.ExpectQuery("FROM sybil_findings WHERE detected = \\$1").WithArgs(detected).
+		WillReturnRows(sqlmock.NewRows([]string{"detected", "kind", "key", "nodes", "ips", "threshold", "node_ids", "blacklisted"}).
+			AddRow(detected, "keyspace", "a3f0/13", 4, 4, 4, "{"+id+"}", true))
+
+	run, err := db.LatestSybilRun(mockDB)
+	if err != nil || !run.Equal(detected) {
+		t.Fatalf("LatestSybilRun = %v, %v; want %v", run, err, detected)
+	}
+	findings, err := db.ReadSybilFindings(mockDB, run)
+	if err != nil {
+		t.Fatalf("ReadSybilFindings failed: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	f := findings[0]
+	if f.Kind != sybil.Keyspace || !f.Blacklisted || len(f.NodeIDs) != 1 || f.NodeIDs[0] != enode.HexID(id) {
+		t.Errorf("Unexpected finding %+v", f)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
+
+func TestSybilStoreBlacklist(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(72 * time.Hour)
+	store := db.NewSybilStore(mockDB, nil, nil)
+
+	mock.ExpectQuery("FROM blacklist_entries").WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "entry", "reason", "expires", "added_by", "added"}).
+			AddRow(util.EntryIP, "198.51.100.7", nil, expires, sybil.Actor, now))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blacklist_entries").
+		WithArgs(util.EntryIP, "203.0.113.0/24", "sybil prefix", expires, sybil.Actor, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO blacklist_audit").
+		WithArgs(sqlmock.AnyArg(), sybil.Actor, db.ActionAdd, util.EntryIP, "203.0.113.0/24", "sybil prefix", expires).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	active, err := store.Blacklisted(now)
+	if err != nil || len(active) != 1 || active[0] != "198.51.100.7" {
+		t.Fatalf("Blacklisted = %v, %v", active, err)
+	}
+	if err := store.Blacklist([]string{"203.0.113.0/24"}, "sybil prefix", expires); err != nil {
+		t.Fatalf("Blacklist failed: %v", err)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
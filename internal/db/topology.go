@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/topology"
+)
+
+// LatestCrawl returns the timestamp of the most recent crawl with neighbor data.
+func LatestCrawl(db *sql.DB) (time.Time, error) {
+	var crawl sql.NullTime
+	if err := db.QueryRow(`SELECT MAX(now) FROM neighbors`).Scan(&crawl); err != nil {
+		return time.Time{}, err
+	}
+	if !crawl.Valid {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return crawl.Time, nil
+}
+
+// ReadTopology builds the discovery topology graph of the crawl at the given timestamp.
+func ReadTopology(db *sql.DB, crawl time.Time) (*topology.Graph, error) {
+	g := topology.NewGraph(crawl)
+
+	rows, err := db.Query(
+		`SELECT
+			id,
+			COALESCE(client_type, ''),
+			COALESCE(country, ''),
+			COALESCE(asn, 0)
+		FROM nodes WHERE now = $1`,
+		crawl,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n topology.Node
+		if err := rows.Scan(&n.ID, &n.ClientType, &n.Country, &n.ASN); err != nil {
+			return nil, err
+		}
+		g.AddNode(n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	edges, err := db.Query(
+		`SELECT
+			node_id,
+			neighbor_id,
+			COALESCE(distance, 0),
+			protocol
+		FROM neighbors WHERE now = $1`,
+		crawl,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer edges.Close()
+	for edges.Next() {
+		var e topology.Edge
+		if err := edges.Scan(&e.Source, &e.Target, &e.Distance, &e.Protocol); err != nil {
+			return nil, err
+		}
+		g.AddEdge(e)
+	}
+	if err := edges.Err(); err != nil {
+		return nil, err
+	}
+
+	g.Sort()
+	return g, nil
+}
@@ -0,0 +1,53 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/200ug/peerlogger/internal/db"
+)
+
+func TestReadTopology(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer mockDB.Close()
+
+	crawl := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT MAX\\(now\\) FROM neighbors").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(crawl))
+	mock.ExpectQuery("FROM nodes WHERE now").WithArgs(crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "client_type", "country", "asn"}).
+			AddRow("bb", "Geth/v1.16.2", "Germany", 24940).
+			AddRow("aa", "", "", 0))
+	mock.ExpectQuery("FROM neighbors WHERE now").WithArgs(crawl).
+		WillReturnRows(sqlmock.NewRows([]string{"node_id", "neighbor_id", "distance", "protocol"}).
+			AddRow("aa", "bb", 256, "discv5").
+			AddRow("bb", "cc", 255, "discv4"))
+
+	latest, err := db.LatestCrawl(mockDB)
+	if err != nil {
+		t.Fatalf("LatestCrawl failed: %v", err)
+	}
+	if !latest.Equal(crawl) {
+		t.Errorf("Expected latest crawl %v, got %v", crawl, latest)
+	}
+
+	g, err := db.ReadTopology(mockDB, latest)
+	if err != nil {
+		t.Fatalf("ReadTopology failed: %v", err)
+	}
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Fatalf("Expected 3 nodes and 2 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+	if g.Nodes[0].ID != "aa" || g.Nodes[1].ASN != 24940 {
+		t.Errorf("Nodes not sorted or attributes missing: %+v", g.Nodes)
+	}
+
+	// Verify all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations not met: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package eth2
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/bits"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+
+	"github.com/200ug/peerlogger/internal/common"
+)
+
+// ENR keys defined by the consensus-layer p2p spec.
+const (
+	keyEth2     = "eth2"
+	keyAttnets  = "attnets"
+	keySyncnets = "syncnets"
+	keyCGC      = "cgc"
+	keyNFD      = "nfd"
+)
+
+// HasEth2 reports whether the record advertises a consensus-layer node.
+func HasEth2(n *enode.Node) bool {
+	var raw []byte
+	return n.Load(enr.WithEntry(keyEth2, &raw)) == nil
+}
+
+// DecodeENR decodes the consensus-layer fields of the record. It returns nil
+// for records without a valid eth2 key. Fork names are resolved against the
+// given network, which may be nil.
+func DecodeENR(n *enode.Node, network *Network) *common.ConsensusInfo {
+	var raw []byte
+	if n.Load(enr.WithEntry(keyEth2, &raw)) != nil {
+		return nil
+	}
+	var dat beacon.Eth2Data
+	if err := dat.Deserialize(codec.NewDecodingReader(bytes.NewReader(raw), uint64(len(raw)))); err != nil {
+		return nil
+	}
+
+	info := &common.ConsensusInfo{
+		ForkDigest:      dat.ForkDigest.String(),
+		NextForkVersion: dat.NextForkVersion.String(),
+		NextForkEpoch:   uint64(dat.NextForkEpoch),
+	}
+	if network != nil {
+		info.ForkName = network.ForkName(dat.ForkDigest)
+	}
+
+	var attnets, syncnets, nfd []byte
+	if n.Load(enr.WithEntry(keyAttnets, &attnets)) == nil {
+		info.Attnets, info.AttnetsCount = hex.EncodeToString(attnets), popCount(attnets)
+	}
+	if n.Load(enr.WithEntry(keySyncnets, &syncnets)) == nil {
+		info.Syncnets, info.SyncnetsCount = hex.EncodeToString(syncnets), popCount(syncnets)
+	}
+	if n.Load(enr.WithEntry(keyNFD, &nfd)) == nil && len(nfd) == 4 {
+		info.NextForkDigest = beacon.ForkDigest(nfd).String()
+	}
+	var cgc uint64
+	if n.Load(enr.WithEntry(keyCGC, &cgc)) == nil {
+		info.CustodyGroupCount = &cgc
+	}
+	if pub := n.Pubkey(); pub != nil {
+		info.PeerID = PeerID(crypto.CompressPubkey(pub))
+	}
+	return info
+}
+
+func popCount(b []byte) (count int) {
+	for _, x := range b {
+		count += bits.OnesCount8(x)
+	}
+	return count
+}
@@ -0,0 +1,89 @@
+package eth2_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+
+	"github.com/200ug/peerlogger/internal/eth2"
+)
+
+func TestDecodeENR(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	var r enr.Record
+	r.Set(eth2Entry(t, beacon.Eth2Data{
+		ForkDigest:      beacon.ForkDigest{0x6a, 0x95, 0xa1, 0xa9},
+		NextForkVersion: beacon.Version{0x05, 0x00, 0x00, 0x00},
+		NextForkEpoch:   364032,
+	}))
+	r.Set(enr.WithEntry("attnets", []byte{0x01, 0x80, 0, 0, 0, 0, 0, 0xff}))
+	r.Set(enr.WithEntry("syncnets", []byte{0x05}))
+	r.Set(enr.WithEntry("cgc", uint64(8)))
+	r.Set(enr.WithEntry("nfd", []byte{0xde, 0xad, 0xbe, 0xef}))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	if !eth2.HasEth2(n) {
+		t.Fatal("HasEth2 = false, want true")
+	}
+	info := eth2.DecodeENR(n, eth2.Mainnet)
+	if info == nil {
+		t.Fatal("DecodeENR returned nil")
+	}
+	if info.ForkDigest != "0x6a95a1a9" || info.ForkName != "deneb" {
+		t.Errorf("fork = %s/%s, want 0x6a95a1a9/deneb", info.ForkDigest, info.ForkName)
+	}
+	if info.NextForkVersion != "0x05000000" || info.NextForkEpoch != 364032 {
+		t.Errorf("next fork = %s@%d, want 0x05000000@364032", info.NextForkVersion, info.NextForkEpoch)
+	}
+	if info.Attnets != "01800000000000ff" || info.AttnetsCount != 10 {
+		t.Errorf("attnets = %s (%d), want 01800000000000ff (10)", info.Attnets, info.AttnetsCount)
+	}
+	if info.Syncnets != "05" || info.SyncnetsCount != 2 {
+		t.Errorf("syncnets = %s (%d), want 05 (2)", info.Syncnets, info.SyncnetsCount)
+	}
+	if info.CustodyGroupCount == nil || *info.CustodyGroupCount != 8 {
+		t.Errorf("cgc = %v, want 8", info.CustodyGroupCount)
+	}
+	if info.NextForkDigest != "0xdeadbeef" {
+		t.Errorf("nfd = %s, want 0xdeadbeef", info.NextForkDigest)
+	}
+	if !strings.HasPrefix(info.PeerID, "16Uiu2HA") || len(info.PeerID) != 53 {
+		t.Errorf("peer ID = %s, want secp256k1 identity peer ID", info.PeerID)
+	}
+}
+
+func TestDecodeENRWithoutEth2(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	n := enode.NewV4(&key.PublicKey, nil, 30303, 30303)
+	if eth2.DecodeENR(n, eth2.Mainnet) != nil {
+		t.Error("DecodeENR returned info for a record without eth2 key")
+	}
+}
+
+func eth2Entry(t *testing.T, dat beacon.Eth2Data) enr.Entry {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := dat.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatalf("Failed to encode eth2 data: %v", err)
+	}
+	return enr.WithEntry("eth2", buf.Bytes())
+}
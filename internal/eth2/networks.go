@@ -0,0 +1,144 @@
+package eth2
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Fork is a consensus-layer fork or blob parameter only (BPO) fork.
+type Fork struct {
+	Name    string
+	Version beacon.Version
+	Epoch   beacon.Epoch
+}
+
+// BlobParameters is an entry of the blob schedule, which changes the fork
+// digest from Fulu on.
+type BlobParameters struct {
+	Epoch            beacon.Epoch
+	MaxBlobsPerBlock uint64
+}
+
+// Network describes the fork schedule of a consensus-layer network.
+type Network struct {
+	Name                  string
+	GenesisValidatorsRoot beacon.Root
+	Forks                 []Fork
+	FuluEpoch             beacon.Epoch
+	ElectraBlobs          BlobParameters
+	BlobSchedule          []BlobParameters
+
+	once    sync.Once
+	digests map[beacon.ForkDigest]string
+}
+
+// ForkDigest computes the fork digest of the given fork.
+func (n *Network) ForkDigest(f Fork) beacon.ForkDigest {
+	digest := beacon.ComputeForkDigest(f.Version, n.GenesisValidatorsRoot)
+	if n.FuluEpoch == 0 || f.Epoch < n.FuluEpoch {
+		return digest
+	}
+
+	// From Fulu on the digest is mixed with the active blob parameters.
+	params := n.ElectraBlobs
+	schedule := append([]BlobParameters{}, n.BlobSchedule...)
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i].Epoch > schedule[j].Epoch })
+	for _, p := range schedule {
+		if f.Epoch >= p.Epoch {
+			params = p
+			break
+		}
+	}
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], uint64(params.Epoch))
+	binary.LittleEndian.PutUint64(buf[8:], params.MaxBlobsPerBlock)
+	mix := sha256.Sum256(buf[:])
+	for i := range digest {
+		digest[i] ^= mix[i]
+	}
+	return digest
+}
+
+// ForkName returns the name of the fork with the given digest, or an empty
+// string if the digest doesn't belong to this network.
+func (n *Network) ForkName(digest beacon.ForkDigest) string {
+	n.once.Do(func() {
+		n.digests = make(map[beacon.ForkDigest]string, len(n.Forks))
+		for _, f := range n.Forks {
+			n.digests[n.ForkDigest(f)] = f.Name
+		}
+	})
+	return n.digests[digest]
+}
+
+// The fork schedules below are taken from the network configs published in
+// github.com/eth-clients. BPO forks reuse the Fulu fork version.
+
+var Mainnet = &Network{
+	Name:                  "mainnet",
+	GenesisValidatorsRoot: root("0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"),
+	Forks: []Fork{
+		{"phase0", beacon.Version{0x00, 0x00, 0x00, 0x00}, 0},
+		{"altair", beacon.Version{0x01, 0x00, 0x00, 0x00}, 74240},
+		{"bellatrix", beacon.Version{0x02, 0x00, 0x00, 0x00}, 144896},
+		{"capella", beacon.Version{0x03, 0x00, 0x00, 0x00}, 194048},
+		{"deneb", beacon.Version{0x04, 0x00, 0x00, 0x00}, 269568},
+		{"electra", beacon.Version{0x05, 0x00, 0x00, 0x00}, 364032},
+		{"fulu", beacon.Version{0x06, 0x00, 0x00, 0x00}, 411392},
+		{"bpo1", beacon.Version{0x06, 0x00, 0x00, 0x00}, 412672},
+		{"bpo2", beacon.Version{0x06, 0x00, 0x00, 0x00}, 419072},
+	},
+	FuluEpoch:    411392,
+	ElectraBlobs: BlobParameters{364032, 9},
+	BlobSchedule: []BlobParameters{{412672, 15}, {419072, 21}},
+}
+
+var Sepolia = &Network{
+	Name:                  "sepolia",
+	GenesisValidatorsRoot: root("0xd8ea171f3c94aea21ebc42a1ed61052acf3f9209c00e4efbaaddac09ed9b8078"),
+	Forks: []Fork{
+		{"phase0", beacon.Version{0x90, 0x00, 0x00, 0x69}, 0},
+		{"altair", beacon.Version{0x90, 0x00, 0x00, 0x70}, 50},
+		{"bellatrix", beacon.Version{0x90, 0x00, 0x00, 0x71}, 100},
+		{"capella", beacon.Version{0x90, 0x00, 0x00, 0x72}, 56832},
+		{"deneb", beacon.Version{0x90, 0x00, 0x00, 0x73}, 132608},
+		{"electra", beacon.Version{0x90, 0x00, 0x00, 0x74}, 222464},
+		{"fulu", beacon.Version{0x90, 0x00, 0x00, 0x75}, 272640},
+		{"bpo1", beacon.Version{0x90, 0x00, 0x00, 0x75}, 274176},
+		{"bpo2", beacon.Version{0x90, 0x00, 0x00, 0x75}, 275200},
+	},
+	FuluEpoch:    272640,
+	ElectraBlobs: BlobParameters{222464, 9},
+	BlobSchedule: []BlobParameters{{274176, 15}, {275200, 21}},
+}
+
+var Hoodi = &Network{
+	Name:                  "hoodi",
+	GenesisValidatorsRoot: root("0x212f13fc4df078b6cb7db228f1c8307566dcecf900867401a92023d7ba99cb5f"),
+	Forks: []Fork{
+		{"phase0", beacon.Version{0x10, 0x00, 0x09, 0x10}, 0},
+		{"altair", beacon.Version{0x20, 0x00, 0x09, 0x10}, 0},
+		{"bellatrix", beacon.Version{0x30, 0x00, 0x09, 0x10}, 0},
+		{"capella", beacon.Version{0x40, 0x00, 0x09, 0x10}, 0},
+		{"deneb", beacon.Version{0x50, 0x00, 0x09, 0x10}, 0},
+		{"electra", beacon.Version{0x60, 0x00, 0x09, 0x10}, 2048},
+		{"fulu", beacon.Version{0x70, 0x00, 0x09, 0x10}, 50688},
+		{"bpo1", beacon.Version{0x70, 0x00, 0x09, 0x10}, 52480},
+		{"bpo2", beacon.Version{0x70, 0x00, 0x09, 0x10}, 54016},
+	},
+	FuluEpoch:    50688,
+	ElectraBlobs: BlobParameters{2048, 9},
+	BlobSchedule: []BlobParameters{{52480, 15}, {54016, 21}},
+}
+
+func root(s string) beacon.Root {
+	var r beacon.Root
+	if err := r.UnmarshalText([]byte(s)); err != nil {
+		panic(err)
+	}
+	return r
+}
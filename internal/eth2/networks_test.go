@@ -0,0 +1,48 @@
+package eth2_test
+
+import (
+	"testing"
+
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/200ug/peerlogger/internal/eth2"
+)
+
+func TestForkName(t *testing.T) {
+	tests := []struct {
+		digest string
+		want   string
+	}{
+		{"0xb5303f2a", "phase0"},
+		{"0xafcaaba0", "altair"},
+		{"0x4a26c58b", "bellatrix"},
+		{"0xbba4da96", "capella"},
+		{"0x6a95a1a9", "deneb"},
+		{"0x00000000", ""},
+	}
+	for _, tt := range tests {
+		var digest beacon.ForkDigest
+		if err := digest.UnmarshalText([]byte(tt.digest)); err != nil {
+			t.Fatalf("Failed to parse digest %s: %v", tt.digest, err)
+		}
+		if got := eth2.Mainnet.ForkName(digest); got != tt.want {
+			t.Errorf("ForkName(%s) = %q, want %q", tt.digest, got, tt.want)
+		}
+	}
+}
+
+func TestForkDigestBlobSchedule(t *testing.T) {
+	for _, network := range []*eth2.Network{eth2.Mainnet, eth2.Sepolia, eth2.Hoodi} {
+		seen := make(map[beacon.ForkDigest]string)
+		for _, f := range network.Forks {
+			digest := network.ForkDigest(f)
+			if prev, ok := seen[digest]; ok {
+				t.Errorf("%s: %s and %s share digest %s", network.Name, prev, f.Name, digest)
+			}
+			seen[digest] = f.Name
+			if got := network.ForkName(digest); got != f.Name {
+				t.Errorf("%s: ForkName(%s) = %q, want %q", network.Name, digest, got, f.Name)
+			}
+		}
+	}
+}
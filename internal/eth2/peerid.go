@@ -0,0 +1,31 @@
+package eth2
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerID returns the libp2p peer ID of a compressed secp256k1 public key, or
+// an empty string if the key is invalid.
+func PeerID(compressed []byte) string {
+	pub, err := p2pcrypto.UnmarshalSecp256k1PublicKey(compressed)
+	if err != nil {
+		return ""
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// nodePeerID derives the libp2p peer ID from the node's secp256k1 key.
+func nodePeerID(n *enode.Node) (peer.ID, error) {
+	pub, err := p2pcrypto.UnmarshalSecp256k1PublicKey(crypto.CompressPubkey(n.Pubkey()))
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromPublicKey(pub)
+}
@@ -0,0 +1,261 @@
+package eth2
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/libp2p/go-libp2p"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/200ug/peerlogger/internal/common"
+)
+
+// agentVersion is what we announce over identify.
+const agentVersion = "peerlogger"
+
+var errNoLibp2pAddrs = errors.New("node has no TCP or QUIC endpoint")
+
+// Prober collects client information from consensus-layer nodes over libp2p.
+type Prober struct {
+	host    host.Host
+	timeout time.Duration
+}
+
+// NewProber creates a libp2p host that only dials out. Incoming Status and
+// MetaData requests are answered so remote nodes don't drop us before the
+// exchange is done.
+func NewProber(timeout time.Duration) (*Prober, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	priv, err := p2pcrypto.UnmarshalSecp256k1PrivateKey(crypto.FromECDSA(key))
+	if err != nil {
+		return nil, err
+	}
+	h, err := libp2p.New(
+		libp2p.Identity(priv),
+		libp2p.UserAgent(agentVersion),
+		libp2p.NoListenAddrs,
+		libp2p.DisableRelay(),
+		libp2p.Ping(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+	responder := &Responder{Status: func(remote Status) Status { return remote }}
+	responder.Register(h)
+	return &Prober{host: h, timeout: timeout}, nil
+}
+
+// Close shuts down the libp2p host.
+func (p *Prober) Close() error {
+	return p.host.Close()
+}
+
+// Probe dials the node over TCP or QUIC, runs identify and the Status and
+// MetaData exchanges.
+func (p *Prober) Probe(n *enode.Node) (*common.BeaconInfo, error) {
+	id, err := nodePeerID(n)
+	if err != nil {
+		return nil, err
+	}
+	addrs := multiaddrs(n)
+	if len(addrs) == 0 {
+		return nil, errNoLibp2pAddrs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	if err := p.host.Connect(ctx, peer.AddrInfo{ID: id, Addrs: addrs}); err != nil {
+		return nil, err
+	}
+	defer p.host.Network().ClosePeer(id)
+
+	conns := p.host.Network().ConnsToPeer(id)
+	if len(conns) == 0 {
+		return nil, errors.New("connection closed")
+	}
+	conn := conns[0]
+	if ids, ok := p.host.(interface{ IDService() identify.IDService }); ok {
+		select {
+		case <-ids.IDService().IdentifyWait(conn):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	info := &common.BeaconInfo{
+		Transport: transport(conn.RemoteMultiaddr()),
+		ProbedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	if v, err := p.host.Peerstore().Get(id, "AgentVersion"); err == nil {
+		info.AgentVersion, _ = v.(string)
+	}
+	if protos, err := p.host.Peerstore().GetProtocols(id); err == nil {
+		for _, proto := range protos {
+			info.Protocols = append(info.Protocols, string(proto))
+		}
+		slices.Sort(info.Protocols)
+	}
+
+	// Send the fork digest the node advertises, so it doesn't reject us
+	// before responding.
+	var status Status
+	if cl := DecodeENR(n, nil); cl != nil {
+		status.ForkDigest.UnmarshalText([]byte(cl.ForkDigest))
+	}
+	remote, err := p.status(ctx, id, status)
+	if err != nil {
+		return info, fmt.Errorf("status: %w", err)
+	}
+	info.ForkDigest = remote.ForkDigest.String()
+	info.FinalizedRoot = remote.FinalizedRoot.String()
+	info.FinalizedEpoch = remote.FinalizedEpoch
+	info.HeadRoot = remote.HeadRoot.String()
+	info.HeadSlot = remote.HeadSlot
+
+	md, err := p.metadata(ctx, id)
+	if err != nil {
+		return info, fmt.Errorf("metadata: %w", err)
+	}
+	info.MetadataSeq = md.SeqNumber
+	return info, nil
+}
+
+func (p *Prober) status(ctx context.Context, id peer.ID, req Status) (*Status, error) {
+	s, err := p.host.NewStream(ctx, id, statusProtocols...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(p.timeout))
+
+	if err := writeRequest(s, req.marshal(s.Protocol())); err != nil {
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, err
+	}
+	payload, err := readResponse(s)
+	if err != nil {
+		return nil, err
+	}
+	var status Status
+	if err := status.unmarshal(s.Protocol(), payload); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (p *Prober) metadata(ctx context.Context, id peer.ID) (*MetaData, error) {
+	s, err := p.host.NewStream(ctx, id, metadataProtocols...)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(p.timeout))
+
+	// MetaData requests have no body.
+	if err := s.CloseWrite(); err != nil {
+		return nil, err
+	}
+	payload, err := readResponse(s)
+	if err != nil {
+		return nil, err
+	}
+	var md MetaData
+	if err := md.unmarshal(s.Protocol(), payload); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+// Responder answers Status and MetaData requests on a libp2p host.
+type Responder struct {
+	// Status returns our status given the one sent by the remote node.
+	Status   func(remote Status) Status
+	MetaData MetaData
+}
+
+// Register sets the stream handlers on the host.
+func (r *Responder) Register(h host.Host) {
+	for _, proto := range statusProtocols {
+		h.SetStreamHandler(proto, r.handleStatus)
+	}
+	for _, proto := range metadataProtocols {
+		h.SetStreamHandler(proto, r.handleMetaData)
+	}
+}
+
+func (r *Responder) handleStatus(s network.Stream) {
+	defer s.Close()
+	proto := s.Protocol()
+	payload, err := readPayload(bufio.NewReader(s))
+	if err != nil {
+		log.Debug("Invalid status request", "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+		return
+	}
+	var remote Status
+	if err := remote.unmarshal(proto, payload); err != nil {
+		log.Debug("Invalid status request", "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+		return
+	}
+	status := r.Status(remote)
+	writeResponse(s, status.marshal(proto))
+}
+
+func (r *Responder) handleMetaData(s network.Stream) {
+	defer s.Close()
+	writeResponse(s, r.MetaData.marshal(s.Protocol()))
+}
+
+// multiaddrs returns the libp2p addresses advertised in the record.
+func multiaddrs(n *enode.Node) []ma.Multiaddr {
+	e := common.NodeEndpoints(n)
+	var addrs []ma.Multiaddr
+	add := func(s string) {
+		if addr, err := ma.NewMultiaddr(s); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	if e.IP.IsValid() {
+		if e.TCP != 0 {
+			add(fmt.Sprintf("/ip4/%s/tcp/%d", e.IP, e.TCP))
+		}
+		if e.QUIC != 0 {
+			add(fmt.Sprintf("/ip4/%s/udp/%d/quic-v1", e.IP, e.QUIC))
+		}
+	}
+	if e.IP6.IsValid() {
+		if port := cmp.Or(e.TCP6, e.TCP); port != 0 {
+			add(fmt.Sprintf("/ip6/%s/tcp/%d", e.IP6, port))
+		}
+		if port := cmp.Or(e.QUIC6, e.QUIC); port != 0 {
+			add(fmt.Sprintf("/ip6/%s/udp/%d/quic-v1", e.IP6, port))
+		}
+	}
+	return addrs
+}
+
+func transport(addr ma.Multiaddr) string {
+	if _, err := addr.ValueForProtocol(ma.P_QUIC_V1); err == nil {
+		return "quic"
+	}
+	return "tcp"
+}
@@ -0,0 +1,112 @@
+package eth2_test
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/libp2p/go-libp2p"
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	ma "github.com/multiformats/go-multiaddr"
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+
+	"github.com/200ug/peerlogger/internal/eth2"
+)
+
+func TestProber(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	priv, err := p2pcrypto.UnmarshalSecp256k1PrivateKey(crypto.FromECDSA(key))
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	h, err := libp2p.New(
+		libp2p.Identity(priv),
+		libp2p.UserAgent("Lighthouse/v7.1.0-e42406d/x86_64-linux"),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0", "/ip4/127.0.0.1/udp/0/quic-v1"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create host: %v", err)
+	}
+	defer h.Close()
+
+	digest := beacon.ForkDigest{0x6a, 0x95, 0xa1, 0xa9}
+	responder := &eth2.Responder{
+		Status: func(remote eth2.Status) eth2.Status {
+			if remote.ForkDigest != digest {
+				t.Errorf("remote fork digest = %v, want %v", remote.ForkDigest, digest)
+			}
+			return eth2.Status{ForkDigest: digest, FinalizedEpoch: 100, HeadSlot: 3250}
+		},
+		MetaData: eth2.MetaData{SeqNumber: 42, CustodyGroupCount: 4},
+	}
+	responder.Register(h)
+
+	var tcpPort, quicPort int
+	for _, addr := range h.Addrs() {
+		if port, err := addr.ValueForProtocol(ma.P_TCP); err == nil {
+			tcpPort, _ = strconv.Atoi(port)
+		}
+		if port, err := addr.ValueForProtocol(ma.P_UDP); err == nil {
+			quicPort, _ = strconv.Atoi(port)
+		}
+	}
+
+	prober, err := eth2.NewProber(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create prober: %v", err)
+	}
+	defer prober.Close()
+
+	tests := []struct {
+		name      string
+		entries   []enr.Entry
+		transport string
+	}{
+		{"tcp", []enr.Entry{enr.TCP(tcpPort)}, "tcp"},
+		{"quic", []enr.Entry{enr.QUIC(quicPort)}, "quic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r enr.Record
+			r.Set(enr.IPv4(net.IPv4(127, 0, 0, 1)))
+			r.Set(eth2Entry(t, beacon.Eth2Data{ForkDigest: digest}))
+			for _, e := range tt.entries {
+				r.Set(e)
+			}
+			if err := enode.SignV4(&r, key); err != nil {
+				t.Fatalf("Failed to sign record: %v", err)
+			}
+			n, err := enode.New(enode.ValidSchemes, &r)
+			if err != nil {
+				t.Fatalf("Failed to create node: %v", err)
+			}
+
+			info, err := prober.Probe(n)
+			if err != nil {
+				t.Fatalf("Probe failed: %v", err)
+			}
+			if info.Transport != tt.transport {
+				t.Errorf("transport = %s, want %s", info.Transport, tt.transport)
+			}
+			if info.AgentVersion != "Lighthouse/v7.1.0-e42406d/x86_64-linux" {
+				t.Errorf("agent version = %q", info.AgentVersion)
+			}
+			if len(info.Protocols) == 0 {
+				t.Error("no protocols reported by identify")
+			}
+			if info.ForkDigest != "0x6a95a1a9" || info.FinalizedEpoch != 100 || info.FinalizedSlot() != 3200 || info.HeadSlot != 3250 {
+				t.Errorf("unexpected status: %+v", info)
+			}
+			if info.MetadataSeq != 42 {
+				t.Errorf("metadata seq = %d, want 42", info.MetadataSeq)
+			}
+		})
+	}
+}
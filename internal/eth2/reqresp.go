@@ -0,0 +1,178 @@
+package eth2
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	beacon "github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Req/resp protocol IDs, newest first. The Fulu versions extend the messages
+// with the earliest available slot and the custody group count.
+var (
+	statusProtocols = []protocol.ID{
+		"/eth2/beacon_chain/req/status/2/ssz_snappy",
+		"/eth2/beacon_chain/req/status/1/ssz_snappy",
+	}
+	metadataProtocols = []protocol.ID{
+		"/eth2/beacon_chain/req/metadata/3/ssz_snappy",
+		"/eth2/beacon_chain/req/metadata/2/ssz_snappy",
+		"/eth2/beacon_chain/req/metadata/1/ssz_snappy",
+	}
+)
+
+const (
+	statusV1Size = 4 + 32 + 8 + 32 + 8
+	statusV2Size = statusV1Size + 8
+
+	// maxChunkSize bounds the SSZ payload we accept in a response chunk.
+	maxChunkSize = 1024
+
+	responseSuccess = 0
+)
+
+// Status is the beacon chain Status message.
+type Status struct {
+	ForkDigest            beacon.ForkDigest
+	FinalizedRoot         beacon.Root
+	FinalizedEpoch        uint64
+	HeadRoot              beacon.Root
+	HeadSlot              uint64
+	EarliestAvailableSlot uint64 // v2 only
+}
+
+func (s *Status) marshal(proto protocol.ID) []byte {
+	b := make([]byte, statusV2Size)
+	copy(b[0:4], s.ForkDigest[:])
+	copy(b[4:36], s.FinalizedRoot[:])
+	binary.LittleEndian.PutUint64(b[36:44], s.FinalizedEpoch)
+	copy(b[44:76], s.HeadRoot[:])
+	binary.LittleEndian.PutUint64(b[76:84], s.HeadSlot)
+	if proto == statusProtocols[0] {
+		binary.LittleEndian.PutUint64(b[84:92], s.EarliestAvailableSlot)
+		return b
+	}
+	return b[:statusV1Size]
+}
+
+func (s *Status) unmarshal(proto protocol.ID, b []byte) error {
+	want := statusV1Size
+	if proto == statusProtocols[0] {
+		want = statusV2Size
+	}
+	if len(b) != want {
+		return fmt.Errorf("invalid status size %d, want %d", len(b), want)
+	}
+	copy(s.ForkDigest[:], b[0:4])
+	copy(s.FinalizedRoot[:], b[4:36])
+	s.FinalizedEpoch = binary.LittleEndian.Uint64(b[36:44])
+	copy(s.HeadRoot[:], b[44:76])
+	s.HeadSlot = binary.LittleEndian.Uint64(b[76:84])
+	if want == statusV2Size {
+		s.EarliestAvailableSlot = binary.LittleEndian.Uint64(b[84:92])
+	}
+	return nil
+}
+
+// MetaData is the beacon node MetaData message.
+type MetaData struct {
+	SeqNumber         uint64
+	Attnets           [8]byte
+	Syncnets          byte   // v2 and later
+	CustodyGroupCount uint64 // v3 only
+}
+
+func (m *MetaData) marshal(proto protocol.ID) []byte {
+	b := make([]byte, 25)
+	binary.LittleEndian.PutUint64(b[0:8], m.SeqNumber)
+	copy(b[8:16], m.Attnets[:])
+	b[16] = m.Syncnets
+	binary.LittleEndian.PutUint64(b[17:25], m.CustodyGroupCount)
+	return b[:metadataSize(proto)]
+}
+
+func (m *MetaData) unmarshal(proto protocol.ID, b []byte) error {
+	if want := metadataSize(proto); len(b) != want {
+		return fmt.Errorf("invalid metadata size %d, want %d", len(b), want)
+	}
+	m.SeqNumber = binary.LittleEndian.Uint64(b[0:8])
+	copy(m.Attnets[:], b[8:16])
+	if len(b) > 16 {
+		m.Syncnets = b[16]
+	}
+	if len(b) > 17 {
+		m.CustodyGroupCount = binary.LittleEndian.Uint64(b[17:25])
+	}
+	return nil
+}
+
+func metadataSize(proto protocol.ID) int {
+	switch proto {
+	case metadataProtocols[0]:
+		return 25
+	case metadataProtocols[1]:
+		return 17
+	default:
+		return 16
+	}
+}
+
+// writeRequest writes an ssz_snappy request: the uvarint length of the SSZ
+// payload followed by the snappy framed payload.
+func writeRequest(w io.Writer, payload []byte) error {
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(payload)))); err != nil {
+		return err
+	}
+	sw := snappy.NewBufferedWriter(w)
+	if _, err := sw.Write(payload); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// writeResponse writes a successful ssz_snappy response chunk.
+func writeResponse(w io.Writer, payload []byte) error {
+	if _, err := w.Write([]byte{responseSuccess}); err != nil {
+		return err
+	}
+	return writeRequest(w, payload)
+}
+
+// readResponse reads a single response chunk without context bytes, as used
+// by Status and MetaData.
+func readResponse(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	code, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if code != responseSuccess {
+		msg, _ := io.ReadAll(io.LimitReader(br, 256))
+		return nil, fmt.Errorf("request failed with code %d: %q", code, msg)
+	}
+	return readPayload(br)
+}
+
+// readPayload reads the uvarint length prefix and the snappy framed payload.
+func readPayload(br *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxChunkSize {
+		return nil, fmt.Errorf("chunk too large: %d", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(snappy.NewReader(br), payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
@@ -0,0 +1,178 @@
+// Package hosting classifies node IPs by the network they are hosted in, e.g.
+// a cloud provider, a hosting company or a residential ISP.
+package hosting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Category is the kind of network a node runs in.
+type Category string
+
+const (
+	Cloud       Category = "cloud"
+	Hosting     Category = "hosting"
+	Residential Category = "residential"
+	Unknown     Category = "unknown"
+)
+
+// Classification is the provider and category of a node's network. Provider
+// is empty for Unknown.
+type Classification struct {
+	Provider string   `json:"provider"`
+	Category Category `json:"category"`
+}
+
+// List is the file format of the provider lists. Providers can be matched by
+// IP prefix, by the ASN of the IP, or both:
+//
+//	{"providers": [
+//	  {"name": "aws", "category": "cloud", "prefixes": ["3.5.140.0/22", "2600:1f14::/35"]},
+//	  {"name": "hetzner", "category": "hosting", "asns": [24940, 213230]},
+//	  {"name": "deutsche telekom", "category": "residential", "asns": [3320]}
+//	]}
+//
+// Cloud ranges and the ASN-to-category mapping are usually kept in separate
+// files, e.g. one generated from the ranges the cloud providers publish and a
+// hand-maintained ASN list.
+type List struct {
+	Providers []Provider `json:"providers"`
+}
+
+// Provider is a network operator with its prefixes and autonomous systems.
+type Provider struct {
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+	Prefixes []string `json:"prefixes,omitempty"`
+	ASNs     []int64  `json:"asns,omitempty"`
+}
+
+// Classifier maps IPs and ASNs to providers. IP prefixes take precedence over
+// ASNs, as cloud providers often announce customer ranges from shared ASNs.
+type Classifier struct {
+	prefixes map[netip.Prefix]Classification
+	bits4    []int // prefix lengths present, longest first
+	bits6    []int
+	asns     map[int64]Classification
+}
+
+// LoadClassifier reads and merges provider lists. For prefixes or ASNs listed
+// more than once, the last file wins.
+func LoadClassifier(paths ...string) (*Classifier, error) {
+	c := newClassifier()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// ParseClassifier creates a classifier from JSON provider lists.
+func ParseClassifier(lists ...[]byte) (*Classifier, error) {
+	c := newClassifier()
+	for _, data := range lists {
+		if err := c.add(data); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func newClassifier() *Classifier {
+	return &Classifier{
+		prefixes: make(map[netip.Prefix]Classification),
+		asns:     make(map[int64]Classification),
+	}
+}
+
+func (c *Classifier) add(data []byte) error {
+	var list List
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, p := range list.Providers {
+		if p.Name == "" {
+			return errors.New("provider without a name")
+		}
+		switch p.Category {
+		case Cloud, Hosting, Residential:
+		default:
+			return fmt.Errorf("provider %s: invalid category %q", p.Name, p.Category)
+		}
+		class := Classification{Provider: p.Name, Category: p.Category}
+		for _, s := range p.Prefixes {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("provider %s: %w", p.Name, err)
+			}
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+			}
+			prefix = prefix.Masked()
+			c.prefixes[prefix] = class
+			if prefix.Addr().Is4() {
+				c.bits4 = addBits(c.bits4, prefix.Bits())
+			} else {
+				c.bits6 = addBits(c.bits6, prefix.Bits())
+			}
+		}
+		for _, asn := range p.ASNs {
+			c.asns[asn] = class
+		}
+	}
+	return nil
+}
+
+func addBits(bits []int, b int) []int {
+	i := sort.Search(len(bits), func(i int) bool { return bits[i] <= b })
+	if i < len(bits) && bits[i] == b {
+		return bits
+	}
+	return slices.Insert(bits, i, b)
+}
+
+// Classify returns the provider of the IP, falling back to the ASN (0 if
+// unknown). It is safe to call on a nil classifier, which returns Unknown.
+func (c *Classifier) Classify(ip netip.Addr, asn int64) Classification {
+	if c == nil {
+		return Classification{Category: Unknown}
+	}
+	if ip.IsValid() {
+		ip = ip.Unmap()
+		bits := c.bits6
+		if ip.Is4() {
+			bits = c.bits4
+		}
+		// longest prefix match, there are only a few distinct lengths
+		for _, b := range bits {
+			prefix, _ := ip.Prefix(b)
+			if class, ok := c.prefixes[prefix]; ok {
+				return class
+			}
+		}
+	}
+	if class, ok := c.asns[asn]; ok && asn != 0 {
+		return class
+	}
+	return Classification{Category: Unknown}
+}
+
+// Len returns the number of prefixes and ASNs.
+func (c *Classifier) Len() (prefixes, asns int) {
+	if c == nil {
+		return 0, 0
+	}
+	return len(c.prefixes), len(c.asns)
+}
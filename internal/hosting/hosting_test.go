@@ -0,0 +1,85 @@
+package hosting_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/hosting"
+)
+
+const ranges = `{"providers": [
+	{"name": "aws", "category": "cloud", "prefixes": ["3.0.0.0/8", "2600:1f00::/24"]},
+	{"name": "aws-eu", "category": "cloud", "prefixes": ["3.5.140.0/22"]}
+]}`
+
+const asns = `{"providers": [
+	{"name": "hetzner", "category": "hosting", "asns": [24940]},
+	{"name": "amazon", "category": "cloud", "asns": [16509]},
+	{"name": "deutsche telekom", "category": "residential", "asns": [3320]}
+]}`
+
+func TestClassify(t *testing.T) {
+	c, err := hosting.ParseClassifier([]byte(ranges), []byte(asns))
+	if err != nil {
+		t.Fatalf("ParseClassifier failed: %v", err)
+	}
+	tests := []struct {
+		ip   string
+		asn  int64
+		want hosting.Classification
+	}{
+		{"3.5.141.7", 16509, hosting.Classification{Provider: "aws-eu", Category: hosting.Cloud}},
+		{"3.4.0.1", 0, hosting.Classification{Provider: "aws", Category: hosting.Cloud}},
+		{"::ffff:3.4.0.1", 0, hosting.Classification{Provider: "aws", Category: hosting.Cloud}},
+		{"2600:1f14::1", 0, hosting.Classification{Provider: "aws", Category: hosting.Cloud}},
+		{"5.9.1.1", 24940, hosting.Classification{Provider: "hetzner", Category: hosting.Hosting}},
+		{"80.128.0.1", 3320, hosting.Classification{Provider: "deutsche telekom", Category: hosting.Residential}},
+		{"192.0.2.1", 64496, hosting.Classification{Category: hosting.Unknown}},
+		{"", 0, hosting.Classification{Category: hosting.Unknown}},
+	}
+	for _, tt := range tests {
+		ip, _ := netip.ParseAddr(tt.ip)
+		if got := c.Classify(ip, tt.asn); got != tt.want {
+			t.Errorf("Classify(%q, %d) = %+v, want %+v", tt.ip, tt.asn, got, tt.want)
+		}
+	}
+
+	var nilClassifier *hosting.Classifier
+	if got := nilClassifier.Classify(netip.MustParseAddr("3.4.0.1"), 16509); got.Category != hosting.Unknown {
+		t.Errorf("Nil classifier should return unknown, got %+v", got)
+	}
+}
+
+func TestParseClassifierErrors(t *testing.T) {
+	for _, list := range []string{
+		`{"providers": [{"name": "aws", "category": "serverless"}]}`,
+		`{"providers": [{"category": "cloud", "asns": [1]}]}`,
+		`{"providers": [{"name": "aws", "category": "cloud", "prefixes": ["3.0.0.0"]}]}`,
+		`[]`,
+	} {
+		if _, err := hosting.ParseClassifier([]byte(list)); err == nil {
+			t.Errorf("Expected an error for %s", list)
+		}
+	}
+}
+
+func TestLoadClassifier(t *testing.T) {
+	dir := t.TempDir()
+	rangesPath := filepath.Join(dir, "cloud-ranges.json")
+	asnPath := filepath.Join(dir, "asn-categories.json")
+	os.WriteFile(rangesPath, []byte(ranges), 0o644)
+	os.WriteFile(asnPath, []byte(asns), 0o644)
+
+	c, err := hosting.LoadClassifier(rangesPath, asnPath)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	if prefixes, asns := c.Len(); prefixes != 3 || asns != 3 {
+		t.Errorf("Expected 3 prefixes and 3 ASNs, got %d and %d", prefixes, asns)
+	}
+	if _, err := hosting.LoadClassifier(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,179 @@
+package releases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+// Advisories is a local database of security advisories, loaded from JSON or
+// YAML:
+//
+//	advisories:
+//	  - id: GHSA-q26p-9cq4-7fc2
+//	    client: geth
+//	    affected: [">=1.10.0, <1.13.15", "=1.14.0"]
+//	    summary: DoS via malicious p2p message
+//
+// A node is affected if its version matches any of the ranges. The
+// constraints within a range, separated by commas, must all match.
+type Advisories struct {
+	Advisories []*Advisory `json:"advisories" yaml:"advisories"`
+
+	byClient map[string][]*Advisory
+}
+
+// Advisory is a published advisory for a client.
+type Advisory struct {
+	ID       string   `json:"id" yaml:"id"`
+	Client   string   `json:"client" yaml:"client"`
+	Affected []string `json:"affected" yaml:"affected"`
+	Summary  string   `json:"summary,omitempty" yaml:"summary,omitempty"`
+
+	ranges [][]constraint
+}
+
+type constraint struct {
+	op      string
+	version vparser.Version
+}
+
+// LoadAdvisories reads an advisory database. Files ending in .yaml or .yml
+// are decoded as YAML, everything else as JSON.
+func LoadAdvisories(path string) (*Advisories, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var a Advisories
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &a)
+	default:
+		err = json.Unmarshal(data, &a)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, a.init()
+}
+
+// ParseAdvisories decodes and validates a JSON advisory database.
+func ParseAdvisories(data []byte) (*Advisories, error) {
+	var a Advisories
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return &a, a.init()
+}
+
+func (a *Advisories) init() error {
+	a.byClient = make(map[string][]*Advisory)
+	seen := make(map[[2]string]bool)
+	for _, adv := range a.Advisories {
+		if adv.ID == "" || adv.Client == "" || len(adv.Affected) == 0 {
+			return fmt.Errorf("advisory %q: id, client and affected are required", adv.ID)
+		}
+		// an ID may be listed once per affected client, e.g. geth and bor
+		key := [2]string{adv.ID, strings.ToLower(adv.Client)}
+		if seen[key] {
+			return fmt.Errorf("advisory %s: listed twice for client %s", adv.ID, adv.Client)
+		}
+		seen[key] = true
+		for _, r := range adv.Affected {
+			cs, err := parseRange(r)
+			if err != nil {
+				return fmt.Errorf("advisory %s: %w", adv.ID, err)
+			}
+			adv.ranges = append(adv.ranges, cs)
+		}
+		client := strings.ToLower(adv.Client)
+		a.byClient[client] = append(a.byClient[client], adv)
+	}
+	return nil
+}
+
+// parseRange parses comma separated constraints like ">=1.10.0, <1.13.15".
+func parseRange(r string) ([]constraint, error) {
+	var cs []constraint
+	for _, part := range strings.Split(r, ",") {
+		part = strings.TrimSpace(part)
+		var c constraint
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(part, op) {
+				c.op, part = op, part[len(op):]
+				break
+			}
+		}
+		if c.op == "" {
+			c.op = "="
+		}
+		var err error
+		if c.version, err = vparser.ParseVersion(part); err != nil {
+			return nil, fmt.Errorf("range %q: %w", r, err)
+		}
+		cs = append(cs, c)
+	}
+	return cs, nil
+}
+
+func (c constraint) match(v vparser.Version) bool {
+	d := v.Compare(c.version)
+	switch c.op {
+	case ">=":
+		return d >= 0
+	case "<=":
+		return d <= 0
+	case ">":
+		return d > 0
+	case "<":
+		return d < 0
+	default:
+		return d == 0
+	}
+}
+
+// Affects reports whether the version falls in one of the affected ranges.
+func (adv *Advisory) Affects(v vparser.Version) bool {
+	for _, cs := range adv.ranges {
+		matched := true
+		for _, c := range cs {
+			matched = matched && c.match(v)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the sorted IDs of the advisories affecting the client
+// version. Nodes without a parsed version match nothing.
+func (a *Advisories) Match(client string, v vparser.Version) []string {
+	if a == nil || v == (vparser.Version{}) {
+		return nil
+	}
+	var ids []string
+	for _, adv := range a.byClient[strings.ToLower(client)] {
+		if adv.Affects(v) {
+			ids = append(ids, adv.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Len returns the number of advisories.
+func (a *Advisories) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.Advisories)
+}
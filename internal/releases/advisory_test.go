@@ -0,0 +1,83 @@
+package releases_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/releases"
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+const testAdvisories = `
+advisories:
+  - id: GHSA-q26p-9cq4-7fc2
+    client: Geth
+    affected: [">=1.10.0, <1.13.15", "=1.14.0"]
+    summary: DoS via malicious p2p message
+  - id: CVE-2025-0001
+    client: geth
+    affected: ["<=1.14.0-rc1"]
+  - id: CVE-2025-0002
+    client: besu
+    affected: ["<25.1.0"]
+`
+
+func TestLoadAdvisories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "advisories.yaml")
+	if err := os.WriteFile(path, []byte(testAdvisories), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	advisories, err := releases.LoadAdvisories(path)
+	if err != nil {
+		t.Fatalf("LoadAdvisories failed: %v", err)
+	}
+	if advisories.Len() != 3 {
+		t.Fatalf("expected 3 advisories, got %d", advisories.Len())
+	}
+
+	tests := []struct {
+		client  string
+		version string
+		want    []string
+	}{
+		{"geth", "1.9.25", []string{"CVE-2025-0001"}},
+		{"geth", "1.13.14", []string{"CVE-2025-0001", "GHSA-q26p-9cq4-7fc2"}},
+		{"geth", "1.13.15", []string{"CVE-2025-0001"}},
+		{"geth", "1.14.0-rc1", []string{"CVE-2025-0001"}},
+		{"geth", "1.14.0-stable", []string{"GHSA-q26p-9cq4-7fc2"}},
+		{"geth", "1.16.2", nil},
+		{"besu", "24.12.2", []string{"CVE-2025-0002"}},
+		{"nethermind", "1.0.0", nil},
+	}
+	for _, tt := range tests {
+		v, err := vparser.ParseVersion(tt.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error = %v", tt.version, err)
+		}
+		if got := advisories.Match(tt.client, v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%s, %s) = %v, want %v", tt.client, tt.version, got, tt.want)
+		}
+	}
+	if got := advisories.Match("geth", vparser.Version{}); got != nil {
+		t.Errorf("node without version matched %v", got)
+	}
+	var none *releases.Advisories
+	if got := none.Match("geth", vparser.Version{Major: 1}); got != nil {
+		t.Errorf("nil database matched %v", got)
+	}
+}
+
+func TestParseAdvisoriesInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"advisories": [{"client": "geth", "affected": ["<1.0.0"]}]}`,
+		`{"advisories": [{"id": "X", "client": "geth", "affected": []}]}`,
+		`{"advisories": [{"id": "X", "client": "geth", "affected": ["~1.0.0"]}]}`,
+		`{"advisories": [{"id": "X", "client": "geth", "affected": ["<1.0.0"]}, {"id": "X", "client": "Geth", "affected": ["<2.0.0"]}]}`,
+	} {
+		if _, err := releases.ParseAdvisories([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package releases classifies client versions against a user-maintained
+// release manifest.
+package releases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+// dateLayout is the format of release dates in the manifest.
+const dateLayout = "2006-01-02"
+
+// Manifest lists the releases of each client, keyed by the lowercase client
+// name as parsed by vparser:
+//
+//	{
+//	  "fork": "fusaka",
+//	  "clients": {
+//	    "geth": {
+//	      "fork_ready": "1.16.0",
+//	      "releases": [{"version": "1.16.2", "date": "2025-08-05"}, ...]
+//	    }
+//	  }
+//	}
+type Manifest struct {
+	// Fork is the name of the upcoming fork fork_ready refers to.
+	Fork    string                    `json:"fork"`
+	Clients map[string]*ClientRelease `json:"clients"`
+}
+
+// ClientRelease holds the releases of a client, sorted newest first on load.
+type ClientRelease struct {
+	// ForkReady is the minimum version supporting the upcoming fork, if any.
+	ForkReady string    `json:"fork_ready,omitempty"`
+	Releases  []Release `json:"releases"`
+
+	forkReady vparser.Version
+}
+
+// Release is a published client release.
+type Release struct {
+	Version string `json:"version"`
+	Date    string `json:"date,omitempty"`
+
+	version vparser.Version
+	date    time.Time
+}
+
+// Released returns the release date, zero if the manifest has none.
+func (r Release) Released() time.Time {
+	return r.date
+}
+
+// LoadManifest reads and validates a release manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest decodes and validates a JSON release manifest.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	clients := make(map[string]*ClientRelease, len(m.Clients))
+	for name, c := range m.Clients {
+		if c == nil || len(c.Releases) == 0 {
+			return nil, fmt.Errorf("client %s: no releases", name)
+		}
+		var err error
+		for i := range c.Releases {
+			r := &c.Releases[i]
+			if r.version, err = vparser.ParseVersion(r.Version); err != nil {
+				return nil, fmt.Errorf("client %s: %w", name, err)
+			}
+			if r.Date != "" {
+				if r.date, err = time.Parse(dateLayout, r.Date); err != nil {
+					return nil, fmt.Errorf("client %s: release %s: invalid date: %w", name, r.Version, err)
+				}
+			}
+		}
+		slices.SortStableFunc(c.Releases, func(a, b Release) int {
+			return b.version.Compare(a.version)
+		})
+		if c.ForkReady != "" {
+			if c.forkReady, err = vparser.ParseVersion(c.ForkReady); err != nil {
+				return nil, fmt.Errorf("client %s: fork_ready: %w", name, err)
+			}
+		}
+		clients[strings.ToLower(name)] = c
+	}
+	m.Clients = clients
+	return &m, nil
+}
+
+// Latest returns the newest release of the client.
+func (c *ClientRelease) Latest() Release {
+	return c.Releases[0]
+}
+
+// State is the release status of a node.
+type State string
+
+const (
+	UpToDate     State = "up-to-date"
+	Behind       State = "behind"
+	NotForkReady State = "not-fork-ready"
+	// Unknown is used for clients missing from the manifest and nodes without
+	// a parsed version.
+	Unknown State = "unknown"
+)
+
+// Status is the result of classifying a client version.
+type Status struct {
+	State State `json:"state"`
+	// Behind is the number of manifest releases newer than the version.
+	Behind int `json:"behind"`
+}
+
+// Classify tags a client version. Nodes below the fork-ready version are
+// NotForkReady regardless of how many releases they are behind.
+func (m *Manifest) Classify(client string, version vparser.Version) Status {
+	c, ok := m.Clients[strings.ToLower(client)]
+	if !ok || version == (vparser.Version{}) {
+		return Status{State: Unknown}
+	}
+	var behind int
+	for _, r := range c.Releases {
+		if !version.Less(r.version) {
+			break
+		}
+		behind++
+	}
+	switch {
+	case c.ForkReady != "" && version.Less(c.forkReady):
+		return Status{State: NotForkReady, Behind: behind}
+	case behind > 0:
+		return Status{State: Behind, Behind: behind}
+	default:
+		return Status{State: UpToDate}
+	}
+}
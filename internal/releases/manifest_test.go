@@ -0,0 +1,109 @@
+package releases_test
+
+import (
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/releases"
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+const testManifest = `{
+	"fork": "fusaka",
+	"clients": {
+		"Geth": {
+			"fork_ready": "1.16.0",
+			"releases": [
+				{"version": "1.15.11", "date": "2025-05-05"},
+				{"version": "1.16.2", "date": "2025-08-05"},
+				{"version": "1.16.1", "date": "2025-07-01"},
+				{"version": "1.16.0", "date": "2025-06-26"}
+			]
+		},
+		"reth": {
+			"releases": [{"version": "v1.6.0"}, {"version": "v1.5.1"}]
+		}
+	}
+}`
+
+func mustVersion(t *testing.T, s string) vparser.Version {
+	t.Helper()
+	v, err := vparser.ParseVersion(s)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q) error = %v", s, err)
+	}
+	return v
+}
+
+func TestClassify(t *testing.T) {
+	m, err := releases.ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if latest := m.Clients["geth"].Latest(); latest.Version != "1.16.2" || latest.Released().IsZero() {
+		t.Errorf("unexpected latest release %+v", latest)
+	}
+
+	tests := []struct {
+		client  string
+		version string
+		want    releases.Status
+	}{
+		{"geth", "1.16.2-stable", releases.Status{State: releases.UpToDate}},
+		{"geth", "1.17.0-unstable", releases.Status{State: releases.UpToDate}},
+		{"geth", "1.16.1-stable", releases.Status{State: releases.Behind, Behind: 1}},
+		{"geth", "1.16.2-rc1", releases.Status{State: releases.Behind, Behind: 1}},
+		{"geth", "1.15.11-stable", releases.Status{State: releases.NotForkReady, Behind: 3}},
+		{"geth", "1.14.0", releases.Status{State: releases.NotForkReady, Behind: 4}},
+		{"reth", "1.5.1", releases.Status{State: releases.Behind, Behind: 1}},
+		{"nethermind", "1.32.4", releases.Status{State: releases.Unknown}},
+	}
+	for _, tt := range tests {
+		if got := m.Classify(tt.client, mustVersion(t, tt.version)); got != tt.want {
+			t.Errorf("Classify(%s, %s) = %+v, want %+v", tt.client, tt.version, got, tt.want)
+		}
+	}
+	if got := m.Classify("geth", vparser.Version{}); got.State != releases.Unknown {
+		t.Errorf("node without version classified as %v", got.State)
+	}
+}
+
+func TestReport(t *testing.T) {
+	m, err := releases.ParseManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	report := m.NewReport()
+	for _, v := range []string{"1.16.2", "1.16.2", "1.16.0", "1.15.11"} {
+		report.Add("geth", mustVersion(t, v))
+	}
+	report.Add("nethermind", mustVersion(t, "1.32.4"))
+	report.Add("", vparser.Version{})
+	report.Sort()
+
+	if len(report.Clients) != 2 {
+		t.Fatalf("expected 2 clients, got %d", len(report.Clients))
+	}
+	geth := report.Clients[0]
+	if geth.Client != "geth" || geth.Nodes != 4 || geth.UpToDate != 2 || geth.Behind != 1 || geth.NotForkReady != 1 {
+		t.Errorf("unexpected geth summary %+v", geth)
+	}
+	if geth.BehindBy[2] != 1 || geth.BehindBy[3] != 1 {
+		t.Errorf("unexpected behind histogram %v", geth.BehindBy)
+	}
+	if nm := report.Clients[1]; nm.Unknown != 1 || nm.Latest != "" {
+		t.Errorf("unexpected nethermind summary %+v", nm)
+	}
+}
+
+func TestParseManifestInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"clients": {"geth": {"releases": []}}}`,
+		`{"clients": {"geth": {"releases": [{"version": "latest"}]}}}`,
+		`{"clients": {"geth": {"releases": [{"version": "1.16.2", "date": "05/08/2025"}]}}}`,
+		`{"clients": {"geth": {"fork_ready": "x", "releases": [{"version": "1.16.2"}]}}}`,
+	} {
+		if _, err := releases.ParseManifest([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package releases
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/200ug/peerlogger/internal/vparser"
+)
+
+// Report summarises the release status of the nodes of a crawl per client.
+type Report struct {
+	Fork    string           `json:"fork,omitempty"`
+	Clients []*ClientSummary `json:"clients"`
+
+	manifest *Manifest
+	byName   map[string]*ClientSummary
+}
+
+// ClientSummary counts the nodes of a client per release status.
+type ClientSummary struct {
+	Client       string `json:"client"`
+	Latest       string `json:"latest,omitempty"`
+	LatestDate   string `json:"latest_date,omitempty"`
+	ForkReady    string `json:"fork_ready,omitempty"`
+	Nodes        int    `json:"nodes"`
+	UpToDate     int    `json:"up_to_date"`
+	Behind       int    `json:"behind"`
+	NotForkReady int    `json:"not_fork_ready"`
+	Unknown      int    `json:"unknown"`
+	// BehindBy counts the nodes by number of releases behind the latest.
+	BehindBy map[int]int `json:"behind_by,omitempty"`
+}
+
+// NewReport creates an empty report for the manifest.
+func (m *Manifest) NewReport() *Report {
+	return &Report{Fork: m.Fork, manifest: m, byName: make(map[string]*ClientSummary)}
+}
+
+// Add classifies a node and counts it. Nodes without a client name are
+// ignored.
+func (r *Report) Add(client string, version vparser.Version) Status {
+	client = strings.ToLower(client)
+	status := r.manifest.Classify(client, version)
+	if client == "" {
+		return status
+	}
+
+	s, ok := r.byName[client]
+	if !ok {
+		s = &ClientSummary{Client: client, BehindBy: make(map[int]int)}
+		if c, ok := r.manifest.Clients[client]; ok {
+			s.Latest, s.LatestDate, s.ForkReady = c.Latest().Version, c.Latest().Date, c.ForkReady
+		}
+		r.byName[client] = s
+		r.Clients = append(r.Clients, s)
+	}
+	s.Nodes++
+	switch status.State {
+	case UpToDate:
+		s.UpToDate++
+	case Behind:
+		s.Behind++
+	case NotForkReady:
+		s.NotForkReady++
+	default:
+		s.Unknown++
+	}
+	if status.Behind > 0 {
+		s.BehindBy[status.Behind]++
+	}
+	return status
+}
+
+// Sort orders the clients by node count, largest first.
+func (r *Report) Sort() {
+	sort.SliceStable(r.Clients, func(i, j int) bool {
+		if r.Clients[i].Nodes != r.Clients[j].Nodes {
+			return r.Clients[i].Nodes > r.Clients[j].Nodes
+		}
+		return r.Clients[i].Client < r.Clients[j].Client
+	})
+}
+
+// WriteText prints the report as a table. Clients missing from the manifest
+// are listed with all their nodes as unknown.
+func (r *Report) WriteText(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "CLIENT\tLATEST\tRELEASED\tFORK READY\tNODES\tUP-TO-DATE\tBEHIND\tNOT FORK READY\tUNKNOWN\tBEHIND BY\n")
+	for _, s := range r.Clients {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%d\t%s\n",
+			s.Client, dash(s.Latest), dash(s.LatestDate), dash(s.ForkReady), s.Nodes,
+			share(s.UpToDate, s.Nodes), share(s.Behind, s.Nodes), share(s.NotForkReady, s.Nodes),
+			s.Unknown, behindBy(s.BehindBy))
+	}
+	if r.Fork != "" {
+		fmt.Fprintf(w, "\nFork readiness refers to %s.\n", r.Fork)
+	}
+	return w.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func share(n, total int) string {
+	return fmt.Sprintf("%d (%.1f%%)", n, 100*float64(n)/float64(total))
+}
+
+// behindBy formats the behind histogram as "1:12 2:3 ...".
+func behindBy(counts map[int]int) string {
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%d:%d", k, counts[k])
+	}
+	return dash(strings.Join(parts, " "))
+}
@@ -0,0 +1,146 @@
+package sybil
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/200ug/peerlogger/internal/common"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// Actor is the blacklist audit actor of the entries added by the monitor.
+const Actor = "sybil-detector"
+
+// Result is a finding of a monitor run.
+type Result struct {
+	Finding
+	// Blacklisted is set if the offenders are on the blacklist, added by
+	// this or an earlier run.
+	Blacklisted bool
+}
+
+// Store keeps the findings of the monitor and the blacklist it adds the
+// offenders to.
+type Store interface {
+	// Blacklisted returns the blacklist entries active at the given time in
+	// the canonical form of util.ParseBlacklistEntry.
+	Blacklisted(at time.Time) ([]string, error)
+	// Blacklist adds the entries until they expire.
+	Blacklist(entries []string, reason string, expires time.Time) error
+	// SaveFindings writes the results of a run.
+	SaveFindings(detected time.Time, results []Result) error
+}
+
+// Monitor runs the detector on the nodes of each crawl round.
+type Monitor struct {
+	cfg     Config
+	ttl     time.Duration
+	privacy *util.IPPrivacy
+	store   Store
+}
+
+// NewMonitor creates a monitor blacklisting offenders for ttl, a zero ttl only
+// records the findings. The privacy mode applies to the keys in the blacklist
+// reasons, and IPs and prefixes are only blacklisted in the full mode.
+func NewMonitor(cfg Config, ttl time.Duration, privacy *util.IPPrivacy, store Store) *Monitor {
+	return &Monitor{cfg: cfg, ttl: ttl, privacy: privacy, store: store}
+}
+
+// Run detects the sybils among the nodes, blacklists offenders which aren't
+// blacklisted yet and saves the findings. Errors of single blacklist updates
+// are logged and leave the finding unmarked.
+func (m *Monitor) Run(now time.Time, nodes []Node) ([]Result, error) {
+	findings := Detect(m.cfg, nodes)
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	active := make(map[string]bool)
+	if m.ttl > 0 {
+		entries, err := m.store.Blacklisted(now)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			active[e] = true
+		}
+	}
+
+	results := make([]Result, len(findings))
+	for i, f := range findings {
+		results[i] = Result{Finding: f}
+		offenders := f.Offenders()
+		if m.ttl > 0 && len(offenders) > 0 && m.blacklists(f.Kind) {
+			results[i].Blacklisted = m.blacklist(now, f, offenders, active)
+		}
+		log.Warn("Sybil suspect detected", "kind", f.Kind, "key", f.Key, "nodes", f.Nodes,
+			"ips", f.IPs, "threshold", f.Threshold, "blacklisted", results[i].Blacklisted)
+	}
+	return results, m.store.SaveFindings(now, results)
+}
+
+// blacklists reports whether offenders of the kind are blacklisted. Blacklist
+// entries and their audit log keep the raw addresses, so IP and prefix
+// offenders are only recorded as findings unless IPs are stored in full.
+func (m *Monitor) blacklists(kind Kind) bool {
+	if kind == IP || kind == Prefix {
+		return m.privacy.StoresFull()
+	}
+	return true
+}
+
+// blacklist adds the offenders without an active entry, so repeated findings
+// don't extend the expiry or fill the audit log.
+func (m *Monitor) blacklist(now time.Time, f Finding, offenders []string, active map[string]bool) bool {
+	var added []string
+	for _, o := range offenders {
+		_, entry, err := util.ParseBlacklistEntry(o)
+		if err != nil {
+			log.Error("Invalid sybil offender", "entry", o, "err", err)
+			return false
+		}
+		if !active[entry] {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return true
+	}
+	if err := m.store.Blacklist(added, m.reason(f), now.Add(m.ttl)); err != nil {
+		log.Error("Blacklisting sybil offenders failed", "kind", f.Kind, "entries", len(added), "err", err)
+		return false
+	}
+	for _, entry := range added {
+		active[entry] = true
+	}
+	return true
+}
+
+// reason describes the finding in the blacklist, with the key anonymised as
+// it is stored along the entries.
+func (m *Monitor) reason(f Finding) string {
+	if key := AnonymizeKey(m.privacy, f.Kind, f.Key); key != "" {
+		return fmt.Sprintf("sybil %s %s: %d node IDs on %d IPs", f.Kind, key, f.Nodes, f.IPs)
+	}
+	return fmt.Sprintf("sybil %s: %d node IDs on %d IPs", f.Kind, f.Nodes, f.IPs)
+}
+
+// CrawlNodes returns the detector input of a crawl round. The ASN is looked
+// up with the geo provider, which may be nil.
+func CrawlNodes(results common.NodeSet, geo util.GeoProvider) []Node {
+	nodes := make([]Node, 0, len(results))
+	for id, n := range results {
+		endpoints := common.NodeEndpoints(n.N)
+		node := Node{ID: id, IPs: []netip.Addr{endpoints.IP, endpoints.IP6}}
+		if ip := n.N.IPAddr(); ip.IsValid() && geo != nil {
+			if data, err := geo.Lookup(ip); err == nil && data != nil && data.ASNumber != nil {
+				node.ASN = *data.ASNumber
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
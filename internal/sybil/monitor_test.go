@@ -0,0 +1,179 @@
+package sybil_test
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/sybil"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// memoryStore is a sybil.Store keeping entries and findings in memory.
+type memoryStore struct {
+	entries map[string]time.Time // entry -> expiry
+	added   []string
+	reasons []string
+	saved   [][]sybil.Result
+	failAdd bool
+}
+
+func (s *memoryStore) Blacklisted(at time.Time) ([]string, error) {
+	var active []string
+	for e, expires := range s.entries {
+		if expires.After(at) {
+			active = append(active, e)
+		}
+	}
+	return active, nil
+}
+
+func (s *memoryStore) Blacklist(entries []string, reason string, expires time.Time) error {
+	if s.failAdd {
+		return errors.New("database unavailable")
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]time.Time)
+	}
+	for _, e := range entries {
+		s.entries[e] = expires
+	}
+	s.added = append(s.added, entries...)
+	s.reasons = append(s.reasons, reason)
+	return nil
+}
+
+func (s *memoryStore) SaveFindings(detected time.Time, results []sybil.Result) error {
+	s.saved = append(s.saved, results)
+	return nil
+}
+
+// sybilNodes returns honest nodes and 20 node IDs on a single IP.
+func sybilNodes() []sybil.Node {
+	nodes := honestNodes(1000)
+	ip := netip.MustParseAddr("198.51.100.7")
+	for range 20 {
+		nodes = append(nodes, sybil.Node{ID: randomID(), IPs: []netip.Addr{ip}})
+	}
+	return nodes
+}
+
+func monitorConfig() sybil.Config {
+	cfg := sybil.DefaultConfig()
+	cfg.MinCluster = 0
+	return cfg
+}
+
+func TestMonitorRun(t *testing.T) {
+	privacy, _ := util.NewIPPrivacy(util.PrivacyFull, "", 24, 48)
+	store := &memoryStore{}
+	m := sybil.NewMonitor(monitorConfig(), time.Hour, privacy, store)
+	now := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	results, err := m.Run(now, sybilNodes())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Key != "198.51.100.7" || !results[0].Blacklisted {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if !slices.Equal(store.added, []string{"198.51.100.7"}) {
+		t.Errorf("Expected the IP to be blacklisted, got %v", store.added)
+	}
+	if want := "sybil ip 198.51.100.7: 20 node IDs on 1 IPs"; store.reasons[0] != want {
+		t.Errorf("Expected reason %q, got %q", want, store.reasons[0])
+	}
+	if len(store.saved) != 1 || len(store.saved[0]) != 1 {
+		t.Errorf("Expected the finding to be saved, got %+v", store.saved)
+	}
+
+	// an active entry isn't added again
+	results, err = m.Run(now.Add(30*time.Minute), sybilNodes())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(store.added) != 1 {
+		t.Errorf("Expected no new entries, got %v", store.added)
+	}
+	if len(results) != 1 || !results[0].Blacklisted {
+		t.Errorf("Expected the finding to be marked blacklisted, got %+v", results)
+	}
+
+	// an expired entry is
+	if _, err := m.Run(now.Add(2*time.Hour), sybilNodes()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(store.added) != 2 {
+		t.Errorf("Expected the expired entry to be added again, got %v", store.added)
+	}
+}
+
+func TestMonitorRunPrivacy(t *testing.T) {
+	privacy, _ := util.NewIPPrivacy(util.PrivacyTruncated, "", 24, 48)
+	store := &memoryStore{}
+	m := sybil.NewMonitor(sybil.DefaultConfig(), time.Hour, privacy, store)
+
+	nodes := sybilNodes()
+	var cluster []string
+	for i := range 5 {
+		id := randomID()
+		id[0], id[1], id[2] = 0xab, 0xcd, 0xef
+		nodes = append(nodes, sybil.Node{ID: id, IPs: []netip.Addr{netip.AddrFrom4([4]byte{192, 0, 2, byte(i)})}})
+		cluster = append(cluster, id.String())
+	}
+	results, err := m.Run(time.Now(), nodes)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected an IP and a keyspace finding, got %+v", results)
+	}
+	// the blacklist keeps raw addresses, so only the node IDs are added
+	for _, r := range results {
+		if r.Blacklisted != (r.Kind == sybil.Keyspace) {
+			t.Errorf("Unexpected blacklisting of %s finding %s", r.Kind, r.Key)
+		}
+	}
+	slices.Sort(cluster)
+	slices.Sort(store.added)
+	if !slices.Equal(store.added, cluster) {
+		t.Errorf("Expected only the clustered node IDs to be blacklisted, got %v", store.added)
+	}
+	if len(store.saved) != 1 || len(store.saved[0]) != 2 {
+		t.Errorf("Expected both findings to be saved, got %+v", store.saved)
+	}
+}
+
+func TestMonitorRunWithoutBlacklist(t *testing.T) {
+	store := &memoryStore{}
+	m := sybil.NewMonitor(monitorConfig(), 0, nil, store)
+
+	results, err := m.Run(time.Now(), sybilNodes())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Blacklisted {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if len(store.added) != 0 || len(store.saved) != 1 {
+		t.Errorf("Expected the finding to be recorded only, got added %v, saved %d", store.added, len(store.saved))
+	}
+}
+
+func TestMonitorRunBlacklistError(t *testing.T) {
+	store := &memoryStore{failAdd: true}
+	m := sybil.NewMonitor(monitorConfig(), time.Hour, nil, store)
+
+	results, err := m.Run(time.Now(), sybilNodes())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Blacklisted {
+		t.Errorf("Expected an unmarked finding, got %+v", results)
+	}
+	if len(store.saved) != 1 {
+		t.Errorf("Expected the finding to be saved, got %d runs", len(store.saved))
+	}
+}
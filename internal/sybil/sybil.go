@@ -0,0 +1,299 @@
+// Package sybil detects operators running many node IDs on one IP, prefix or
+// ASN, or generating IDs clustered in the keyspace to skew discovery.
+package sybil
+
+import (
+	"bytes"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"slices"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+// Kind is what a finding groups the nodes by.
+type Kind string
+
+const (
+	// IP groups the nodes by endpoint address.
+	IP Kind = "ip"
+	// Prefix groups the nodes by the /24 (IPv4) or /48 (IPv6) of the address.
+	Prefix Kind = "prefix"
+	// ASN groups the nodes by the AS of the address.
+	ASN Kind = "asn"
+	// Keyspace groups the nodes by a node ID prefix much longer than the
+	// prefix random IDs of the crawl would share.
+	Keyspace Kind = "keyspace"
+)
+
+// Default thresholds of the detector.
+const (
+	DefaultMaxPerIP     = 8
+	DefaultMaxPerPrefix = 64
+	DefaultPrefixBits4  = 24
+	DefaultPrefixBits6  = 48
+	DefaultClusterBits  = 8
+	DefaultMinCluster   = 4
+)
+
+// Config holds the detector thresholds. Groups with more node IDs than the
+// maximum are flagged, a zero maximum disables the check.
+type Config struct {
+	MaxPerIP     int
+	MaxPerPrefix int
+	// MaxPerASN is disabled by default, as cloud and hosting ASNs
+	// legitimately host thousands of nodes.
+	MaxPerASN   int
+	PrefixBits4 int
+	PrefixBits6 int
+	// ClusterBits is how many bits longer than log2 of the crawl size the
+	// keyspace prefix of a cluster is. With 8 bits, a prefix is expected to
+	// be shared by 1/256 random IDs.
+	ClusterBits int
+	// MinCluster is the number of IDs sharing a prefix to flag it, zero
+	// disables the keyspace check.
+	MinCluster int
+}
+
+// DefaultConfig returns the default thresholds.
+func DefaultConfig() Config {
+	return Config{
+		MaxPerIP:     DefaultMaxPerIP,
+		MaxPerPrefix: DefaultMaxPerPrefix,
+		PrefixBits4:  DefaultPrefixBits4,
+		PrefixBits6:  DefaultPrefixBits6,
+		ClusterBits:  DefaultClusterBits,
+		MinCluster:   DefaultMinCluster,
+	}
+}
+
+// Node is a crawled node. IPs holds its IPv4 and IPv6 endpoints, ASN is zero
+// if unknown.
+type Node struct {
+	ID  enode.ID
+	IPs []netip.Addr
+	ASN int64
+}
+
+// Finding is a group of nodes exceeding a threshold.
+type Finding struct {
+	Kind Kind `json:"kind"`
+	// Key is the IP, prefix, "AS<number>" or the keyspace prefix in the
+	// form <hex>/<bits>.
+	Key string `json:"key"`
+	// Nodes is the number of node IDs in the group, IPs the number of
+	// distinct addresses they use.
+	Nodes     int `json:"nodes"`
+	IPs       int `json:"ips"`
+	Threshold int `json:"threshold"`
+	// NodeIDs are the IDs of the group, sorted.
+	NodeIDs []enode.ID `json:"node_ids"`
+}
+
+// Detect returns the findings of a crawl, ordered by kind and descending
+// node count.
+func Detect(cfg Config, nodes []Node) []Finding {
+	var (
+		byIP     = make(map[netip.Addr]*group)
+		byPrefix = make(map[netip.Prefix]*group)
+		byASN    = make(map[int64]*group)
+	)
+	for _, n := range nodes {
+		for _, ip := range n.IPs {
+			if !ip.IsValid() {
+				continue
+			}
+			ip = ip.Unmap()
+			addTo(byIP, ip, n.ID, ip)
+			bits := cfg.PrefixBits6
+			if ip.Is4() {
+				bits = cfg.PrefixBits4
+			}
+			if prefix, err := ip.Prefix(bits); err == nil {
+				addTo(byPrefix, prefix, n.ID, ip)
+			}
+			if n.ASN != 0 {
+				addTo(byASN, n.ASN, n.ID, ip)
+			}
+		}
+	}
+
+	var findings []Finding
+	findings = appendGroups(findings, IP, cfg.MaxPerIP, byIP, netip.Addr.String)
+	findings = appendGroups(findings, Prefix, cfg.MaxPerPrefix, byPrefix, netip.Prefix.String)
+	findings = appendGroups(findings, ASN, cfg.MaxPerASN, byASN, func(asn int64) string {
+		return fmt.Sprintf("AS%d", asn)
+	})
+	return append(findings, clusters(cfg, nodes)...)
+}
+
+// group collects the node IDs and addresses of a group.
+type group struct {
+	ids map[enode.ID]bool
+	ips map[netip.Addr]bool
+}
+
+func addTo[K comparable](groups map[K]*group, key K, id enode.ID, ip netip.Addr) {
+	g := groups[key]
+	if g == nil {
+		g = &group{ids: make(map[enode.ID]bool), ips: make(map[netip.Addr]bool)}
+		groups[key] = g
+	}
+	g.ids[id] = true
+	g.ips[ip] = true
+}
+
+func appendGroups[K comparable](findings []Finding, kind Kind, max int, groups map[K]*group, key func(K) string) []Finding {
+	if max <= 0 {
+		return findings
+	}
+	start := len(findings)
+	for k, g := range groups {
+		if len(g.ids) <= max {
+			continue
+		}
+		ids := make([]enode.ID, 0, len(g.ids))
+		for id := range g.ids {
+			ids = append(ids, id)
+		}
+		findings = append(findings, Finding{
+			Kind:      kind,
+			Key:       key(k),
+			Nodes:     len(ids),
+			IPs:       len(g.ips),
+			Threshold: max,
+			NodeIDs:   sortIDs(ids),
+		})
+	}
+	sortFindings(findings[start:])
+	return findings
+}
+
+// clusters returns the keyspace prefixes shared by at least MinCluster IDs.
+// Random IDs of a crawl of n nodes share prefixes of about log2(n) bits, a
+// prefix ClusterBits longer is expected to hold 2^-ClusterBits of an ID.
+func clusters(cfg Config, nodes []Node) []Finding {
+	if cfg.MinCluster <= 0 || len(nodes) < cfg.MinCluster {
+		return nil
+	}
+	prefixBits := min(bits.Len(uint(len(nodes)))+cfg.ClusterBits, 64)
+
+	buckets := make(map[uint64][]Node)
+	for _, n := range nodes {
+		p := keyPrefix(n.ID, prefixBits)
+		buckets[p] = append(buckets[p], n)
+	}
+	var findings []Finding
+	for p, members := range buckets {
+		ids := make(map[enode.ID]bool)
+		ips := make(map[netip.Addr]bool)
+		for _, n := range members {
+			ids[n.ID] = true
+			for _, ip := range n.IPs {
+				if ip.IsValid() {
+					ips[ip.Unmap()] = true
+				}
+			}
+		}
+		if len(ids) < cfg.MinCluster {
+			continue
+		}
+		sorted := make([]enode.ID, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		findings = append(findings, Finding{
+			Kind:      Keyspace,
+			Key:       formatKeyPrefix(p, prefixBits),
+			Nodes:     len(ids),
+			IPs:       len(ips),
+			Threshold: cfg.MinCluster,
+			NodeIDs:   sortIDs(sorted),
+		})
+	}
+	sortFindings(findings)
+	return findings
+}
+
+// keyPrefix returns the first bits of the ID.
+func keyPrefix(id enode.ID, n int) uint64 {
+	var v uint64
+	for _, b := range id[:8] {
+		v = v<<8 | uint64(b)
+	}
+	return v >> (64 - n)
+}
+
+// formatKeyPrefix formats a prefix as its hex digits and length, e.g.
+// "a3f0/13" for the 13 bits 1010 0011 1111 0.
+func formatKeyPrefix(p uint64, n int) string {
+	digits := (n + 3) / 4
+	hex := fmt.Sprintf("%016x", p<<(64-n))
+	return hex[:digits] + "/" + fmt.Sprint(n)
+}
+
+func sortIDs(ids []enode.ID) []enode.ID {
+	slices.SortFunc(ids, func(a, b enode.ID) int { return bytes.Compare(a[:], b[:]) })
+	return ids
+}
+
+func sortFindings(findings []Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Nodes != findings[j].Nodes {
+			return findings[i].Nodes > findings[j].Nodes
+		}
+		return findings[i].Key < findings[j].Key
+	})
+}
+
+// Offenders returns the blacklist entries of a finding: the IP or prefix of
+// address groups and the node IDs of keyspace clusters. ASNs can't be
+// blacklisted by entry, use an exclude rule instead.
+func (f Finding) Offenders() []string {
+	switch f.Kind {
+	case IP, Prefix:
+		return []string{f.Key}
+	case Keyspace:
+		entries := make([]string, len(f.NodeIDs))
+		for i, id := range f.NodeIDs {
+			entries[i] = id.String()
+		}
+		return entries
+	default:
+		return nil
+	}
+}
+
+// AnonymizeKey applies the privacy mode to the key of IP and prefix
+// findings. Prefixes longer than the truncation length are shortened, in the
+// hmac mode the network address is hashed and the length kept, e.g.
+// "<hmac>/24". Other keys and keys which are already anonymised are returned
+// as is.
+func AnonymizeKey(privacy *util.IPPrivacy, kind Kind, key string) string {
+	if kind != IP && kind != Prefix {
+		return key
+	}
+	if ip, err := netip.ParseAddr(key); err == nil {
+		return privacy.Value(ip)
+	}
+	prefix, err := netip.ParsePrefix(key)
+	if err != nil || privacy.StoresFull() {
+		return key
+	}
+	prefix = prefix.Masked()
+	if privacy.Stores() {
+		if truncated, err := netip.ParsePrefix(privacy.Address(prefix.Addr())); err == nil &&
+			truncated.Bits() < prefix.Bits() {
+			return truncated.String()
+		}
+		return prefix.String()
+	}
+	if hash := privacy.Hash(prefix.Addr()); hash != "" {
+		return fmt.Sprintf("%s/%d", hash, prefix.Bits())
+	}
+	return ""
+}
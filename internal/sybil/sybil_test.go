@@ -0,0 +1,140 @@
+package sybil_test
+
+import (
+	"crypto/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/200ug/peerlogger/internal/sybil"
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func randomID() enode.ID {
+	var id enode.ID
+	rand.Read(id[:])
+	return id
+}
+
+// honestNodes returns nodes with random IDs, each on its own IP and /24.
+func honestNodes(n int) []sybil.Node {
+	nodes := make([]sybil.Node, n)
+	for i := range nodes {
+		ip := netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 1})
+		nodes[i] = sybil.Node{ID: randomID(), IPs: []netip.Addr{ip}, ASN: int64(64500 + i%100)}
+	}
+	return nodes
+}
+
+func TestDetect_Honest(t *testing.T) {
+	if findings := sybil.Detect(sybil.DefaultConfig(), honestNodes(20000)); len(findings) != 0 {
+		t.Errorf("Expected no findings for random IDs on distinct IPs, got %+v", findings[0])
+	}
+}
+
+func TestDetect_AddressGroups(t *testing.T) {
+	nodes := honestNodes(1000)
+	sybilIP := netip.MustParseAddr("198.51.100.7")
+	for range 20 {
+		nodes = append(nodes, sybil.Node{ID: randomID(), IPs: []netip.Addr{sybilIP}, ASN: 64496})
+	}
+	for i := range 70 {
+		ip := netip.AddrFrom4([4]byte{203, 0, 113, byte(i)})
+		v6 := netip.MustParseAddr("2001:db8::1")
+		nodes = append(nodes, sybil.Node{ID: randomID(), IPs: []netip.Addr{ip, v6}, ASN: 64496})
+	}
+
+	cfg := sybil.DefaultConfig()
+	cfg.MaxPerASN = 80
+	found := make(map[string]sybil.Finding)
+	for _, f := range sybil.Detect(cfg, nodes) {
+		found[string(f.Kind)+" "+f.Key] = f
+	}
+
+	tests := []struct {
+		key        string
+		nodes, ips int
+	}{
+		{"ip 198.51.100.7", 20, 1},
+		{"ip 2001:db8::1", 70, 1},
+		{"prefix 203.0.113.0/24", 70, 70},
+		{"prefix 2001:db8::/48", 70, 1},
+		{"asn AS64496", 90, 72},
+	}
+	for _, tt := range tests {
+		f, ok := found[tt.key]
+		if !ok {
+			t.Errorf("Missing finding %s", tt.key)
+			continue
+		}
+		if f.Nodes != tt.nodes || f.IPs != tt.ips || len(f.NodeIDs) != tt.nodes {
+			t.Errorf("Finding %s: got %d nodes on %d IPs, want %d on %d", tt.key, f.Nodes, f.IPs, tt.nodes, tt.ips)
+		}
+	}
+	if len(found) != len(tests) {
+		t.Errorf("Expected %d findings, got %d", len(tests), len(found))
+	}
+	if got := found["prefix 203.0.113.0/24"].Offenders(); len(got) != 1 || got[0] != "203.0.113.0/24" {
+		t.Errorf("Unexpected offenders %v", got)
+	}
+	if got := found["asn AS64496"].Offenders(); got != nil {
+		t.Errorf("Expected no offenders of an ASN, got %v", got)
+	}
+}
+
+func TestDetect_KeyspaceCluster(t *testing.T) {
+	nodes := honestNodes(1000)
+	// IDs ground to share their first 24 bits, on distinct IPs
+	for i := range 5 {
+		id := randomID()
+		id[0], id[1], id[2] = 0xab, 0xcd, 0xef
+		ip := netip.AddrFrom4([4]byte{192, 0, 2, byte(i)})
+		nodes = append(nodes, sybil.Node{ID: id, IPs: []netip.Addr{ip}})
+	}
+
+	findings := sybil.Detect(sybil.DefaultConfig(), nodes)
+	if len(findings) != 1 {
+		t.Fatalf("Expected one finding, got %+v", findings)
+	}
+	f := findings[0]
+	// 1005 nodes: 10 bits plus the 8 cluster bits
+	if f.Kind != sybil.Keyspace || f.Key != "abcdc/18" || f.Nodes != 5 || f.IPs != 5 {
+		t.Errorf("Unexpected finding %+v", f)
+	}
+	if offenders := f.Offenders(); len(offenders) != 5 || offenders[0] != f.NodeIDs[0].String() {
+		t.Errorf("Expected the node IDs as offenders, got %v", offenders)
+	}
+}
+
+func TestAnonymizeKey(t *testing.T) {
+	truncated, _ := util.NewIPPrivacy(util.PrivacyTruncated, "", 24, 48)
+	hmac, _ := util.NewIPPrivacy(util.PrivacyHMAC, "secret", 24, 48)
+	none, _ := util.NewIPPrivacy(util.PrivacyNone, "", 24, 48)
+	network := hmac.Hash(netip.MustParseAddr("198.51.100.0"))
+
+	tests := []struct {
+		privacy *util.IPPrivacy
+		kind    sybil.Kind
+		key     string
+		want    string
+	}{
+		{nil, sybil.IP, "198.51.100.7", "198.51.100.7"},
+		{nil, sybil.Prefix, "198.51.100.0/24", "198.51.100.0/24"},
+		{truncated, sybil.IP, "198.51.100.7", "198.51.100.0/24"},
+		{truncated, sybil.Prefix, "198.51.100.0/24", "198.51.100.0/24"},
+		{truncated, sybil.Prefix, "198.51.100.128/25", "198.51.100.0/24"},
+		{truncated, sybil.Prefix, "2a01:4f8:10a::/56", "2a01:4f8:10a::/48"},
+		{hmac, sybil.IP, "198.51.100.7", hmac.Hash(netip.MustParseAddr("198.51.100.7"))},
+		{hmac, sybil.Prefix, "198.51.100.0/24", network + "/24"},
+		{hmac, sybil.Prefix, network + "/24", network + "/24"},
+		{none, sybil.Prefix, "198.51.100.0/24", ""},
+		{none, sybil.Keyspace, "a3f0/13", "a3f0/13"},
+		{truncated, sybil.ASN, "AS24940", "AS24940"},
+	}
+	for _, tt := range tests {
+		if got := sybil.AnonymizeKey(tt.privacy, tt.kind, tt.key); got != tt.want {
+			t.Errorf("AnonymizeKey(%v, %s, %q) = %q, want %q", tt.privacy, tt.kind, tt.key, got, tt.want)
+		}
+	}
+}
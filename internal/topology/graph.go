@@ -0,0 +1,223 @@
+package topology
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/dot"
+)
+
+// Graph is the discovery topology of a single crawl. Nodes are identified by
+// their node ID, edges point from a node to an entry of its routing table.
+type Graph struct {
+	Crawl time.Time `json:"crawl"`
+	Nodes []Node    `json:"nodes"`
+	Edges []Edge    `json:"edges"`
+
+	index map[string]int
+}
+
+type Node struct {
+	ID         string `json:"id"`
+	ClientType string `json:"clientType,omitempty"`
+	Country    string `json:"country,omitempty"`
+	ASN        uint64 `json:"asn,omitempty"`
+}
+
+type Edge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Distance int    `json:"distance"`
+	Protocol string `json:"protocol"`
+}
+
+func NewGraph(crawl time.Time) *Graph {
+	return &Graph{
+		Crawl: crawl,
+		Nodes: []Node{},
+		Edges: []Edge{},
+		index: make(map[string]int),
+	}
+}
+
+// AddNode adds a node or replaces the attributes of an existing one.
+func (g *Graph) AddNode(n Node) {
+	if i, ok := g.index[n.ID]; ok {
+		g.Nodes[i] = n
+		return
+	}
+	g.index[n.ID] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, n)
+}
+
+// AddEdge adds an edge. Endpoints which are not part of the graph yet (neighbors
+// that weren't crawled themselves) are added without attributes.
+func (g *Graph) AddEdge(e Edge) {
+	for _, id := range []string{e.Source, e.Target} {
+		if _, ok := g.index[id]; !ok {
+			g.AddNode(Node{ID: id})
+		}
+	}
+	g.Edges = append(g.Edges, e)
+}
+
+// Sort orders nodes and edges by ID, so exports of the same crawl are stable.
+func (g *Graph) Sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	for i, n := range g.Nodes {
+		g.index[n.ID] = i
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Protocol < b.Protocol
+	})
+}
+
+// WriteJSON writes the graph as a JSON edge list with node attributes.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	out := dot.NewGraph(dot.Directed)
+	nodes := make(map[string]dot.Node, len(g.Nodes))
+	for _, n := range g.Nodes {
+		dn := out.Node(n.ID)
+		if n.ClientType != "" {
+			dn.Attr("client", n.ClientType)
+		}
+		if n.Country != "" {
+			dn.Attr("country", n.Country)
+		}
+		if n.ASN != 0 {
+			dn.Attr("asn", strconv.FormatUint(n.ASN, 10))
+		}
+		nodes[n.ID] = dn
+	}
+	for _, e := range g.Edges {
+		out.Edge(nodes[e.Source], nodes[e.Target]).
+			Attr("distance", strconv.Itoa(e.Distance)).
+			Attr("protocol", e.Protocol)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph in GraphML format, as understood by Gephi.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "client", For: "node", Name: "client", Type: "string"},
+			{ID: "country", For: "node", Name: "country", Type: "string"},
+			{ID: "asn", For: "node", Name: "asn", Type: "long"},
+			{ID: "distance", For: "edge", Name: "distance", Type: "int"},
+			{ID: "protocol", For: "edge", Name: "protocol", Type: "string"},
+		},
+		Graph: graphMLGraph{
+			ID:          g.Crawl.UTC().Format(time.RFC3339),
+			EdgeDefault: "directed",
+		},
+	}
+	for _, n := range g.Nodes {
+		node := graphMLNode{ID: n.ID}
+		if n.ClientType != "" {
+			node.Data = append(node.Data, graphMLData{"client", n.ClientType})
+		}
+		if n.Country != "" {
+			node.Data = append(node.Data, graphMLData{"country", n.Country})
+		}
+		if n.ASN != 0 {
+			node.Data = append(node.Data, graphMLData{"asn", strconv.FormatUint(n.ASN, 10)})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.Source,
+			Target: e.Target,
+			Data: []graphMLData{
+				{"distance", strconv.Itoa(e.Distance)},
+				{"protocol", e.Protocol},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Write writes the graph in the given format (graphml, dot or json).
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "graphml":
+		return g.WriteGraphML(w)
+	case "dot":
+		return g.WriteDOT(w)
+	case "json":
+		return g.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown graph format %q", format)
+	}
+}
@@ -0,0 +1,102 @@
+package topology_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/200ug/peerlogger/internal/topology"
+)
+
+func testGraph() *topology.Graph {
+	g := topology.NewGraph(time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC))
+	g.AddNode(topology.Node{ID: "aa", ClientType: "Geth/v1.16.2", Country: "Germany", ASN: 24940})
+	g.AddNode(topology.Node{ID: "bb", ClientType: "erigon/v3.0.0", Country: "Finland"})
+	g.AddEdge(topology.Edge{Source: "aa", Target: "bb", Distance: 256, Protocol: "discv5"})
+	g.AddEdge(topology.Edge{Source: "bb", Target: "cc", Distance: 255, Protocol: "discv4"})
+	g.Sort()
+	return g
+}
+
+func TestGraph_AddEdgeAddsMissingNodes(t *testing.T) {
+	g := testGraph()
+	if len(g.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(g.Nodes))
+	}
+	if g.Nodes[2].ID != "cc" || g.Nodes[2].ClientType != "" {
+		t.Errorf("Uncrawled neighbor should be added without attributes, got %+v", g.Nodes[2])
+	}
+	// re-adding a node replaces its attributes
+	g.AddNode(topology.Node{ID: "cc", ClientType: "nethermind"})
+	if len(g.Nodes) != 3 || g.Nodes[2].ClientType != "nethermind" {
+		t.Errorf("AddNode should update existing node, got %+v", g.Nodes)
+	}
+}
+
+func TestGraph_WriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().Write(&buf, "json"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var decoded topology.Graph
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 3 || len(decoded.Edges) != 2 {
+		t.Errorf("Expected 3 nodes and 2 edges, got %d and %d", len(decoded.Nodes), len(decoded.Edges))
+	}
+	if decoded.Edges[0].Source != "aa" || decoded.Edges[0].Protocol != "discv5" {
+		t.Errorf("Unexpected first edge: %+v", decoded.Edges[0])
+	}
+}
+
+func TestGraph_WriteGraphML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().Write(&buf, "graphml"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var doc struct {
+		Graph struct {
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid XML: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 2 {
+		t.Errorf("Expected 3 nodes and 2 edges, got %d and %d", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	if !strings.Contains(buf.String(), `<data key="asn">24940</data>`) {
+		t.Error("GraphML output should contain the ASN attribute")
+	}
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().Write(&buf, "dot"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph") {
+		t.Errorf("DOT output should be a digraph, got %q", out)
+	}
+	if strings.Count(out, "->") != 2 {
+		t.Errorf("Expected 2 edges in DOT output, got %q", out)
+	}
+}
+
+func TestGraph_WriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGraph().Write(&buf, "csv"); err == nil {
+		t.Error("Write should fail for unknown formats")
+	}
+}
@@ -1,30 +1,38 @@
 package util
 
 import (
-	"net"
+	"fmt"
+	"net/netip"
 	"strings"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/rs/zerolog/log"
 )
 
 type Blacklist struct {
-	ipNets  []*net.IPNet
-	ips     map[string]bool
+	// CIDR blocks, matched in a prefix trie
+	ipNets prefixTrie
+	ips    map[netip.Addr]bool
+	// entries that resolve to a node ID (enode URLs, hex pubkeys, node IDs)
+	nodeIDs map[enode.ID]bool
+	// anything else is matched verbatim
 	pubkeys map[string]bool
+	rules   Rules
 	mu      sync.RWMutex
 }
 
 func NewBlacklist(ipBlacklist []string, pubkeyBlacklist []string) *Blacklist {
 	b := &Blacklist{
-		ips:     make(map[string]bool),
+		ips:     make(map[netip.Addr]bool),
+		nodeIDs: make(map[enode.ID]bool),
 		pubkeys: make(map[string]bool),
 	}
 	// no need to acquire locks here as no one else is using the blacklist yet
 	b.parseIPBlacklist(ipBlacklist)
 	b.parsePubkeyBlacklist(pubkeyBlacklist)
 
-	log.Info().Int("single_ips", len(b.ips)).Int("cidr_blocks", len(b.ipNets)).Int("pubkeys", len(b.pubkeys)).Msg("Blacklist loaded")
+	log.Info().Int("single_ips", len(b.ips)).Int("cidr_blocks", b.ipNets.len).Int("pubkeys", len(b.pubkeys)+len(b.nodeIDs)).Msg("Blacklist loaded")
 
 	return b
 }
@@ -39,20 +47,20 @@ func (b *Blacklist) parseIPBlacklist(ipList []string) {
 
 		// check if defined in cidr notation
 		if strings.Contains(ipStr, "/") {
-			_, ipNet, err := net.ParseCIDR(ipStr)
+			prefix, err := netip.ParsePrefix(ipStr)
 			if err != nil {
 				log.Warn().Err(err).Str("cidr", ipStr).Msg("Invalid CIDR in blacklist, skipping")
 				continue
 			}
-			b.ipNets = append(b.ipNets, ipNet)
+			b.ipNets.insert(prefix.Masked())
 		} else {
-			// single ip
-			ip := net.ParseIP(ipStr)
-			if ip == nil {
+			// single ip, stored in canonical form so both families match regardless of notation
+			ip, err := netip.ParseAddr(ipStr)
+			if err != nil {
 				log.Warn().Str("ip", ipStr).Msg("Invalid IP address in blacklist, skipping")
 				continue
 			}
-			b.ips[ipStr] = true
+			b.ips[ip.Unmap()] = true
 		}
 	}
 }
@@ -64,6 +72,10 @@ func (b *Blacklist) parsePubkeyBlacklist(pubkeyList []string) {
 		if pubkey == "" {
 			continue
 		}
+		if id, ok := ParseNodeID(pubkey); ok {
+			b.nodeIDs[id] = true
+			continue
+		}
 		b.pubkeys[pubkey] = true
 	}
 }
@@ -73,48 +85,94 @@ func (b *Blacklist) Reload(ipBlacklist []string, pubkeyBlacklist []string) {
 	defer b.mu.Unlock()
 
 	// clear existing lists
-	b.ipNets = []*net.IPNet{}
-	b.ips = make(map[string]bool)
+	b.ipNets = prefixTrie{}
+	b.ips = make(map[netip.Addr]bool)
+	b.nodeIDs = make(map[enode.ID]bool)
 	b.pubkeys = make(map[string]bool)
 
 	// reparse
 	b.parseIPBlacklist(ipBlacklist)
 	b.parsePubkeyBlacklist(pubkeyBlacklist)
 
-	log.Info().Int("single_ips", len(b.ips)).Int("cidr_blocks", len(b.ipNets)).Int("pubkeys", len(b.pubkeys)).Msg("Blacklist reloaded")
+	log.Info().Int("single_ips", len(b.ips)).Int("cidr_blocks", b.ipNets.len).Int("pubkeys", len(b.pubkeys)+len(b.nodeIDs)).Msg("Blacklist reloaded")
 }
 
+// IsIPBlacklisted parses the IP, prefer IsAddrBlacklisted on the dial path.
 func (b *Blacklist) IsIPBlacklisted(ipStr string) bool {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		log.Warn().Str("ip", ipStr).Msg("Invalid IP address format for blacklist check")
+		return false
+	}
+	return b.IsAddrBlacklisted(addr)
+}
+
+// IsAddrBlacklisted matches the address against the IPs and CIDR blocks, in
+// constant time regardless of the size of the lists.
+func (b *Blacklist) IsAddrBlacklisted(addr netip.Addr) bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
+	return b.isIPBlacklisted(addr)
+}
 
+// isIPBlacklisted is IsAddrBlacklisted with the read lock held.
+func (b *Blacklist) isIPBlacklisted(addr netip.Addr) bool {
+	addr = addr.Unmap()
 	// exact ip match
-	if b.ips[ipStr] {
+	if b.ips[addr] {
 		return true
 	}
 	// cidr block range match
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
-		log.Warn().Str("ip", ipStr).Msg("Invalid IP address format for blacklist check")
-		return false
-	}
-	for _, ipNet := range b.ipNets {
-		if ipNet.Contains(ip) {
-			return true
-		}
-	}
-
-	return false
+	return b.ipNets.contains(addr)
 }
 
+// IsPubkeyBlacklisted accepts hex pubkeys, enode URLs and node IDs, which all
+// match entries given in any of these notations.
 func (b *Blacklist) IsPubkeyBlacklisted(pubkey string) bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return b.pubkeys[pubkey]
+	if b.pubkeys[strings.TrimSpace(pubkey)] {
+		return true
+	}
+	id, ok := ParseNodeID(pubkey)
+	return ok && b.nodeIDs[id]
+}
+
+func (b *Blacklist) IsNodeBlacklisted(id enode.ID) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.nodeIDs[id]
 }
 
 func (b *Blacklist) GetStats() (ips int, ipNets int, pubkeys int) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return len(b.ips), len(b.ipNets), len(b.pubkeys)
+	return len(b.ips), b.ipNets.len, len(b.pubkeys) + len(b.nodeIDs)
+}
+
+// Kinds of blacklist entries.
+const (
+	EntryIP     = "ip"
+	EntryPubkey = "pubkey"
+)
+
+// ParseBlacklistEntry validates a single blacklist entry and returns its kind
+// and canonical form: IPs unmapped, CIDR blocks masked and node keys in any
+// notation as node ID.
+func ParseBlacklistEntry(s string) (kind, entry string, err error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") && !strings.HasPrefix(s, "enode://") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", "", err
+		}
+		return EntryIP, prefix.Masked().String(), nil
+	}
+	if ip, err := netip.ParseAddr(s); err == nil {
+		return EntryIP, ip.Unmap().String(), nil
+	}
+	if id, ok := ParseNodeID(s); ok {
+		return EntryPubkey, id.String(), nil
+	}
+	return "", "", fmt.Errorf("%q is neither an IP, a CIDR block nor a node key", s)
 }
@@ -1,8 +1,16 @@
 package util_test
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
 	"github.com/200ug/peerlogger/internal/util"
 )
 
@@ -217,3 +225,183 @@ func TestBlacklist_InvalidCIDRAndIP(t *testing.T) {
 		t.Error("Valid IP should be blacklisted")
 	}
 }
+
+func TestBlacklist_DualStack(t *testing.T) {
+	bl := util.NewBlacklist([]string{
+		"192.168.1.1",
+		"2001:DB8::1",
+		"2001:db8:1::/48",
+		"::ffff:10.0.0.1",
+	}, []string{})
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"192.168.1.1", true},
+		{"::ffff:192.168.1.1", true},
+		{"2001:db8::1", true},
+		{"2001:0db8:0000::0001", true},
+		{"2001:db8:1:2::5", true},
+		{"2001:db8:2::1", false},
+		{"10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if result := bl.IsIPBlacklisted(tt.ip); result != tt.expected {
+				t.Errorf("IsIPBlacklisted(%s) = %v, expected %v", tt.ip, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBlacklist_NodeKeyNotations(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	n := enode.NewV4(&key.PublicKey, net.ParseIP("192.0.2.1"), 30303, 30303)
+	uncompressed := hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)[1:])
+	compressed := "0x" + hex.EncodeToString(crypto.CompressPubkey(&key.PublicKey))
+	notations := []string{n.URLv4(), uncompressed, compressed, n.ID().String()}
+
+	for _, entry := range notations {
+		bl := util.NewBlacklist(nil, []string{entry})
+		for _, query := range notations {
+			if !bl.IsPubkeyBlacklisted(query) {
+				t.Errorf("Entry %s doesn't match %s", entry, query)
+			}
+		}
+		if !bl.IsNodeBlacklisted(n.ID()) {
+			t.Errorf("Entry %s doesn't match node ID", entry)
+		}
+	}
+
+	other, _ := crypto.GenerateKey()
+	bl := util.NewBlacklist(nil, notations)
+	if bl.IsNodeBlacklisted(enode.PubkeyToIDV4(&other.PublicKey)) {
+		t.Error("Unrelated node should not be blacklisted")
+	}
+	if _, _, pubkeys := bl.GetStats(); pubkeys != 1 {
+		t.Errorf("Expected all notations to resolve to one entry, got %d", pubkeys)
+	}
+}
+
+func TestBlacklist_CIDRTrie(t *testing.T) {
+	bl := util.NewBlacklist([]string{
+		"10.0.0.0/8",
+		"10.1.0.0/16",   // nested in 10.0.0.0/8
+		"172.16.5.7/24", // host bits are ignored
+		"::ffff:192.0.2.0/120",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+		"fd00::/8",
+	}, nil)
+	_, cidrs, _ := bl.GetStats()
+	if cidrs != 7 {
+		t.Errorf("Expected 7 CIDRs, got %d", cidrs)
+	}
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.255.0.1", true},
+		{"10.1.2.3", true},
+		{"11.0.0.1", false},
+		{"172.16.5.200", true},
+		{"172.16.6.1", false},
+		{"192.0.2.99", true},
+		{"::ffff:192.0.2.99", true},
+		{"192.0.3.1", false},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::1", false},
+		{"fdab::1", true},
+		{"fe80::1", false},
+		// IPv4 prefixes don't match IPv6 addresses with the same bits
+		{"a00::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if result := bl.IsAddrBlacklisted(netip.MustParseAddr(tt.ip)); result != tt.expected {
+				t.Errorf("IsAddrBlacklisted(%s) = %v, expected %v", tt.ip, result, tt.expected)
+			}
+		})
+	}
+	if bl.IsAddrBlacklisted(netip.Addr{}) {
+		t.Error("The zero address should not be blacklisted")
+	}
+
+	all := util.NewBlacklist([]string{"0.0.0.0/0"}, nil)
+	if !all.IsAddrBlacklisted(netip.MustParseAddr("203.0.113.1")) || all.IsAddrBlacklisted(netip.MustParseAddr("2001:db8::1")) {
+		t.Error("0.0.0.0/0 should match all IPv4 and no IPv6 addresses")
+	}
+}
+
+// randomCIDRs returns n distinct IPv4 /24 and IPv6 /48 blocks.
+func randomCIDRs(n int) []string {
+	cidrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], uint32(i)*2654435761)
+			cidrs = append(cidrs, netip.PrefixFrom(netip.AddrFrom4(b), 24).Masked().String())
+		} else {
+			var b [16]byte
+			b[0], b[1] = 0x20, 0x01
+			binary.BigEndian.PutUint32(b[2:], uint32(i)*2654435761)
+			cidrs = append(cidrs, netip.PrefixFrom(netip.AddrFrom16(b), 48).Masked().String())
+		}
+	}
+	return cidrs
+}
+
+func BenchmarkBlacklist_IsAddrBlacklisted(b *testing.B) {
+	addrs := []netip.Addr{
+		netip.MustParseAddr("198.51.100.7"),
+		netip.MustParseAddr("2a01:4f8:10a:1::2"),
+	}
+	for _, size := range []int{100, 10_000, 100_000} {
+		bl := util.NewBlacklist(randomCIDRs(size), nil)
+		b.Run(fmt.Sprintf("cidrs=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bl.IsAddrBlacklisted(addrs[i%len(addrs)])
+			}
+		})
+	}
+}
+
+func BenchmarkBlacklist_IsIPBlacklisted(b *testing.B) {
+	bl := util.NewBlacklist(randomCIDRs(10_000), nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.IsIPBlacklisted("198.51.100.7")
+	}
+}
+
+func TestParseBlacklistEntry(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	node := enode.NewV4(&key.PublicKey, net.ParseIP("192.0.2.1"), 30303, 30303)
+	id := node.ID().String()
+
+	tests := []struct {
+		in, kind, entry string
+	}{
+		{"192.0.2.1", util.EntryIP, "192.0.2.1"},
+		{"::ffff:192.0.2.1", util.EntryIP, "192.0.2.1"},
+		{" 203.0.113.77/24 ", util.EntryIP, "203.0.113.0/24"},
+		{"2001:db8::1/32", util.EntryIP, "2001:db8::/32"},
+		{node.URLv4(), util.EntryPubkey, id},
+		{id, util.EntryPubkey, id},
+		{"203.0.113.0/33", "", ""},
+		{"not-an-entry", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		kind, entry, err := util.ParseBlacklistEntry(tt.in)
+		if tt.kind == "" {
+			if err == nil {
+				t.Errorf("ParseBlacklistEntry(%q) = %s %s, want an error", tt.in, kind, entry)
+			}
+			continue
+		}
+		if err != nil || kind != tt.kind || entry != tt.entry {
+			t.Errorf("ParseBlacklistEntry(%q) = %s %s, %v; want %s %s", tt.in, kind, entry, err, tt.kind, tt.entry)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
@@ -11,13 +12,42 @@ import (
 )
 
 type EnvConfig struct {
-	LogLevel            string `env:"LOG_LEVEL" envDefault:"info"`
-	DBURL               string `env:"DB_URL,notEmpty"`
-	IPBlacklistPath     string `env:"IP_BLACKLIST_PATH"`
-	PubkeyBlacklistPath string `env:"PUBKEY_BLACKLIST_PATH"`
-	GeoIPCityDBPath     string `env:"GEOIP_CITY_DB_PATH,notEmpty"`
-	GeoIPASNDBPath      string `env:"GEOIP_ASN_DB_PATH,notEmpty"`
-	ENRDBPath           string `env:"ENR_DB_PATH" envDefault:"./enr-data/enode.db"`
+	LogLevel            string   `env:"LOG_LEVEL" envDefault:"info"`
+	DBURL               string   `env:"DB_URL,notEmpty"`
+	IPBlacklistPath     string   `env:"IP_BLACKLIST_PATH"`
+	PubkeyBlacklistPath string   `env:"PUBKEY_BLACKLIST_PATH"`
+	GeoProviders        []string `env:"GEO_PROVIDERS" envDefault:"maxmind" envSeparator:","`
+	GeoIPCityDBPath     string   `env:"GEOIP_CITY_DB_PATH"`
+	GeoIPASNDBPath      string   `env:"GEOIP_ASN_DB_PATH"`
+	GeoRangeFiles       []string `env:"GEO_RANGE_FILES" envSeparator:","`
+	GeoIPCacheSize      int      `env:"GEOIP_CACHE_SIZE" envDefault:"131072"`
+	ENRDBPath           string   `env:"ENR_DB_PATH" envDefault:"./enr-data/enode.db"`
+	CrawlMode           string   `env:"CRAWL_MODE" envDefault:"random"`
+	ENRFallback         bool     `env:"ENR_FALLBACK" envDefault:"false"`
+	DualStack           bool     `env:"DUAL_STACK" envDefault:"true"`
+	PreferIPv6          bool     `env:"PREFER_IPV6" envDefault:"false"`
+	BeaconProbe         bool     `env:"BEACON_PROBE" envDefault:"false"`
+	ReleaseManifestPath string   `env:"RELEASE_MANIFEST_PATH"`
+	AdvisoryPath        string   `env:"ADVISORY_PATH"`
+	HostingRangesPath   string   `env:"HOSTING_RANGES_PATH"`
+	HostingASNPath      string   `env:"HOSTING_ASN_PATH"`
+	IPPrivacyMode       string   `env:"IP_PRIVACY_MODE" envDefault:"full"`
+	IPHMACKey           string   `env:"IP_HMAC_KEY"`
+	IPTruncateV4        int      `env:"IP_TRUNCATE_V4" envDefault:"24"`
+	IPTruncateV6        int      `env:"IP_TRUNCATE_V6" envDefault:"48"`
+	IPRetentionDays     int      `env:"IP_RETENTION_DAYS" envDefault:"0"`
+	IPRetentionMode     string   `env:"IP_RETENTION_MODE" envDefault:"truncated"`
+	SybilDetection      bool     `env:"SYBIL_DETECTION" envDefault:"true"`
+	SybilMaxPerIP       int      `env:"SYBIL_MAX_PER_IP" envDefault:"8"`
+	SybilMaxPerPrefix   int      `env:"SYBIL_MAX_PER_PREFIX" envDefault:"64"`
+	SybilMaxPerASN      int      `env:"SYBIL_MAX_PER_ASN" envDefault:"0"`
+	SybilMinCluster     int      `env:"SYBIL_MIN_CLUSTER" envDefault:"4"`
+	SybilClusterBits    int      `env:"SYBIL_CLUSTER_BITS" envDefault:"8"`
+	// SybilBlacklistTTL blacklists offenders for this long, zero only records findings
+	SybilBlacklistTTL time.Duration `env:"SYBIL_BLACKLIST_TTL" envDefault:"0"`
+	APIListenAddr     string        `env:"API_LISTEN_ADDR"`
+	// AdminTokens maps admin names to their bearer tokens, "alice:secret,bob:..."
+	AdminTokens map[string]string `env:"ADMIN_TOKENS" envSeparator:"," envKeyValSeparator:":"`
 }
 
 func LoadEnv() *EnvConfig {
@@ -5,14 +5,27 @@ import (
 	"net/netip"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/oschwald/geoip2-golang/v2"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultGeoCacheSize is the number of IPs whose lookup results are cached.
+const DefaultGeoCacheSize = 1 << 17
+
+var (
+	geoCacheHits   = metrics.NewRegisteredCounter("geoip/cache/hits", nil)
+	geoCacheMisses = metrics.NewRegisteredCounter("geoip/cache/misses", nil)
+)
+
+// GeoIP looks up IPs in MaxMind databases. Lookups only take the read lock
+// and results are cached per IP until a database is reloaded.
 type GeoIP struct {
 	cityDB *geoip2.Reader
 	asnDB  *geoip2.Reader
 	mu     sync.RWMutex
+	cache  *lru.Cache[netip.Addr, *GeoData] // nil if disabled
 }
 
 type GeoData struct {
@@ -20,12 +33,30 @@ type GeoData struct {
 	CountryCode *string
 	CityName    *string
 	ASNumber    *int64
+
+	ContinentCode   *string
+	ContinentName   *string
+	SubdivisionCode *string // ISO 3166-2 code of the largest subdivision
+	SubdivisionName *string
+	TimeZone        *string // IANA time zone, e.g. Europe/Berlin
+	Latitude        *float64
+	Longitude       *float64
+	AccuracyRadius  *uint16 // in km around latitude/longitude
+	ASOrganization  *string
 }
 
 // cityDBPath: path to GeoLite2-City.mmdb
 // asnDBPath: path to GeoLite2-ASN.mmdb
 func NewGeoIP(cityDBPath, asnDBPath string) (*GeoIP, error) {
+	return NewCachedGeoIP(cityDBPath, asnDBPath, DefaultGeoCacheSize)
+}
+
+// NewCachedGeoIP is NewGeoIP with the given cache size, 0 disables caching.
+func NewCachedGeoIP(cityDBPath, asnDBPath string, cacheSize int) (*GeoIP, error) {
 	provider := &GeoIP{}
+	if cacheSize > 0 {
+		provider.cache = lru.NewCache[netip.Addr, *GeoData](cacheSize)
+	}
 	if cityDBPath != "" {
 		if err := provider.LoadCityDatabase(cityDBPath); err != nil {
 			return nil, fmt.Errorf("failed to load city database: %w", err)
@@ -40,48 +71,69 @@ func NewGeoIP(cityDBPath, asnDBPath string) (*GeoIP, error) {
 }
 
 func (g *GeoIP) LoadCityDatabase(dbPath string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	// close existing db if open
-	if g.cityDB != nil {
-		if err := g.cityDB.Close(); err != nil {
-			log.Warn().Err(err).Msg("Failed to close existing city database")
-		}
-	}
+	// open the new db first so lookups keep using the old one on failure
 	db, err := geoip2.Open(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open city database at %s: %w", dbPath, err)
 	}
+
+	g.mu.Lock()
+	old := g.cityDB
 	g.cityDB = db
+	g.purgeCache()
+	g.mu.Unlock()
+
+	// close the replaced db once no lookup holds the read lock
+	if old != nil {
+		if err := old.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to close existing city database")
+		}
+	}
 	log.Info().Str("path", dbPath).Msg("GeoLite2 City database loaded successfully")
 
 	return nil
 }
 
 func (g *GeoIP) LoadASNDatabase(dbPath string) error {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	// close existing db if open
-	if g.asnDB != nil {
-		if err := g.asnDB.Close(); err != nil {
-			log.Warn().Err(err).Msg("Failed to close existing ASN database")
-		}
-	}
+	// open the new db first so lookups keep using the old one on failure
 	db, err := geoip2.Open(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open ASN database at %s: %w", dbPath, err)
 	}
+
+	g.mu.Lock()
+	old := g.asnDB
 	g.asnDB = db
+	g.purgeCache()
+	g.mu.Unlock()
+
+	// close the replaced db once no lookup holds the read lock
+	if old != nil {
+		if err := old.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to close existing ASN database")
+		}
+	}
 	log.Info().Str("path", dbPath).Msg("GeoLite2 ASN database loaded successfully")
 
 	return nil
 }
 
+// Lookup returns the data of the IP. The result may be shared with other
+// callers through the cache and must not be modified.
 func (g *GeoIP) Lookup(ip netip.Addr) (*GeoData, error) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+	// IPv4-mapped IPv6 addresses (from dual-stack sockets) are looked up as IPv4
+	ip = ip.Unmap()
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.cache != nil {
+		if geoData, ok := g.cache.Get(ip); ok {
+			geoCacheHits.Inc(1)
+			return geoData, nil
+		}
+		geoCacheMisses.Inc(1)
+	}
+
 	geoData := &GeoData{}
 	if g.cityDB != nil {
 		cityRecord, err := g.cityDB.City(ip)
@@ -99,9 +151,25 @@ func (g *GeoIP) Lookup(ip netip.Addr) (*GeoData, error) {
 			g.extractASNData(asnRecord, geoData)
 		}
 	}
+	if g.cache != nil {
+		g.cache.Add(ip, geoData)
+	}
 	return geoData, nil
 }
 
+// purgeCache drops the cached results, called with the write lock held when
+// a database is replaced.
+func (g *GeoIP) purgeCache() {
+	if g.cache != nil {
+		g.cache.Purge()
+	}
+}
+
+// CacheStats returns the process-wide cache hit and miss counts.
+func CacheStats() (hits, misses int64) {
+	return geoCacheHits.Snapshot().Count(), geoCacheMisses.Snapshot().Count()
+}
+
 func (g *GeoIP) extractCityData(record *geoip2.City, geoData *GeoData) {
 	if len(record.Country.Names.English) > 0 {
 		geoData.CountryName = &record.Country.Names.English
@@ -112,6 +180,31 @@ func (g *GeoIP) extractCityData(record *geoip2.City, geoData *GeoData) {
 	if len(record.City.Names.English) > 0 {
 		geoData.CityName = &record.City.Names.English
 	}
+	if len(record.Continent.Code) > 0 {
+		geoData.ContinentCode = &record.Continent.Code
+	}
+	if len(record.Continent.Names.English) > 0 {
+		geoData.ContinentName = &record.Continent.Names.English
+	}
+	if len(record.Subdivisions) > 0 {
+		sub := record.Subdivisions[0]
+		if len(sub.ISOCode) > 0 {
+			geoData.SubdivisionCode = &sub.ISOCode
+		}
+		if len(sub.Names.English) > 0 {
+			geoData.SubdivisionName = &sub.Names.English
+		}
+	}
+	if len(record.Location.TimeZone) > 0 {
+		geoData.TimeZone = &record.Location.TimeZone
+	}
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		geoData.Latitude = record.Location.Latitude
+		geoData.Longitude = record.Location.Longitude
+		if record.Location.AccuracyRadius > 0 {
+			geoData.AccuracyRadius = &record.Location.AccuracyRadius
+		}
+	}
 }
 
 func (g *GeoIP) extractASNData(record *geoip2.ASN, geoData *GeoData) {
@@ -119,6 +212,9 @@ func (g *GeoIP) extractASNData(record *geoip2.ASN, geoData *GeoData) {
 		asNum := int64(record.AutonomousSystemNumber)
 		geoData.ASNumber = &asNum
 	}
+	if len(record.AutonomousSystemOrganization) > 0 {
+		geoData.ASOrganization = &record.AutonomousSystemOrganization
+	}
 }
 
 func (g *GeoIP) GetDatabaseInfo() map[string]interface{} {
@@ -128,6 +224,10 @@ func (g *GeoIP) GetDatabaseInfo() map[string]interface{} {
 	info := map[string]interface{}{
 		"city_db_loaded": g.cityDB != nil,
 		"asn_db_loaded":  g.asnDB != nil,
+		"cache_entries":  0,
+	}
+	if g.cache != nil {
+		info["cache_entries"] = g.cache.Len()
 	}
 
 	return info
@@ -149,6 +249,7 @@ func (g *GeoIP) Close() error {
 		}
 		g.asnDB = nil
 	}
+	g.purgeCache()
 
 	return nil
 }
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GeoProvider looks up the location and network of an IP address. Lookup
+// returns a non-nil GeoData with the fields the source has data for.
+type GeoProvider interface {
+	Lookup(ip netip.Addr) (*GeoData, error)
+	Close() error
+}
+
+// GeoConfig selects and configures the geolocation providers.
+type GeoConfig struct {
+	// Providers is the fallback order of providers: maxmind, range or none.
+	Providers []string
+	// MaxMind GeoLite2/GeoIP2 databases
+	CityDBPath string
+	ASNDBPath  string
+	// CacheSize is the number of MaxMind lookup results cached, 0 disables
+	// the cache.
+	CacheSize int
+	// RangeFiles are format:path pairs, see LoadRangeGeo.
+	RangeFiles []string
+}
+
+// NewGeoProvider creates the configured providers, chained in order if there
+// is more than one. Providers without configured files are skipped, so the
+// result is a NoopGeo if nothing is available.
+func NewGeoProvider(cfg GeoConfig) (GeoProvider, error) {
+	var providers []GeoProvider
+	for _, name := range cfg.Providers {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "maxmind":
+			if cfg.CityDBPath == "" && cfg.ASNDBPath == "" {
+				log.Info().Msg("No MaxMind database paths configured, skipping MaxMind provider")
+				continue
+			}
+			geo, err := NewCachedGeoIP(cfg.CityDBPath, cfg.ASNDBPath, cfg.CacheSize)
+			if err != nil {
+				closeAll(providers)
+				return nil, fmt.Errorf("maxmind provider: %w", err)
+			}
+			providers = append(providers, geo)
+		case "range":
+			if len(cfg.RangeFiles) == 0 {
+				log.Info().Msg("No range files configured, skipping range provider")
+				continue
+			}
+			geo, err := LoadRangeGeo(cfg.RangeFiles...)
+			if err != nil {
+				closeAll(providers)
+				return nil, fmt.Errorf("range provider: %w", err)
+			}
+			providers = append(providers, geo)
+		case "none", "":
+		default:
+			closeAll(providers)
+			return nil, fmt.Errorf("unknown geo provider %q", name)
+		}
+	}
+
+	switch len(providers) {
+	case 0:
+		return NoopGeo{}, nil
+	case 1:
+		return providers[0], nil
+	default:
+		return ChainGeo(providers), nil
+	}
+}
+
+func closeAll(providers []GeoProvider) {
+	for _, p := range providers {
+		p.Close()
+	}
+}
+
+// NoopGeo is used when no geolocation data is available.
+type NoopGeo struct{}
+
+func (NoopGeo) Lookup(netip.Addr) (*GeoData, error) { return &GeoData{}, nil }
+func (NoopGeo) Close() error                        { return nil }
+
+// ChainGeo queries providers in order. Each field is taken from the first
+// provider which has data for it, so e.g. a range file can fill in the ASN
+// when only the MaxMind city database is available.
+type ChainGeo []GeoProvider
+
+func (c ChainGeo) Lookup(ip netip.Addr) (*GeoData, error) {
+	result := &GeoData{}
+	var errs []error
+	for _, p := range c {
+		geoData, err := p.Lookup(ip)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.merge(geoData)
+	}
+	if len(errs) == len(c) && len(c) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+func (c ChainGeo) Close() error {
+	var errs []error
+	for _, p := range c {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// merge fills the fields of g that are unset from o.
+func (g *GeoData) merge(o *GeoData) {
+	if o == nil {
+		return
+	}
+	fill(&g.CountryName, o.CountryName)
+	fill(&g.CountryCode, o.CountryCode)
+	fill(&g.CityName, o.CityName)
+	fill(&g.ASNumber, o.ASNumber)
+	fill(&g.ContinentCode, o.ContinentCode)
+	fill(&g.ContinentName, o.ContinentName)
+	fill(&g.SubdivisionCode, o.SubdivisionCode)
+	fill(&g.SubdivisionName, o.SubdivisionName)
+	fill(&g.TimeZone, o.TimeZone)
+	if g.Latitude == nil && o.Latitude != nil && o.Longitude != nil {
+		g.Latitude, g.Longitude, g.AccuracyRadius = o.Latitude, o.Longitude, o.AccuracyRadius
+	}
+	fill(&g.ASOrganization, o.ASOrganization)
+}
+
+func fill[T any](dst **T, src *T) {
+	if *dst == nil {
+		*dst = src
+	}
+}
@@ -0,0 +1,89 @@
+package util_test
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+type staticGeo struct {
+	data *util.GeoData
+	err  error
+}
+
+func (s staticGeo) Lookup(netip.Addr) (*util.GeoData, error) { return s.data, s.err }
+func (s staticGeo) Close() error                             { return nil }
+
+func TestChainGeo_FallsBack(t *testing.T) {
+	country, city, org := "Germany", "Falkenstein", "Hetzner Online GmbH"
+	asn := int64(24940)
+	chain := util.ChainGeo{
+		staticGeo{err: errors.New("database not loaded")},
+		staticGeo{data: &util.GeoData{CountryName: &country, CityName: &city}},
+		staticGeo{data: &util.GeoData{CountryName: new(string), ASNumber: &asn, ASOrganization: &org}},
+	}
+	result, err := chain.Lookup(netip.MustParseAddr("88.99.0.1"))
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result.CountryName != &country || result.CityName != &city {
+		t.Errorf("Fields of the first provider with data should win: %+v", result)
+	}
+	if result.ASNumber == nil || *result.ASNumber != asn || result.ASOrganization != &org {
+		t.Errorf("Missing fields should be filled from later providers: %+v", result)
+	}
+
+	failing := util.ChainGeo{staticGeo{err: errors.New("a")}, staticGeo{err: errors.New("b")}}
+	if _, err := failing.Lookup(netip.MustParseAddr("88.99.0.1")); err == nil {
+		t.Error("Lookup should fail when all providers fail")
+	}
+}
+
+func TestNewGeoProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     util.GeoConfig
+		want    string
+		wantErr bool
+	}{
+		{"none", util.GeoConfig{Providers: []string{"none"}}, "util.NoopGeo", false},
+		{"maxmind without paths", util.GeoConfig{Providers: []string{"maxmind"}}, "util.NoopGeo", false},
+		{"maxmind missing file", util.GeoConfig{Providers: []string{"maxmind"}, CityDBPath: "/non/existent.mmdb"}, "", true},
+		{"unknown", util.GeoConfig{Providers: []string{"ipinfo"}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geo, err := util.NewGeoProvider(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGeoProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer geo.Close()
+			if got := fmt.Sprintf("%T", geo); got != tt.want {
+				t.Errorf("NewGeoProvider() = %s, want %s", got, tt.want)
+			}
+			if result, err := geo.Lookup(netip.MustParseAddr("8.8.8.8")); err != nil || result == nil {
+				t.Errorf("Lookup() = %v, %v", result, err)
+			}
+		})
+	}
+
+	path := writeRangeFile(t, "ranges.csv", "8.8.8.0,8.8.8.255,US\n")
+	geo, err := util.NewGeoProvider(util.GeoConfig{
+		Providers:  []string{"maxmind", "range"},
+		CityDBPath: "",
+		RangeFiles: []string{"dbip-country:" + path},
+	})
+	if err != nil {
+		t.Fatalf("NewGeoProvider failed: %v", err)
+	}
+	result, _ := geo.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if result.CountryCode == nil || *result.CountryCode != "US" {
+		t.Errorf("Range provider not used: %+v", result)
+	}
+}
@@ -0,0 +1,249 @@
+package util
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Range file formats accepted by LoadRangeGeo.
+const (
+	// RangeDBIPCountry is the DB-IP country CSV: start,end,country
+	RangeDBIPCountry = "dbip-country"
+	// RangeDBIPCity is the DB-IP city CSV:
+	// start,end,continent,country,region,city,latitude,longitude
+	RangeDBIPCity = "dbip-city"
+	// RangeDBIPASN is the DB-IP ASN CSV: start,end,asn,organisation
+	RangeDBIPASN = "dbip-asn"
+	// RangeIPtoASN is the iptoasn.com TSV: start,end,asn,country,description
+	RangeIPtoASN = "iptoasn"
+)
+
+// RangeGeo looks up IPs in range files (e.g. DB-IP or IPtoASN dumps), each
+// loaded into an interval tree. Results of the files are merged in order.
+type RangeGeo struct {
+	trees []*intervalTree
+}
+
+// LoadRangeGeo loads range files given as format:path, e.g.
+// "dbip-city:/app/geoip/dbip-city-lite.csv".
+func LoadRangeGeo(files ...string) (*RangeGeo, error) {
+	g := &RangeGeo{}
+	for _, file := range files {
+		format, path, ok := strings.Cut(file, ":")
+		if !ok {
+			return nil, fmt.Errorf("range file %q: expected format:path", file)
+		}
+		tree, err := loadRangeFile(format, path)
+		if err != nil {
+			return nil, fmt.Errorf("range file %s: %w", path, err)
+		}
+		log.Info().Str("path", path).Str("format", format).Int("ranges", tree.len).Msg("Range file loaded successfully")
+		g.trees = append(g.trees, tree)
+	}
+	return g, nil
+}
+
+func (g *RangeGeo) Lookup(ip netip.Addr) (*GeoData, error) {
+	ip = ip.Unmap()
+	result := &GeoData{}
+	for _, t := range g.trees {
+		result.merge(t.lookup(ip))
+	}
+	return result, nil
+}
+
+func (g *RangeGeo) Close() error {
+	return nil
+}
+
+func loadRangeFile(format, path string) (*intervalTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readRanges(format, f)
+}
+
+// readRanges parses a range file. Rows that can't be parsed are skipped.
+func readRanges(format string, r io.Reader) (*intervalTree, error) {
+	var parse func(row []string, in *interner) *GeoData
+	minFields := 3
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	switch format {
+	case RangeDBIPCountry:
+		parse = func(row []string, in *interner) *GeoData {
+			return &GeoData{CountryCode: in.str(row[2])}
+		}
+	case RangeDBIPCity:
+		minFields = 8
+		parse = func(row []string, in *interner) *GeoData {
+			d := &GeoData{
+				ContinentCode:   in.str(row[2]),
+				CountryCode:     in.str(row[3]),
+				SubdivisionName: in.str(row[4]),
+				CityName:        in.str(row[5]),
+			}
+			lat, latErr := strconv.ParseFloat(row[6], 64)
+			lon, lonErr := strconv.ParseFloat(row[7], 64)
+			if latErr == nil && lonErr == nil {
+				d.Latitude, d.Longitude = &lat, &lon
+			}
+			return d
+		}
+	case RangeDBIPASN, RangeIPtoASN:
+		minFields = 4
+		orgField := 3
+		if format == RangeIPtoASN {
+			cr.Comma = '\t'
+			minFields, orgField = 5, 4
+		}
+		parse = func(row []string, in *interner) *GeoData {
+			asn, err := strconv.ParseInt(row[2], 10, 64)
+			if err != nil || asn == 0 { // 0 is "not routed" in iptoasn
+				return nil
+			}
+			d := &GeoData{ASNumber: &asn, ASOrganization: in.str(row[orgField])}
+			if format == RangeIPtoASN && row[3] != "None" {
+				d.CountryCode = in.str(row[3])
+			}
+			return d
+		}
+	default:
+		return nil, fmt.Errorf("unknown range file format %q", format)
+	}
+
+	in := &interner{m: make(map[string]*string)}
+	var ranges []ipRange
+	var skipped int
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < minFields {
+			skipped++
+			continue
+		}
+		start, startErr := netip.ParseAddr(row[0])
+		end, endErr := netip.ParseAddr(row[1])
+		if startErr != nil || endErr != nil || end.Less(start) || start.Is4() != end.Is4() {
+			skipped++
+			continue
+		}
+		if data := parse(row, in); data != nil {
+			ranges = append(ranges, ipRange{start: start.Unmap(), end: end.Unmap(), data: data})
+		}
+	}
+	if skipped > 0 {
+		log.Debug().Str("format", format).Int("rows", skipped).Msg("Skipped malformed range rows")
+	}
+	return newIntervalTree(ranges), nil
+}
+
+// interner shares the strings repeated across rows, e.g. country codes.
+type interner struct {
+	m map[string]*string
+}
+
+func (in *interner) str(s string) *string {
+	if s == "" {
+		return nil
+	}
+	if p, ok := in.m[s]; ok {
+		return p
+	}
+	s = strings.Clone(s) // don't keep the whole CSV line alive
+	p := &s
+	in.m[s] = p
+	return p
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	data       *GeoData
+}
+
+// intervalTree is a static augmented interval tree, balanced by building it
+// from the ranges sorted by start address. Overlapping ranges are allowed,
+// lookups return the most specific one.
+type intervalTree struct {
+	root *intervalNode
+	len  int
+}
+
+type intervalNode struct {
+	r           ipRange
+	maxEnd      netip.Addr // largest end address in the subtree
+	left, right *intervalNode
+}
+
+func newIntervalTree(ranges []ipRange) *intervalTree {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return &intervalTree{root: buildInterval(ranges), len: len(ranges)}
+}
+
+func buildInterval(ranges []ipRange) *intervalNode {
+	if len(ranges) == 0 {
+		return nil
+	}
+	mid := len(ranges) / 2
+	n := &intervalNode{r: ranges[mid], maxEnd: ranges[mid].end}
+	n.left = buildInterval(ranges[:mid])
+	n.right = buildInterval(ranges[mid+1:])
+	for _, c := range []*intervalNode{n.left, n.right} {
+		if c != nil && n.maxEnd.Less(c.maxEnd) {
+			n.maxEnd = c.maxEnd
+		}
+	}
+	return n
+}
+
+// lookup returns the data of the most specific range containing ip, or nil.
+func (t *intervalTree) lookup(ip netip.Addr) *GeoData {
+	var best *ipRange
+	var search func(n *intervalNode)
+	search = func(n *intervalNode) {
+		if n == nil || n.maxEnd.Less(ip) {
+			return
+		}
+		search(n.left)
+		if ip.Less(n.r.start) {
+			return // all ranges to the right start after ip
+		}
+		if !n.r.end.Less(ip) && (best == nil || narrower(n.r, *best)) {
+			best = &n.r
+		}
+		search(n.right)
+	}
+	search(t.root)
+	if best == nil {
+		return nil
+	}
+	return best.data
+}
+
+// narrower reports whether a is more specific than b. Both contain the same
+// address, so for nested ranges the later start or earlier end is narrower.
+func narrower(a, b ipRange) bool {
+	if a.start != b.start {
+		return b.start.Less(a.start)
+	}
+	return a.end.Less(b.end)
+}
@@ -0,0 +1,89 @@
+package util_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func writeRangeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write range file: %v", err)
+	}
+	return path
+}
+
+func TestRangeGeo_Lookup(t *testing.T) {
+	city := writeRangeFile(t, "dbip-city.csv", `1.0.0.0,1.0.0.255,OC,AU,Queensland,"South Brisbane",-27.4767,153.017
+8.8.8.0,8.8.8.255,NA,US,California,"Mountain View",37.4056,-122.078
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,NA,US,California,"Mountain View",37.4056,-122.078
+not-an-ip,1.0.0.1,EU,DE,,,0,0
+`)
+	asn := writeRangeFile(t, "ip2asn.tsv", "8.0.0.0\t8.127.255.255\t3356\tUS\tLEVEL3\n"+
+		"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n"+
+		"9.0.0.0\t9.255.255.255\t0\tNone\tNot routed\n")
+
+	geo, err := util.LoadRangeGeo("dbip-city:"+city, "iptoasn:"+asn)
+	if err != nil {
+		t.Fatalf("LoadRangeGeo failed: %v", err)
+	}
+	defer geo.Close()
+
+	result, err := geo.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if result.CityName == nil || *result.CityName != "Mountain View" || result.ContinentCode == nil || *result.ContinentCode != "NA" {
+		t.Errorf("Unexpected city data: %+v", result)
+	}
+	if result.Latitude == nil || *result.Latitude != 37.4056 {
+		t.Errorf("Unexpected coordinates: %v", result.Latitude)
+	}
+	// the /24 is more specific than the Level3 /9
+	if result.ASNumber == nil || *result.ASNumber != 15169 || *result.ASOrganization != "GOOGLE" {
+		t.Errorf("Expected AS15169, got %+v", result)
+	}
+
+	result, _ = geo.Lookup(netip.MustParseAddr("8.8.4.4"))
+	if result.ASNumber == nil || *result.ASNumber != 3356 || result.CityName != nil {
+		t.Errorf("Expected AS3356 without city, got %+v", result)
+	}
+
+	result, _ = geo.Lookup(netip.MustParseAddr("::ffff:1.0.0.1"))
+	if result.CountryCode == nil || *result.CountryCode != "AU" {
+		t.Errorf("IPv4-mapped address not looked up as IPv4: %+v", result)
+	}
+
+	result, _ = geo.Lookup(netip.MustParseAddr("2001:4860:4860::8888"))
+	if result.CountryCode == nil || *result.CountryCode != "US" {
+		t.Errorf("IPv6 range not found: %+v", result)
+	}
+
+	for _, ip := range []string{"9.9.9.9", "127.0.0.1", "2a00::1"} {
+		result, _ = geo.Lookup(netip.MustParseAddr(ip))
+		if result.CountryCode != nil || result.ASNumber != nil {
+			t.Errorf("Expected no data for %s, got %+v", ip, result)
+		}
+	}
+}
+
+func TestLoadRangeGeo_Errors(t *testing.T) {
+	path := writeRangeFile(t, "ranges.csv", "1.0.0.0,1.0.0.255,AU\n")
+	for _, files := range [][]string{
+		{path},
+		{"unknown:" + path},
+		{"dbip-country:/non/existent/file.csv"},
+	} {
+		if _, err := util.LoadRangeGeo(files...); err == nil {
+			t.Errorf("LoadRangeGeo(%v) should fail", files)
+		}
+	}
+	if _, err := util.LoadRangeGeo("dbip-country:" + path); err != nil {
+		t.Errorf("LoadRangeGeo failed: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package util_test
 
 import (
 	"net/netip"
+	"sync"
 	"testing"
 
 	"github.com/200ug/peerlogger/internal/util"
@@ -252,6 +253,91 @@ func TestGeoIP_WithMockData(t *testing.T) {
 			} else {
 				t.Log("AS Number: <no data>")
 			}
+
+			if result.ASOrganization != nil {
+				t.Logf("AS Organization: %s", *result.ASOrganization)
+			}
+			if result.ContinentCode != nil {
+				t.Logf("Continent: %s", *result.ContinentCode)
+			}
+			if result.SubdivisionName != nil {
+				t.Logf("Subdivision: %s", *result.SubdivisionName)
+			}
+			if result.TimeZone != nil {
+				t.Logf("Time Zone: %s", *result.TimeZone)
+			}
+			if (result.Latitude == nil) != (result.Longitude == nil) {
+				t.Errorf("Latitude and longitude should be set together for %s", ipStr)
+			} else if result.Latitude != nil {
+				if *result.Latitude < -90 || *result.Latitude > 90 || *result.Longitude < -180 || *result.Longitude > 180 {
+					t.Errorf("Coordinates out of range for %s: %f, %f", ipStr, *result.Latitude, *result.Longitude)
+				}
+				t.Logf("Location: %f, %f", *result.Latitude, *result.Longitude)
+			}
+			if result.AccuracyRadius != nil {
+				t.Logf("Accuracy Radius: %d km", *result.AccuracyRadius)
+			}
 		})
 	}
 }
+
+func TestGeoIP_Cache(t *testing.T) {
+	geo, err := util.NewCachedGeoIP("", "", 16)
+	if err != nil {
+		t.Fatalf("Failed to create GeoIP: %v", err)
+	}
+	hits, misses := util.CacheStats()
+
+	ip := netip.MustParseAddr("192.0.2.1")
+	first, err := geo.Lookup(ip)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	// the IPv4-mapped form shares the cache entry
+	second, err := geo.Lookup(netip.MustParseAddr("::ffff:192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if first != second {
+		t.Error("Second lookup should be served from the cache")
+	}
+	h, m := util.CacheStats()
+	if h-hits != 1 || m-misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d and %d", h-hits, m-misses)
+	}
+	if n := geo.GetDatabaseInfo()["cache_entries"]; n != 1 {
+		t.Errorf("Expected 1 cache entry, got %v", n)
+	}
+
+	// closing (like reloading) drops the cached results
+	geo.Close()
+	if n := geo.GetDatabaseInfo()["cache_entries"]; n != 0 {
+		t.Errorf("Expected an empty cache after close, got %v", n)
+	}
+	third, _ := geo.Lookup(ip)
+	if third == first {
+		t.Error("Lookup after close should not be served from the cache")
+	}
+}
+
+func TestGeoIP_ConcurrentLookup(t *testing.T) {
+	geo, err := util.NewCachedGeoIP("", "", 4)
+	if err != nil {
+		t.Fatalf("Failed to create GeoIP: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				ip := netip.AddrFrom4([4]byte{192, 0, 2, byte(i*100 + j)})
+				if _, err := geo.Lookup(ip); err != nil {
+					t.Errorf("Lookup failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
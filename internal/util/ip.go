@@ -0,0 +1,42 @@
+package util
+
+import "net/netip"
+
+// bogonPrefixes are reserved or documentation ranges that must never appear
+// as the address of a public node.
+var bogonPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("3fff::/20"),
+}
+
+// IsPrivateIP reports whether ip is a private, loopback or link-local address.
+func IsPrivateIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast()
+}
+
+// IsBogonIP reports whether ip is not routable on the public internet, which
+// includes private addresses as well as reserved and documentation ranges.
+func IsBogonIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsMulticast() || IsPrivateIP(ip) {
+		return true
+	}
+	for _, prefix := range bogonPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
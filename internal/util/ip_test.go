@@ -0,0 +1,41 @@
+package util_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func TestIsPrivateAndBogonIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+		bogon   bool
+	}{
+		{"8.8.8.8", false, false},
+		{"2001:4860:4860::8888", false, false},
+		{"192.168.1.1", true, true},
+		{"10.0.0.1", true, true},
+		{"127.0.0.1", true, true},
+		{"fd00::1", true, true},
+		{"fe80::1", true, true},
+		{"::ffff:192.168.1.1", true, true},
+		{"100.64.0.1", false, true},
+		{"192.0.2.1", false, true},
+		{"0.0.0.0", false, true},
+		{"2001:db8::1", false, true},
+		{"255.255.255.255", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := netip.MustParseAddr(tt.ip)
+			if got := util.IsPrivateIP(ip); got != tt.private {
+				t.Errorf("IsPrivateIP(%s) = %v, expected %v", tt.ip, got, tt.private)
+			}
+			if got := util.IsBogonIP(ip); got != tt.bogon {
+				t.Errorf("IsBogonIP(%s) = %v, expected %v", tt.ip, got, tt.bogon)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// ParseNodeID resolves the node ID from any of the common node key notations:
+// enode URLs, hex encoded compressed (33 bytes) or uncompressed (64 or 65
+// bytes) secp256k1 public keys and hex encoded node IDs (32 bytes).
+func ParseNodeID(s string) (enode.ID, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "enode://") {
+		n, err := enode.ParseV4(s)
+		if err != nil {
+			return enode.ID{}, false
+		}
+		return n.ID(), true
+	}
+
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil {
+		return enode.ID{}, false
+	}
+	switch len(b) {
+	case 32:
+		return enode.ID(b), true
+	case 33:
+		pub, err := crypto.DecompressPubkey(b)
+		if err != nil {
+			return enode.ID{}, false
+		}
+		return enode.PubkeyToIDV4(pub), true
+	case 64, 65:
+		if len(b) == 64 {
+			b = append([]byte{0x04}, b...)
+		}
+		pub, err := crypto.UnmarshalPubkey(b)
+		if err != nil {
+			return enode.ID{}, false
+		}
+		return enode.PubkeyToIDV4(pub), true
+	}
+	return enode.ID{}, false
+}
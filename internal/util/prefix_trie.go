@@ -0,0 +1,80 @@
+package util
+
+import "net/netip"
+
+// prefixTrie is a binary trie of IPv4 and IPv6 prefixes. Lookups walk at most
+// one node per address bit, so they don't depend on the number of prefixes.
+type prefixTrie struct {
+	v4, v6 trieNode
+	len    int
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// terminal marks the end of an inserted prefix
+	terminal bool
+}
+
+// insert adds a prefix, IPv4-mapped prefixes are stored as IPv4. It returns
+// false if the prefix was already present.
+func (t *prefixTrie) insert(p netip.Prefix) bool {
+	addr, bits := p.Addr(), p.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	n := t.root(addr)
+	b := addr.As16()
+	offset := 0
+	if addr.Is4() {
+		offset = 96
+	}
+	for i := 0; i < bits; i++ {
+		bit := bitAt(b, offset+i)
+		if n.children[bit] == nil {
+			n.children[bit] = &trieNode{}
+		}
+		n = n.children[bit]
+	}
+	if n.terminal {
+		return false
+	}
+	n.terminal = true
+	t.len++
+	return true
+}
+
+// contains reports whether any prefix contains the address.
+func (t *prefixTrie) contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	n := t.root(addr)
+	b := addr.As16()
+	offset, bits := 0, 128
+	if addr.Is4() {
+		offset, bits = 96, 32
+	}
+	for i := 0; ; i++ {
+		if n.terminal {
+			return true
+		}
+		if i == bits {
+			return false
+		}
+		if n = n.children[bitAt(b, offset+i)]; n == nil {
+			return false
+		}
+	}
+}
+
+func (t *prefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+func bitAt(b [16]byte, i int) int {
+	return int(b[i/8]>>(7-i%8)) & 1
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IP privacy modes, deciding what is stored of a peer IP.
+const (
+	// PrivacyFull stores the address as is.
+	PrivacyFull = "full"
+	// PrivacyTruncated stores the enclosing prefix, e.g. the /24 or /48.
+	PrivacyTruncated = "truncated"
+	// PrivacyHMAC stores a keyed hash, so addresses can still be correlated
+	// within the dataset but not recovered without the key.
+	PrivacyHMAC = "hmac"
+	// PrivacyNone stores nothing.
+	PrivacyNone = "none"
+)
+
+// IPPrivacy anonymises IPs before they are written. A nil IPPrivacy stores
+// addresses as is.
+type IPPrivacy struct {
+	Mode  string
+	Bits4 int // prefix length kept of IPv4 addresses in truncated mode
+	Bits6 int // prefix length kept of IPv6 addresses in truncated mode
+	key   []byte
+}
+
+// NewIPPrivacy validates the mode. The key is required for the hmac mode.
+func NewIPPrivacy(mode, key string, bits4, bits6 int) (*IPPrivacy, error) {
+	p := &IPPrivacy{Mode: strings.ToLower(strings.TrimSpace(mode)), Bits4: bits4, Bits6: bits6}
+	switch p.Mode {
+	case PrivacyFull, PrivacyNone:
+	case PrivacyTruncated:
+		if bits4 < 0 || bits4 > 32 || bits6 < 0 || bits6 > 128 {
+			return nil, fmt.Errorf("invalid truncation prefix lengths /%d and /%d", bits4, bits6)
+		}
+	case PrivacyHMAC:
+		if key == "" {
+			return nil, errors.New("hmac privacy mode requires a key")
+		}
+		p.key = []byte(key)
+	default:
+		return nil, fmt.Errorf("unknown IP privacy mode %q", mode)
+	}
+	return p, nil
+}
+
+// Stores reports whether the mode stores the address or a prefix of it.
+func (p *IPPrivacy) Stores() bool {
+	return p == nil || p.Mode == PrivacyFull || p.Mode == PrivacyTruncated
+}
+
+// StoresFull reports whether addresses are stored as is.
+func (p *IPPrivacy) StoresFull() bool {
+	return p == nil || p.Mode == PrivacyFull
+}
+
+// Address returns what is stored in place of ip: the address, its prefix in
+// CIDR notation, or "" if the mode doesn't store addresses.
+func (p *IPPrivacy) Address(ip netip.Addr) string {
+	if !ip.IsValid() {
+		return ""
+	}
+	ip = ip.Unmap()
+	switch {
+	case p == nil || p.Mode == PrivacyFull:
+		return ip.String()
+	case p.Mode == PrivacyTruncated:
+		bits := p.Bits6
+		if ip.Is4() {
+			bits = p.Bits4
+		}
+		prefix, _ := ip.Prefix(bits)
+		return prefix.String()
+	default:
+		return ""
+	}
+}
+
+// Hash returns the hex HMAC-SHA256 of ip in the hmac mode, "" otherwise.
+func (p *IPPrivacy) Hash(ip netip.Addr) string {
+	if p == nil || p.Mode != PrivacyHMAC || !ip.IsValid() {
+		return ""
+	}
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(ip.Unmap().AsSlice())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Value is Address in the modes storing addresses, else Hash. It is used
+// where a single text value represents the IP, e.g. in ENR change events.
+func (p *IPPrivacy) Value(ip netip.Addr) string {
+	if p.Stores() {
+		return p.Address(ip)
+	}
+	return p.Hash(ip)
+}
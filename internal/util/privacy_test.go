@@ -0,0 +1,74 @@
+package util_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func TestIPPrivacy(t *testing.T) {
+	v4, v6 := netip.MustParseAddr("88.99.10.20"), netip.MustParseAddr("2a01:4f8:10a:1::2")
+	mapped := netip.MustParseAddr("::ffff:88.99.10.20")
+
+	full, _ := util.NewIPPrivacy("full", "", 24, 48)
+	truncated, _ := util.NewIPPrivacy("truncated", "", 24, 48)
+	hmacMode, _ := util.NewIPPrivacy("hmac", "secret", 24, 48)
+	none, _ := util.NewIPPrivacy("none", "", 24, 48)
+	var unset *util.IPPrivacy
+
+	tests := []struct {
+		name    string
+		privacy *util.IPPrivacy
+		ip      netip.Addr
+		addr    string
+		hashed  bool
+	}{
+		{"nil", unset, v4, "88.99.10.20", false},
+		{"full", full, v6, "2a01:4f8:10a:1::2", false},
+		{"truncated v4", truncated, v4, "88.99.10.0/24", false},
+		{"truncated mapped", truncated, mapped, "88.99.10.0/24", false},
+		{"truncated v6", truncated, v6, "2a01:4f8:10a::/48", false},
+		{"hmac", hmacMode, v4, "", true},
+		{"none", none, v4, "", false},
+		{"invalid", truncated, netip.Addr{}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.privacy.Address(tt.ip); got != tt.addr {
+				t.Errorf("Address(%s) = %q, expected %q", tt.ip, got, tt.addr)
+			}
+			if got := tt.privacy.Hash(tt.ip); (got != "") != tt.hashed {
+				t.Errorf("Hash(%s) = %q, expected hashed %v", tt.ip, got, tt.hashed)
+			}
+		})
+	}
+
+	// the hash is keyed and stable for mapped addresses
+	if hmacMode.Hash(v4) != hmacMode.Hash(mapped) || len(hmacMode.Hash(v4)) != 64 {
+		t.Errorf("Unexpected hash %q", hmacMode.Hash(v4))
+	}
+	otherKey, _ := util.NewIPPrivacy("hmac", "other", 24, 48)
+	if otherKey.Hash(v4) == hmacMode.Hash(v4) {
+		t.Error("Hashes with different keys should differ")
+	}
+	if hmacMode.Value(v4) != hmacMode.Hash(v4) || truncated.Value(v4) != "88.99.10.0/24" {
+		t.Error("Value should be the hash in hmac mode and the address otherwise")
+	}
+}
+
+func TestNewIPPrivacyErrors(t *testing.T) {
+	for _, tt := range []struct {
+		mode, key    string
+		bits4, bits6 int
+	}{
+		{"hmac", "", 24, 48},
+		{"truncated", "", 33, 48},
+		{"truncated", "", 24, 129},
+		{"redacted", "", 24, 48},
+	} {
+		if _, err := util.NewIPPrivacy(tt.mode, tt.key, tt.bits4, tt.bits6); err == nil {
+			t.Errorf("Expected an error for %+v", tt)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rs/zerolog/log"
+)
+
+// Rules are the exclude and include rules of the blacklist files, given next
+// to the ip_blacklists and pubkey_blacklists keys:
+//
+//	{
+//	  "ip_blacklists": ["203.0.113.0/24"],
+//	  "exclude_rules": [
+//	    {"name": "partner honeypots", "asns": [64496], "enr_keys": ["hp"]},
+//	    {"client": "^erigon/v2\\."}
+//	  ],
+//	  "include_rules": [{"countries": ["DE", "FR"]}]
+//	}
+//
+// Nodes matching any exclude rule are not dialed. If there are include rules,
+// only nodes matching at least one of them are dialed.
+type Rules struct {
+	Exclude []*Rule `json:"exclude_rules,omitempty"`
+	Include []*Rule `json:"include_rules,omitempty"`
+}
+
+// Rule matches nodes having all of the given properties. Within a list any
+// value matches. The client name and network ID are only known for nodes
+// dialed in an earlier round, the ASN and country only with GeoIP data.
+// Unknown properties never match exclude rules and always match include
+// rules, so such nodes are dialed until they are known.
+type Rule struct {
+	Name      string   `json:"name,omitempty"`
+	ASNs      []int64  `json:"asns,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	// Client is a regular expression matched against the client name, e.g.
+	// "Geth/v1.16.2-stable/linux-amd64/go1.24.5".
+	Client     string   `json:"client,omitempty"`
+	NetworkIDs []uint64 `json:"network_ids,omitempty"`
+	// ENRKeys must all be present in the node record.
+	ENRKeys []string `json:"enr_keys,omitempty"`
+
+	client *regexp.Regexp
+}
+
+// NodeAttrs are the properties of a node the blacklist is checked against.
+// Zero values are unknown.
+type NodeAttrs struct {
+	// Node is the record the ENR keys are looked up in. ID is checked
+	// against the blacklisted node keys if Node is nil, e.g. before the
+	// record is requested.
+	Node      *enode.Node
+	ID        enode.ID
+	IPs       []netip.Addr
+	ASN       int64
+	Country   string
+	Client    string
+	NetworkID uint64
+}
+
+// LoadRules reads the rules of the blacklist files. Files without rules are
+// fine, unlike invalid rules.
+func LoadRules(paths ...string) (Rules, error) {
+	var rules Rules
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Rules{}, err
+		}
+		var r Rules
+		if err := json.Unmarshal(data, &r); err != nil {
+			return Rules{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := r.compile(); err != nil {
+			return Rules{}, fmt.Errorf("%s: %w", path, err)
+		}
+		rules.Exclude = append(rules.Exclude, r.Exclude...)
+		rules.Include = append(rules.Include, r.Include...)
+	}
+	return rules, nil
+}
+
+func (r Rules) compile() error {
+	for i, rule := range slices.Concat(r.Exclude, r.Include) {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if len(rule.ASNs) == 0 && len(rule.Countries) == 0 && rule.Client == "" &&
+			len(rule.NetworkIDs) == 0 && len(rule.ENRKeys) == 0 {
+			return fmt.Errorf("rule %s: no criteria", rule.Name)
+		}
+		if rule.Client != "" {
+			re, err := regexp.Compile(rule.Client)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			rule.client = re
+		}
+	}
+	return nil
+}
+
+// SetRules replaces the exclude and include rules.
+func (b *Blacklist) SetRules(rules Rules) error {
+	if err := rules.compile(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules = rules
+
+	log.Info().Int("exclude", len(rules.Exclude)).Int("include", len(rules.Include)).Msg("Blacklist rules loaded")
+	return nil
+}
+
+// Check reports whether a node may be dialed, and why not.
+func (b *Blacklist) Check(a NodeAttrs) (bool, string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ip := range a.IPs {
+		if ip.IsValid() && b.isIPBlacklisted(ip) {
+			return false, "ip blacklisted"
+		}
+	}
+	id := a.ID
+	if a.Node != nil {
+		id = a.Node.ID()
+	}
+	if b.nodeIDs[id] {
+		return false, "node blacklisted"
+	}
+	return b.checkRules(&a)
+}
+
+// CheckRules is Check without the IP and node key lists, for nodes whose
+// endpoint was checked before.
+func (b *Blacklist) CheckRules(a NodeAttrs) (bool, string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.checkRules(&a)
+}
+
+func (b *Blacklist) checkRules(a *NodeAttrs) (bool, string) {
+	for _, rule := range b.rules.Exclude {
+		if rule.match(a, false) {
+			return false, "exclude rule " + rule.Name
+		}
+	}
+	if len(b.rules.Include) == 0 {
+		return true, ""
+	}
+	for _, rule := range b.rules.Include {
+		if rule.match(a, true) {
+			return true, ""
+		}
+	}
+	return false, "no include rule matched"
+}
+
+// match reports whether the node has all properties of the rule, unknown
+// properties match if unknownMatches is set.
+func (r *Rule) match(a *NodeAttrs, unknownMatches bool) bool {
+	check := func(set, known bool, matches func() bool) bool {
+		if !set {
+			return true
+		}
+		if !known {
+			return unknownMatches
+		}
+		return matches()
+	}
+	return check(len(r.ASNs) > 0, a.ASN != 0, func() bool {
+		return slices.Contains(r.ASNs, a.ASN)
+	}) && check(len(r.Countries) > 0, a.Country != "", func() bool {
+		return slices.ContainsFunc(r.Countries, func(c string) bool { return strings.EqualFold(c, a.Country) })
+	}) && check(r.client != nil, a.Client != "", func() bool {
+		return r.client.MatchString(a.Client)
+	}) && check(len(r.NetworkIDs) > 0, a.NetworkID != 0, func() bool {
+		return slices.Contains(r.NetworkIDs, a.NetworkID)
+	}) && check(len(r.ENRKeys) > 0, a.Node != nil, func() bool {
+		return hasENRKeys(a.Node, r.ENRKeys)
+	})
+}
+
+func hasENRKeys(n *enode.Node, keys []string) bool {
+	for _, key := range keys {
+		var raw rlp.RawValue
+		if err := n.Load(enr.WithEntry(key, &raw)); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package util_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+
+	"github.com/200ug/peerlogger/internal/util"
+)
+
+func signedNode(t *testing.T, entries ...enr.Entry) *enode.Node {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IPv4{192, 0, 2, 1})
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("Signing record failed: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("Creating node failed: %v", err)
+	}
+	return n
+}
+
+func TestBlacklist_Rules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ip-blacklist.json")
+	os.WriteFile(path, []byte(`{
+		"ip_blacklists": ["203.0.113.0/24"],
+		"exclude_rules": [
+			{"name": "honeypots", "asns": [64496], "enr_keys": ["hp"]},
+			{"client": "^erigon/v2\\."},
+			{"network_ids": [11155111]}
+		],
+		"include_rules": [{"countries": ["de", "FR"]}]
+	}`), 0o644)
+
+	rules, err := util.LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+	bl := util.NewBlacklist(util.LoadJSONList(path, "ip_blacklists"), nil)
+	if err := bl.SetRules(rules); err != nil {
+		t.Fatalf("SetRules failed: %v", err)
+	}
+
+	plain := signedNode(t)
+	honeypot := signedNode(t, enr.WithEntry("hp", uint(1)))
+	tests := []struct {
+		name   string
+		attrs  util.NodeAttrs
+		reason string
+	}{
+		{"included country", util.NodeAttrs{Node: plain, Country: "DE"}, ""},
+		{"unknown country", util.NodeAttrs{Node: plain}, ""},
+		{"other country", util.NodeAttrs{Node: plain, Country: "US"}, "no include rule matched"},
+		{"blacklisted ip", util.NodeAttrs{Node: plain, IPs: []netip.Addr{netip.MustParseAddr("203.0.113.7")}}, "ip blacklisted"},
+		{"honeypot", util.NodeAttrs{Node: honeypot, ASN: 64496, Country: "FR"}, "exclude rule honeypots"},
+		{"honeypot key elsewhere", util.NodeAttrs{Node: honeypot, ASN: 64497, Country: "FR"}, ""},
+		{"honeypot asn unknown", util.NodeAttrs{Node: honeypot, Country: "FR"}, ""},
+		{"client", util.NodeAttrs{Node: plain, Client: "erigon/v2.60.10-125509e4/linux-amd64/go1.21.13"}, "exclude rule #2"},
+		{"other client", util.NodeAttrs{Node: plain, Client: "Geth/v1.16.2-stable/linux-amd64/go1.24.5"}, ""},
+		{"network", util.NodeAttrs{Node: plain, NetworkID: 11155111}, "exclude rule #3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := bl.Check(tt.attrs)
+			if ok != (tt.reason == "") || reason != tt.reason {
+				t.Errorf("Check() = %v, %q, expected reason %q", ok, reason, tt.reason)
+			}
+		})
+	}
+
+	// without include rules every node not excluded is allowed
+	bl.SetRules(util.Rules{})
+	if ok, _ := bl.Check(util.NodeAttrs{Node: plain, Country: "US"}); !ok {
+		t.Error("Node should be allowed without rules")
+	}
+	bl.Reload(nil, []string{plain.ID().String()})
+	if ok, reason := bl.Check(util.NodeAttrs{Node: plain}); ok || reason != "node blacklisted" {
+		t.Errorf("Blacklisted node should be rejected, got %v, %q", ok, reason)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty-rule.json": `{"exclude_rules": [{"name": "everything"}]}`,
+		"bad-regex.json":  `{"include_rules": [{"client": "geth("}]}`,
+		"invalid.json":    `{"exclude_rules": {}}`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := util.LoadRules(path); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+
+	// files with only blacklists have no rules
+	path := filepath.Join(dir, "pubkeys.json")
+	os.WriteFile(path, []byte(`{"pubkey_blacklists": ["abc"]}`), 0o644)
+	rules, err := util.LoadRules(path)
+	if err != nil || len(rules.Exclude)+len(rules.Include) != 0 {
+		t.Errorf("Expected no rules, got %+v, %v", rules, err)
+	}
+}
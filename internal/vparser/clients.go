@@ -0,0 +1,119 @@
+package vparser
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ClientParser parses a client name split at '/' and lowercased. The first
+// segment is the client name the parser was registered for.
+type ClientParser func(segments []string) (ParsedInfo, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]ClientParser{
+		// execution layer
+		"geth":         parseGoClient,
+		"bor":          parseGoClient,
+		"coregeth":     parseGoClient,
+		"erigon":       parseGoClient,
+		"nethermind":   parseNethermind,
+		"besu":         parseJavaClient,
+		"reth":         parseRustClient,
+		"openethereum": parseGeneric,
+		// consensus layer (libp2p agent versions)
+		"lighthouse": parseRustClient,
+		"grandine":   parseRustClient,
+		"teku":       parseJavaClient,
+		"prysm":      parseCommitClient("go"),
+		"lodestar":   parseCommitClient("typescript"),
+	}
+)
+
+// Register adds or replaces the parser used for the given client name.
+func Register(name string, parser ClientParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[strings.ToLower(name)] = parser
+}
+
+func lookupParser(name string) (ClientParser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	parser, ok := parsers[name]
+	return parser, ok
+}
+
+// exact upgrades the result of the generic parser to high confidence if the
+// string had the expected number of segments.
+func exact(info ParsedInfo, err error, ok bool) (ParsedInfo, error) {
+	if err == nil && ok {
+		info.Confidence = ConfidenceHigh
+	}
+	return info, err
+}
+
+// parseGoClient parses Geth/v1.16.2-stable-a1b2c3d4/linux-amd64/go1.24.5,
+// optionally with a label after the name.
+func parseGoClient(segments []string) (ParsedInfo, error) {
+	info, err := parseGeneric(segments)
+	vi := versionIndex(segments)
+	return exact(info, err, vi > 0 && len(segments)-vi == 3 && info.Language.Name == "go")
+}
+
+// parseNethermind parses Nethermind/v1.32.4+1c4c7c0a/linux-x64/dotnet9.0.7
+// and the older Nethermind/v1.14.0-0-7b6fbc5d4-20220810/X64-Linux/6.0.7.
+func parseNethermind(segments []string) (ParsedInfo, error) {
+	info, err := parseGeneric(segments)
+	if err == nil && info.Language.Name == "" && info.Language.Version != "" {
+		info.Language.Name = "dotnet"
+	}
+	return exact(info, err, len(segments) == 4)
+}
+
+var reJava = regexp.MustCompile(`java-?(\d[\d.]*)`)
+
+// parseJavaClient parses besu/v25.7.0/linux-x86_64/openjdk-java-21 and
+// teku/v25.7.1/linux-x86_64/-eclipseadoptium-openjdk64bitservervm-java-21.
+func parseJavaClient(segments []string) (ParsedInfo, error) {
+	info, err := parseGeneric(segments)
+	if err != nil || len(segments) != 4 {
+		return info, err
+	}
+	if match := reJava.FindStringSubmatch(segments[3]); match != nil {
+		info.Language = LanguageInfo{Name: "java", Version: match[1]}
+		info.Confidence = ConfidenceHigh
+	}
+	return info, nil
+}
+
+// parseRustClient parses reth/v1.6.0-d8451e5/x86_64-unknown-linux-gnu and
+// Lighthouse/v7.1.0-e42406d/x86_64-linux, where the last segment is the
+// target triple.
+func parseRustClient(segments []string) (ParsedInfo, error) {
+	info, err := parseGeneric(segments)
+	if err != nil || len(segments) != 3 {
+		return info, err
+	}
+	info.Os = parseOS(segments[2])
+	info.Language = LanguageInfo{Name: "rust"}
+	info.Confidence = ConfidenceHigh
+	return info, nil
+}
+
+// parseCommitClient parses Prysm/v6.0.4/3d9d5d8d3c and similar strings where
+// the commit follows the version.
+func parseCommitClient(language string) ClientParser {
+	return func(segments []string) (ParsedInfo, error) {
+		info, err := parseGeneric(segments)
+		if err != nil || len(segments) != 3 || !reHex.MatchString(segments[2]) {
+			return info, err
+		}
+		info.Version.Build = segments[2]
+		info.Os = OSInfo{}
+		info.Language = LanguageInfo{Name: language}
+		info.Confidence = ConfidenceHigh
+		return info, nil
+	}
+}
@@ -1,6 +1,8 @@
 package vparser
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -14,7 +16,6 @@ type Version struct {
 	Tag   string
 	Build string
 	Date  string
-	Error bool
 }
 
 type OSInfo struct {
@@ -28,60 +29,135 @@ type LanguageInfo struct {
 }
 
 type ParsedInfo struct {
-	Name     string
-	Label    string
-	Version  Version
-	Os       OSInfo
-	Language LanguageInfo
+	Name       string
+	Label      string
+	Version    Version
+	Os         OSInfo
+	Language   LanguageInfo
+	Confidence Confidence
 }
 
-var reLanguage = regexp.MustCompile(`(?P<name>[a-zA-Z]+)?-?(?P<version>[\d+.?]+)`)
+// Confidence tells how well a client name matched a known format.
+type Confidence int
+
+const (
+	// ConfidenceNone is returned together with an error.
+	ConfidenceNone Confidence = iota
+	// ConfidenceLow means only the name and possibly the version were recognised.
+	ConfidenceLow
+	// ConfidenceMedium means the generic name/version/os/language layout matched.
+	ConfidenceMedium
+	// ConfidenceHigh means a client specific parser matched the whole string.
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return "none"
+	}
+}
+
+var (
+	ErrEmpty          = errors.New("empty client name")
+	ErrEnode          = errors.New("client name contains an enode URL")
+	ErrUnknownFormat  = errors.New("unknown client name format")
+	ErrInvalidVersion = errors.New("invalid version number")
+)
+
+var (
+	reLanguage = regexp.MustCompile(`(?P<name>[a-zA-Z]+)?-?(?P<version>[\d+.?]+)`)
+	reVersion  = regexp.MustCompile(`^v?\d+(\.\d+)*([-+].*)?$`)
+	reHex      = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+	reDate     = regexp.MustCompile(`^\d{8}$`)
+)
+
+// Semver returns the version number as major.minor.patch.
+func (v Version) Semver() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
 
 func (p *ParsedInfo) String() string {
 	return fmt.Sprintf("%v (%v) %v %v", p.Name, p.Version, p.Os, p.Language)
 }
 
-func ParseVersionString(input string) *ParsedInfo {
-	var output ParsedInfo
-
-	// Quick fix to filter out enodes.
+// ParseVersionString parses a devp2p Hello name or libp2p agent version. The
+// client specific parser registered for the name is used if there is one,
+// otherwise the generic name[/label]/version/os/language layout is assumed.
+func ParseVersionString(input string) (ParsedInfo, error) {
+	if strings.TrimSpace(input) == "" {
+		return ParsedInfo{}, ErrEmpty
+	}
 	if strings.Contains(input, "enode://") {
-		return nil
+		return ParsedInfo{}, ErrEnode
+	}
+
+	segments := strings.Split(strings.ToLower(strings.TrimSpace(input)), "/")
+	if segments[0] == "" {
+		return ParsedInfo{}, ErrUnknownFormat
+	}
+	if parser, ok := lookupParser(segments[0]); ok {
+		return parser(segments)
+	}
+	return parseGeneric(segments)
+}
+
+// parseGeneric handles name[/label...]/version[/os][/language] strings.
+func parseGeneric(segments []string) (ParsedInfo, error) {
+	output := ParsedInfo{Name: segments[0], Confidence: ConfidenceLow}
+	if len(segments) == 1 {
+		return output, nil
 	}
 
-	// If there exists more than one version, don't count it
-	if strings.Count(input, "/v") > 1 {
-		return nil
+	vi := versionIndex(segments)
+	if vi < 0 {
+		return ParsedInfo{}, fmt.Errorf("%w: no version in %q", ErrUnknownFormat, strings.Join(segments, "/"))
 	}
+	output.Label = strings.Join(segments[1:vi], "/")
 
-	// version string consists of four components, divided by /
-	s := strings.Split(strings.ToLower(input), "/")
-	l := len(s)
-	output.Name = strings.ToLower(s[0])
-	if output.Name == "" {
-		return nil
+	version, err := parseVersion(segments[vi])
+	if err != nil {
+		return ParsedInfo{}, err
 	}
+	output.Version = version
 
-	if l == 5 || l == 7 {
-		output.Label = s[1]
-		output.Version = parseVersion(s[2])
-		output.Os = parseOS(s[3])
-		output.Language = parseLanguage(s[4])
-	} else if l == 4 {
-		output.Version = parseVersion(s[1])
-		output.Os = parseOS(s[2])
-		output.Language = parseLanguage(s[3])
-	} else if (l == 1 || l == 0) && (output.Name == "tmp" || output.Name == "eth2") {
-		// These are usually "tmp" nodes that cannot be parsed.
-	} else {
-		fmt.Printf("Parser Error: Invalid length of '%d' for input: '%s'\n", l, input)
+	rest := segments[vi+1:]
+	switch len(rest) {
+	case 0:
+	case 1:
+		if lang := parseLanguage(rest[0]); lang.Name != "" {
+			output.Language = lang
+		} else {
+			output.Os = parseOS(rest[0])
+		}
+		output.Confidence = ConfidenceMedium
+	case 2:
+		output.Os = parseOS(rest[0])
+		output.Language = parseLanguage(rest[1])
+		output.Confidence = ConfidenceMedium
+	default:
+		// Wrapped clients (e.g. Q-Client/v1/Geth/v1/...) repeat name and version.
+		output.Os = parseOS(rest[len(rest)-2])
+		output.Language = parseLanguage(rest[len(rest)-1])
 	}
+	return output, nil
+}
 
-	if output.Version.Error {
-		fmt.Printf(" -> Error Parsing: '%s', %v\n", input, output)
-		return nil
+// versionIndex returns the index of the first segment after the name which
+// looks like a version number.
+func versionIndex(segments []string) int {
+	for i := 1; i < len(segments); i++ {
+		if reVersion.MatchString(segments[i]) {
+			return i
+		}
 	}
-	return &output
+	return -1
 }
 
 func parseLanguage(input string) LanguageInfo {
@@ -96,72 +172,98 @@ func parseLanguage(input string) LanguageInfo {
 	return languageInfo
 }
 
-func parseVersion(input string) Version {
+// parseVersion parses v<major>.<minor>.<patch>[-tag...][-build][-date][+build].
+// Hex commit hashes and dates are recognised wherever they appear.
+func parseVersion(input string) (Version, error) {
 	var vers Version
+	input, build, _ := strings.Cut(input, "+")
+	vers.Build = build
+
 	split := strings.Split(input, "-")
-	split_length := len(split)
-	switch len(split) {
-	case 8:
-		fallthrough
-	case 7:
-		fallthrough
-	case 6:
-		fallthrough
-	case 5:
-		vers.Date = split[split_length-1]
-		vers.Build = split[split_length-2]
-		vers.Tag = strings.Join(split[1:split_length-3], "")
-		vers.Major, vers.Minor, vers.Patch = parseVersionNumber(split[0])
-	case 4:
-		// Date
-		vers.Date = split[3]
-		fallthrough
-	case 3:
-		// Build
-		vers.Build = split[2]
-		fallthrough
-	case 2:
-		// Tag
-		vers.Tag = split[1]
-		fallthrough
-	case 1:
-		// Version
-		vers.Major, vers.Minor, vers.Patch = parseVersionNumber(split[0])
+	var err error
+	vers.Major, vers.Minor, vers.Patch, err = parseVersionNumber(split[0])
+	if err != nil {
+		return Version{}, err
 	}
 
-	if vers.Major == 0 && vers.Minor == 0 && vers.Patch == 0 {
-		fmt.Println("Version string is invalid:", input)
-		vers.Error = true
+	var tags []string
+	for _, part := range split[1:] {
+		switch {
+		case part == "":
+		case reDate.MatchString(part):
+			vers.Date = part
+		case reHex.MatchString(part) && vers.Build == "":
+			vers.Build = part
+		default:
+			tags = append(tags, part)
+		}
 	}
-
-	return vers
+	vers.Tag = strings.Join(tags, "")
+	return vers, nil
 }
 
-func parseVersionNumber(input string) (int, int, int) {
+func parseVersionNumber(input string) (int, int, int, error) {
 	// Version
 	trimmed := strings.TrimLeft(input, "v")
 	vSplit := strings.Split(trimmed, ".")
 	var major, minor, patch int
+	var err error
 
 	switch len(vSplit) {
 	case 4:
 		fallthrough
 	case 3:
-		patch, _ = strconv.Atoi(vSplit[2])
+		if patch, err = strconv.Atoi(vSplit[2]); err != nil {
+			break
+		}
 		fallthrough
 	case 2:
-		minor, _ = strconv.Atoi(vSplit[1])
+		if minor, err = strconv.Atoi(vSplit[1]); err != nil {
+			break
+		}
 		fallthrough
 	case 1:
-		major, _ = strconv.Atoi(vSplit[0])
+		major, err = strconv.Atoi(vSplit[0])
+	default:
+		err = ErrInvalidVersion
 	}
 
-	return major, minor, patch
+	if err != nil || major == 0 && minor == 0 && patch == 0 {
+		return 0, 0, 0, fmt.Errorf("%w: %q", ErrInvalidVersion, input)
+	}
+	return major, minor, patch, nil
 }
 
+var (
+	knownOS = map[string]bool{
+		"linux": true, "windows": true, "darwin": true, "macos": true, "osx": true,
+		"freebsd": true, "openbsd": true, "netbsd": true, "android": true, "ios": true,
+	}
+	knownArch = map[string]bool{
+		"amd64": true, "x86_64": true, "x64": true, "arm64": true, "aarch64": true,
+		"386": true, "i686": true, "x86": true, "arm": true, "armv7": true,
+		"riscv64": true, "ppc64le": true, "s390x": true,
+	}
+)
+
+// parseOS parses os-arch pairs as well as arch-os (Nethermind) and target
+// triples like x86_64-unknown-linux-gnu (Rust clients).
 func parseOS(input string) OSInfo {
+	input = strings.ReplaceAll(input, "x86-64", "x86_64")
 	split := strings.Split(input, "-")
 	var osInfo OSInfo
+	for _, part := range split {
+		switch {
+		case knownOS[part] && osInfo.Os == "":
+			osInfo.Os = part
+		case knownArch[part] && osInfo.Architecture == "":
+			osInfo.Architecture = part
+		}
+	}
+	if osInfo.Os != "" || osInfo.Architecture != "" {
+		return osInfo
+	}
+
 	switch len(split) {
 	case 2:
 		osInfo.Architecture = split[1]
@@ -171,3 +273,84 @@ func parseOS(input string) OSInfo {
 	}
 	return osInfo
 }
+
+// ParseVersion parses a bare version number such as v1.16.2 or 25.7.0-rc1,
+// e.g. from a release manifest.
+func ParseVersion(input string) (Version, error) {
+	return parseVersion(strings.ToLower(strings.TrimSpace(input)))
+}
+
+// prereleaseTags are tag fragments that mark builds before a release.
+var prereleaseTags = []string{"alpha", "beta", "rc", "unstable", "dev", "pre", "snapshot", "nightly"}
+
+// Prerelease reports whether the version tag marks a pre-release build.
+func (v Version) Prerelease() bool {
+	for _, tag := range prereleaseTags {
+		if strings.Contains(v.Tag, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older than, equal to
+// or newer than o. Versions are ordered by major, minor and patch number, a
+// pre-release sorts before the release and pre-releases are ordered by tag,
+// see compareTags. Build and date are ignored.
+func (v Version) Compare(o Version) int {
+	for _, d := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if d[0] != d[1] {
+			return cmp.Compare(d[0], d[1])
+		}
+	}
+	switch vp, op := v.Prerelease(), o.Prerelease(); {
+	case vp && !op:
+		return -1
+	case !vp && op:
+		return 1
+	case vp && op:
+		return compareTags(v.Tag, o.Tag)
+	}
+	return 0
+}
+
+// compareTags orders pre-release tags by their alphabetic and numeric parts
+// in turn, comparing numbers numerically so that rc2 sorts before rc10.
+func compareTags(a, b string) int {
+	for a != "" && b != "" {
+		pa, pb := tagPart(a), tagPart(b)
+		a, b = a[len(pa):], b[len(pb):]
+		var c int
+		if isDigit(pa[0]) && isDigit(pb[0]) {
+			// compare without parsing, the numbers may overflow an int
+			pa, pb = strings.TrimLeft(pa, "0"), strings.TrimLeft(pb, "0")
+			c = cmp.Or(cmp.Compare(len(pa), len(pb)), strings.Compare(pa, pb))
+		} else {
+			c = strings.Compare(pa, pb)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// tagPart returns the leading run of digits or non-digits of a tag.
+func tagPart(s string) string {
+	digit := isDigit(s[0])
+	for i := 1; i < len(s); i++ {
+		if isDigit(s[i]) != digit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Less reports whether v is older than o.
+func (v Version) Less(o Version) bool {
+	return v.Compare(o) < 0
+}
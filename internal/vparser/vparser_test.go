@@ -1,7 +1,11 @@
 package vparser_test
 
 import (
+	"bufio"
+	"errors"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/200ug/peerlogger/internal/vparser"
@@ -11,21 +15,23 @@ func TestParseVersionString(t *testing.T) {
 	type ParseTestCase struct {
 		name string
 		args string
-		want *vparser.ParsedInfo
+		want vparser.ParsedInfo
+		err  error
 	}
 
 	var test_data = []ParseTestCase{
 		{
 			name: "single",
 			args: "geth",
-			want: &vparser.ParsedInfo{
-				Name: "geth",
+			want: vparser.ParsedInfo{
+				Name:       "geth",
+				Confidence: vparser.ConfidenceLow,
 			},
 		},
 		{
 			name: "perfect-case",
 			args: "Geth/v1.10.3-stable-991384a7/linux-amd64/go1.16.3",
-			want: &vparser.ParsedInfo{
+			want: vparser.ParsedInfo{
 				Name: "geth",
 				Version: vparser.Version{
 					Major: 1,
@@ -42,12 +48,13 @@ func TestParseVersionString(t *testing.T) {
 					Name:    "go",
 					Version: "1.16.3",
 				},
+				Confidence: vparser.ConfidenceHigh,
 			},
 		},
 		{
 			name: "without-build",
 			args: "Geth/v1.10.4-stable/linux-x64/go1.16.4",
-			want: &vparser.ParsedInfo{
+			want: vparser.ParsedInfo{
 				Name: "geth",
 				Version: vparser.Version{
 					Major: 1,
@@ -63,12 +70,13 @@ func TestParseVersionString(t *testing.T) {
 					Name:    "go",
 					Version: "1.16.4",
 				},
+				Confidence: vparser.ConfidenceHigh,
 			},
 		},
 		{
 			name: "java",
 			args: "besu/v21.7.0-RC1/darwin-x86_64/corretto-java-11",
-			want: &vparser.ParsedInfo{
+			want: vparser.ParsedInfo{
 				Name: "besu",
 				Version: vparser.Version{
 					Major: 21,
@@ -84,12 +92,13 @@ func TestParseVersionString(t *testing.T) {
 					Name:    "java",
 					Version: "11",
 				},
+				Confidence: vparser.ConfidenceHigh,
 			},
 		},
 		{
 			name: "windows",
 			args: "erigon/v2021.06.5-alpha-a0694dd3/windows-x86_64/go1.16.5",
-			want: &vparser.ParsedInfo{
+			want: vparser.ParsedInfo{
 				Name: "erigon",
 				Version: vparser.Version{
 					Major: 2021,
@@ -106,12 +115,13 @@ func TestParseVersionString(t *testing.T) {
 					Name:    "go",
 					Version: "1.16.5",
 				},
+				Confidence: vparser.ConfidenceHigh,
 			},
 		},
 		{
 			name: "rust",
 			args: "OpenEthereum/v3.2.6-stable-f9f4926-20210514/x86_64-linux-gnu/rustc1.52.1",
-			want: &vparser.ParsedInfo{
+			want: vparser.ParsedInfo{
 				Name: "openethereum",
 				Version: vparser.Version{
 					Major: 3,
@@ -121,34 +131,245 @@ func TestParseVersionString(t *testing.T) {
 					Build: "f9f4926",
 					Date:  "20210514",
 				},
-				// This doesn't work
-				// Os: vparser.OSInfo{
-				// 	Os: "linux",
-				// 	Architecture: "x86_64",
-				// },
+				Os: vparser.OSInfo{
+					Os:           "linux",
+					Architecture: "x86_64",
+				},
 				Language: vparser.LanguageInfo{
 					Name:    "rustc",
 					Version: "1.52.1",
 				},
+				Confidence: vparser.ConfidenceMedium,
+			},
+		},
+		{
+			name: "nethermind",
+			args: "Nethermind/v1.32.4+1c4c7c0a/linux-x64/dotnet9.0.7",
+			want: vparser.ParsedInfo{
+				Name: "nethermind",
+				Version: vparser.Version{
+					Major: 1,
+					Minor: 32,
+					Patch: 4,
+					Build: "1c4c7c0a",
+				},
+				Os: vparser.OSInfo{
+					Os:           "linux",
+					Architecture: "x64",
+				},
+				Language: vparser.LanguageInfo{
+					Name:    "dotnet",
+					Version: "9.0.7",
+				},
+				Confidence: vparser.ConfidenceHigh,
 			},
 		},
+		{
+			name: "nethermind-legacy",
+			args: "Nethermind/v1.14.0-0-7b6fbc5d4-20220810/X64-Linux/6.0.7",
+			want: vparser.ParsedInfo{
+				Name: "nethermind",
+				Version: vparser.Version{
+					Major: 1,
+					Minor: 14,
+					Patch: 0,
+					Tag:   "0",
+					Build: "7b6fbc5d4",
+					Date:  "20220810",
+				},
+				Os: vparser.OSInfo{
+					Os:           "linux",
+					Architecture: "x64",
+				},
+				Language: vparser.LanguageInfo{
+					Name:    "dotnet",
+					Version: "6.0.7",
+				},
+				Confidence: vparser.ConfidenceHigh,
+			},
+		},
+		{
+			name: "reth",
+			args: "reth/v1.6.0-d8451e5/x86_64-unknown-linux-gnu",
+			want: vparser.ParsedInfo{
+				Name: "reth",
+				Version: vparser.Version{
+					Major: 1,
+					Minor: 6,
+					Patch: 0,
+					Build: "d8451e5",
+				},
+				Os: vparser.OSInfo{
+					Os:           "linux",
+					Architecture: "x86_64",
+				},
+				Language: vparser.LanguageInfo{
+					Name: "rust",
+				},
+				Confidence: vparser.ConfidenceHigh,
+			},
+		},
+		{
+			name: "prysm",
+			args: "Prysm/v6.0.4/3d9d5d8d3c1b2a6f",
+			want: vparser.ParsedInfo{
+				Name: "prysm",
+				Version: vparser.Version{
+					Major: 6,
+					Minor: 0,
+					Patch: 4,
+					Build: "3d9d5d8d3c1b2a6f",
+				},
+				Language: vparser.LanguageInfo{
+					Name: "go",
+				},
+				Confidence: vparser.ConfidenceHigh,
+			},
+		},
+		{
+			name: "no-version",
+			args: "Geth/linux-amd64/go1.16.3",
+			err:  vparser.ErrUnknownFormat,
+		},
 		{
 			name: "with-label",
 			args: "Q-Client/v1.0.8-stable/Geth/v1.10.8-stable-825470ee/linux-amd64/go1.16.15",
-			want: nil,
+			want: vparser.ParsedInfo{
+				Name: "q-client",
+				Version: vparser.Version{
+					Major: 1,
+					Minor: 0,
+					Patch: 8,
+					Tag:   "stable",
+				},
+				Os: vparser.OSInfo{
+					Os:           "linux",
+					Architecture: "amd64",
+				},
+				Language: vparser.LanguageInfo{
+					Name:    "go",
+					Version: "1.16.15",
+				},
+				Confidence: vparser.ConfidenceLow,
+			},
 		},
 		{
 			name: "with-enode",
 			args: "Geth/enode://91a3c3d5e76b0acf05d9abddee959f1bcbc7c91537d2629288a9edd7a3df90acaa46ffba0e0e5d49a20598e0960ac458d76eb8fa92a1d64938c0a3a3d60f8be4@127.0.0.1:21000/v1.10.0-stable(quorum-v22.1.0)/linux-amd64/go1.17.2",
-			want: nil,
+			err:  vparser.ErrEnode,
 		},
 	}
 
 	for _, tt := range test_data {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := vparser.ParseVersionString(tt.args); !reflect.DeepEqual(got, tt.want) {
+			got, err := vparser.ParseVersionString(tt.args)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseVersionString() error = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseVersionString() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestRegister(t *testing.T) {
+	vparser.Register("Custom", func(segments []string) (vparser.ParsedInfo, error) {
+		return vparser.ParsedInfo{Name: segments[0], Label: segments[1], Confidence: vparser.ConfidenceHigh}, nil
+	})
+	got, err := vparser.ParseVersionString("custom/something")
+	if err != nil {
+		t.Fatalf("ParseVersionString() error = %v", err)
+	}
+	if got.Label != "something" || got.Confidence != vparser.ConfidenceHigh {
+		t.Errorf("registered parser not used, got %v", got)
+	}
+}
+
+// TestCorpus parses real-world client names from testdata/corpus.txt. Each
+// line holds the minimum expected confidence and the name, separated by a tab.
+// Names expected to fail are marked "none" so new unknown formats show up as
+// test failures and the totals are reported.
+func TestCorpus(t *testing.T) {
+	f, err := os.Open("testdata/corpus.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	counts := make(map[vparser.Confidence]int)
+	unknown := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		want, name, ok := strings.Cut(text, "\t")
+		if _, known := confidences[want]; !ok || !known {
+			t.Fatalf("line %d: malformed entry %q", line, text)
+		}
+
+		got, err := vparser.ParseVersionString(name)
+		if err != nil {
+			unknown++
+			if confidences[want] != vparser.ConfidenceNone {
+				t.Errorf("line %d: %q: %v", line, name, err)
+			}
+			continue
+		}
+		counts[got.Confidence]++
+		if got.Confidence < confidences[want] {
+			t.Errorf("line %d: %q: confidence %v, want at least %v", line, name, got.Confidence, want)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("high=%d medium=%d low=%d unknown=%d", counts[vparser.ConfidenceHigh],
+		counts[vparser.ConfidenceMedium], counts[vparser.ConfidenceLow], unknown)
+}
+
+var confidences = map[string]vparser.Confidence{
+	"none":   vparser.ConfidenceNone,
+	"low":    vparser.ConfidenceLow,
+	"medium": vparser.ConfidenceMedium,
+	"high":   vparser.ConfidenceHigh,
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.16.2", "v1.16.2-stable", 0},
+		{"v1.16.2", "v1.16.10", -1},
+		{"v1.17.0", "v1.16.10", 1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v25.7.0-rc1", "v25.7.0", -1},
+		{"v25.7.0-rc1", "v25.7.0-rc2", -1},
+		{"v25.7.0-rc2", "v25.7.0-rc10", -1},
+		{"v25.7.0-rc.2", "v25.7.0-rc.10", -1},
+		{"v25.7.0-rc02", "v25.7.0-rc2", 0},
+		{"v25.7.0-beta3", "v25.7.0-rc1", -1},
+		{"v25.7.0-rc1", "v25.7.0-rc1.1", -1},
+		{"v1.14.0-unstable", "v1.13.15-stable", 1},
+		{"v1.32.4+1c4c7c0a", "1.32.4", 0},
+	}
+	for _, tt := range tests {
+		a, err := vparser.ParseVersion(tt.a)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error = %v", tt.a, err)
+		}
+		b, err := vparser.ParseVersion(tt.b)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error = %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Compare(a); got != -tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -7,12 +7,18 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"slices"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/200ug/peerlogger/internal/api"
 	"github.com/200ug/peerlogger/internal/common"
 	"github.com/200ug/peerlogger/internal/crawler"
 	"github.com/200ug/peerlogger/internal/db"
+	"github.com/200ug/peerlogger/internal/hosting"
+	"github.com/200ug/peerlogger/internal/releases"
+	"github.com/200ug/peerlogger/internal/sybil"
 	"github.com/200ug/peerlogger/internal/util"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -26,18 +32,24 @@ const (
 
 var (
 	config     *util.EnvConfig
+	configOnce sync.Once
 	appCfgHash [32]byte
 )
 
-func init() {
-	config = util.LoadEnv()
-	logLevel, _ := zerolog.ParseLevel(config.LogLevel) // should already be verified
-	zerolog.SetGlobalLevel(logLevel)
-	log.Debug().Msg("Primary initialization done")
+// loadConfig reads the configuration from the environment on first use, so
+// commands which don't need it (e.g. decoding a record) run without it.
+func loadConfig() *util.EnvConfig {
+	configOnce.Do(func() {
+		config = util.LoadEnv()
+		logLevel, _ := zerolog.ParseLevel(config.LogLevel) // should already be verified
+		zerolog.SetGlobalLevel(logLevel)
+		log.Debug().Msg("Primary initialization done")
+	})
+	return config
 }
 
-func initDB() (*sql.DB, error) {
-	database, err := sql.Open("postgres", config.DBURL)
+func openDB() (*sql.DB, error) {
+	database, err := sql.Open("postgres", loadConfig().DBURL)
 	if err != nil {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
@@ -47,6 +59,14 @@ func initDB() (*sql.DB, error) {
 		database.Close()
 		return nil, fmt.Errorf("database ping failed: %w", err)
 	}
+	return database, nil
+}
+
+func initDB() (*sql.DB, error) {
+	database, err := openDB()
+	if err != nil {
+		return nil, err
+	}
 
 	// Create the database schema if needed
 	if err := db.CreateDB(database); err != nil {
@@ -57,27 +77,99 @@ func initDB() (*sql.DB, error) {
 	return database, nil
 }
 
-func initGeoIP() (*util.GeoIP, error) {
-	if config.GeoIPCityDBPath == "" && config.GeoIPASNDBPath == "" {
-		log.Info().Msg("No GeoIP database paths configured, skipping GeoIP initialization")
-		return nil, nil
-	}
-
-	provider, err := util.NewGeoIP(config.GeoIPCityDBPath, config.GeoIPASNDBPath)
+func initGeoIP() (util.GeoProvider, error) {
+	provider, err := util.NewGeoProvider(util.GeoConfig{
+		Providers:  config.GeoProviders,
+		CityDBPath: config.GeoIPCityDBPath,
+		ASNDBPath:  config.GeoIPASNDBPath,
+		RangeFiles: config.GeoRangeFiles,
+		CacheSize:  config.GeoIPCacheSize,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("provider creation failed: %w", err)
 	}
 
-	info := provider.GetDatabaseInfo()
 	log.Info().
-		Bool("city_db", info["city_db_loaded"].(bool)).
-		Bool("asn_db", info["asn_db_loaded"].(bool)).
+		Strs("providers", config.GeoProviders).
+		Str("provider", fmt.Sprintf("%T", provider)).
 		Msg("GeoIP provider initialized successfully")
 
 	return provider, nil
 }
 
-func initBlacklist() *util.Blacklist {
+func initAdvisories() (*releases.Advisories, error) {
+	if config.AdvisoryPath == "" {
+		log.Info().Msg("No advisory database configured, skipping advisory matching")
+		return nil, nil
+	}
+
+	advisories, err := releases.LoadAdvisories(config.AdvisoryPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading advisories failed: %w", err)
+	}
+	log.Info().
+		Int("advisories", advisories.Len()).
+		Str("file", config.AdvisoryPath).
+		Msg("Advisory database loaded")
+
+	return advisories, nil
+}
+
+func initHosting() (*hosting.Classifier, error) {
+	var paths []string
+	for _, path := range []string{config.HostingRangesPath, config.HostingASNPath} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		log.Info().Msg("No hosting provider lists configured, skipping hosting classification")
+		return nil, nil
+	}
+
+	classifier, err := hosting.LoadClassifier(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("loading hosting provider lists failed: %w", err)
+	}
+	prefixes, asns := classifier.Len()
+	log.Info().
+		Int("prefixes", prefixes).
+		Int("asns", asns).
+		Strs("files", paths).
+		Msg("Hosting provider lists loaded")
+
+	return classifier, nil
+}
+
+func initPrivacy(mode string) (*util.IPPrivacy, error) {
+	privacy, err := util.NewIPPrivacy(mode, config.IPHMACKey, config.IPTruncateV4, config.IPTruncateV6)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP privacy configuration: %w", err)
+	}
+	return privacy, nil
+}
+
+// runRetention anonymises the IPs of rows older than the retention period
+// every hour until the context is cancelled.
+func runRetention(ctx context.Context, database *sql.DB, privacy *util.IPPrivacy, days int) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		before := time.Now().AddDate(0, 0, -days)
+		if _, err := db.AnonymizeIPs(database, privacy, before); err != nil {
+			log.Error().Err(err).Time("before", before).Msg("IP retention job failed")
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// initBlacklist loads the blacklist files and applies the entries managed in
+// the database on top of them.
+func initBlacklist(database *sql.DB) (*db.ManagedBlacklist, error) {
 	// load blacklists if the paths are defined in .env
 	var ipBlacklist []string
 	if config.IPBlacklistPath != "" {
@@ -89,16 +181,56 @@ func initBlacklist() *util.Blacklist {
 	}
 
 	blacklist := util.NewBlacklist(ipBlacklist, pubkeyBlacklist)
+
+	// exclude/include rules may be given in either file
+	var rulePaths []string
+	for _, path := range []string{config.IPBlacklistPath, config.PubkeyBlacklistPath} {
+		if path != "" && !slices.Contains(rulePaths, path) {
+			rulePaths = append(rulePaths, path)
+		}
+	}
+	rules, err := util.LoadRules(rulePaths...)
+	if err != nil {
+		return nil, fmt.Errorf("loading blacklist rules failed: %w", err)
+	}
+	if err := blacklist.SetRules(rules); err != nil {
+		return nil, err
+	}
+
+	managed := db.NewManagedBlacklist(database, blacklist, ipBlacklist, pubkeyBlacklist)
+	if err := managed.Sync(); err != nil {
+		return nil, fmt.Errorf("loading stored blacklist entries failed: %w", err)
+	}
+
 	ips, ipNets, pubkeys := blacklist.GetStats()
 	log.Info().
 		Int("ips", ips).
 		Int("ipNets", ipNets).
 		Int("pubkeys", pubkeys).
+		Int("exclude_rules", len(rules.Exclude)).
+		Int("include_rules", len(rules.Include)).
 		Str("ip_file", config.IPBlacklistPath).
 		Str("pubkey_file", config.PubkeyBlacklistPath).
 		Msg("Blacklist initialized")
 
-	return blacklist
+	return managed, nil
+}
+
+// syncBlacklist applies stored blacklist entries changed by other processes
+// and drops expired ones every minute until the context is cancelled.
+func syncBlacklist(ctx context.Context, blacklist *db.ManagedBlacklist) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := blacklist.Sync(); err != nil {
+				log.Error().Err(err).Msg("Blacklist sync failed")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 func printStartupInfo() {
@@ -127,11 +259,16 @@ func setupSignalHandling(cancel context.CancelFunc) {
 }
 
 func main() {
-	printStartupInfo()
+	// Subcommands operate on the existing database and exit when done
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal().Err(err).Str("command", os.Args[1]).Msg("Command failed")
+		}
+		return
+	}
 
-	// Initialize blacklist
-	_ = initBlacklist() // blacklist initialized but not used in this demo
-	log.Info().Msg("Blacklist initialization completed")
+	loadConfig()
+	printStartupInfo()
 
 	// Initialize database
 	database, err := initDB()
@@ -140,16 +277,63 @@ func main() {
 	}
 	defer database.Close()
 
+	// Initialize blacklist
+	blacklist, err := initBlacklist(database)
+	if err != nil {
+		log.Fatal().Err(err).
+			Str("ip_file", config.IPBlacklistPath).
+			Str("pubkey_file", config.PubkeyBlacklistPath).
+			Msg("Blacklist initialization failed")
+	}
+	log.Info().Msg("Blacklist initialization completed")
+
 	// Initialize GeoIP provider
 	geoIP, err := initGeoIP()
 	if err != nil {
 		log.Fatal().Err(err).
+			Strs("providers", config.GeoProviders).
 			Str("city_db", config.GeoIPCityDBPath).
 			Str("asn_db", config.GeoIPASNDBPath).
+			Strs("range_files", config.GeoRangeFiles).
 			Msg("GeoIP provider initialization failed")
 	}
-	if geoIP != nil {
-		defer geoIP.Close()
+	defer geoIP.Close()
+
+	advisories, err := initAdvisories()
+	if err != nil {
+		log.Fatal().Err(err).Str("file", config.AdvisoryPath).Msg("Advisory database initialization failed")
+	}
+
+	classifier, err := initHosting()
+	if err != nil {
+		log.Fatal().Err(err).
+			Str("ranges", config.HostingRangesPath).
+			Str("asns", config.HostingASNPath).
+			Msg("Hosting classifier initialization failed")
+	}
+
+	privacy, err := initPrivacy(config.IPPrivacyMode)
+	if err != nil {
+		log.Fatal().Err(err).Str("mode", config.IPPrivacyMode).Msg("IP privacy initialization failed")
+	}
+	var retention *util.IPPrivacy
+	if config.IPRetentionDays > 0 {
+		if retention, err = initPrivacy(config.IPRetentionMode); err != nil {
+			log.Fatal().Err(err).Str("mode", config.IPRetentionMode).Msg("IP retention initialization failed")
+		}
+		if retention.Mode == util.PrivacyFull {
+			log.Fatal().Msg("IP_RETENTION_MODE must anonymise, use truncated, hmac or none")
+		}
+	}
+	log.Info().
+		Str("mode", privacy.Mode).
+		Int("retention_days", config.IPRetentionDays).
+		Str("retention_mode", config.IPRetentionMode).
+		Msg("IP privacy configured")
+
+	mode, err := crawler.ParseMode(config.CrawlMode)
+	if err != nil {
+		log.Fatal().Err(err).Msg("CRAWL_MODE must be random or table")
 	}
 
 	// Initialize crawler components
@@ -163,9 +347,19 @@ func main() {
 		Workers:    16,
 		Sepolia:    false,
 		Hoodi:      false,
+		Mode:       mode,
+
+		ENRFallback: config.ENRFallback,
+		DualStack:   config.DualStack,
+		PreferIPv6:  config.PreferIPv6,
+		BeaconProbe: config.BeaconProbe,
+		Advisories:  advisories,
+		Hosting:     classifier,
+		Privacy:     privacy,
+		Blacklist:   blacklist.Blacklist,
 	}
 
-	log.Info().Msg("Crawler initialized successfully")
+	log.Info().Str("mode", c.Mode).Msg("Crawler initialized successfully")
 
 	// Setup signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -175,6 +369,38 @@ func main() {
 	// Demo crawling functionality
 	log.Info().Msg("Starting peer crawler demo...")
 	
+	// Serve the HTTP API if an address is configured
+	if config.APIListenAddr != "" {
+		go func() {
+			log.Info().Str("addr", config.APIListenAddr).Msg("API server listening")
+			srv := api.NewServer(database)
+			srv.EnableAdmin(blacklist, config.AdminTokens)
+			if err := srv.ListenAndServe(ctx, config.APIListenAddr); err != nil {
+				log.Error().Err(err).Str("addr", config.APIListenAddr).Msg("API server failed")
+			}
+		}()
+	}
+
+	go syncBlacklist(ctx, blacklist)
+
+	// Anonymise stored IPs past the retention period
+	if retention != nil {
+		go runRetention(ctx, database, retention, config.IPRetentionDays)
+	}
+
+	// Flag sybil suspects after each crawl round
+	var monitor *sybil.Monitor
+	if config.SybilDetection {
+		cfg := sybil.DefaultConfig()
+		cfg.MaxPerIP = config.SybilMaxPerIP
+		cfg.MaxPerPrefix = config.SybilMaxPerPrefix
+		cfg.MaxPerASN = config.SybilMaxPerASN
+		cfg.MinCluster = config.SybilMinCluster
+		cfg.ClusterBits = config.SybilClusterBits
+		store := db.NewSybilStore(database, privacy, blacklist)
+		monitor = sybil.NewMonitor(cfg, config.SybilBlacklistTTL, privacy, store)
+	}
+
 	// Create an empty initial node set
 	inputSet := make(common.NodeSet)
 	
@@ -194,6 +420,12 @@ func main() {
 				log.Info().
 					Int("discovered_nodes", len(results)).
 					Msg("Crawl round completed")
+
+				if monitor != nil {
+					if _, err := monitor.Run(time.Now(), sybil.CrawlNodes(results, geoIP)); err != nil {
+						log.Error().Err(err).Msg("Sybil detection failed")
+					}
+				}
 					
 				// Update inputSet with discovered nodes for next round
 				inputSet = results